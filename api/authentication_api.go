@@ -0,0 +1,45 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package api
+
+//First pass of M-Pin authentication. U and UT are base64 url encoded. UT is empty if time permits are not used
+type AuthenticationPass1Request struct {
+	ClientID string
+	U        string
+	UT       string
+	//Master secret version of the client secret. The active version is used if it is 0
+	KeyVersion int
+}
+
+type AuthenticationPass1Response struct {
+	SessionID string
+	Y         string
+	Message   string
+}
+
+//Second pass of M-Pin authentication. V is base64 url encoded
+type AuthenticationPass2Request struct {
+	SessionID string
+	V         string
+}
+
+type AuthenticationPass2Response struct {
+	Authenticated bool
+	Message       string
+}
@@ -0,0 +1,1358 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements. See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership. The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License. You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: dta.proto
+
+package dtapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ServerSecretRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Master secret version. The active version is used when it is 0
+	KeyVersion    int32 `protobuf:"varint,1,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerSecretRequest) Reset() {
+	*x = ServerSecretRequest{}
+	mi := &file_dta_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerSecretRequest) ProtoMessage() {}
+
+func (x *ServerSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerSecretRequest.ProtoReflect.Descriptor instead.
+func (*ServerSecretRequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ServerSecretRequest) GetKeyVersion() int32 {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return 0
+}
+
+// signature and signing_key_id of the issuance responses are described in signature.IssuanceMessage
+type ServerSecretResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ServerSecret  []byte                 `protobuf:"bytes,1,opt,name=server_secret,json=serverSecret,proto3" json:"server_secret,omitempty"`
+	KeyVersion    int32                  `protobuf:"varint,2,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	SigningKeyId  string                 `protobuf:"bytes,4,opt,name=signing_key_id,json=signingKeyId,proto3" json:"signing_key_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerSecretResponse) Reset() {
+	*x = ServerSecretResponse{}
+	mi := &file_dta_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerSecretResponse) ProtoMessage() {}
+
+func (x *ServerSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerSecretResponse.ProtoReflect.Descriptor instead.
+func (*ServerSecretResponse) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ServerSecretResponse) GetServerSecret() []byte {
+	if x != nil {
+		return x.ServerSecret
+	}
+	return nil
+}
+
+func (x *ServerSecretResponse) GetKeyVersion() int32 {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return 0
+}
+
+func (x *ServerSecretResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *ServerSecretResponse) GetSigningKeyId() string {
+	if x != nil {
+		return x.SigningKeyId
+	}
+	return ""
+}
+
+type ClientSecretRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	KeyVersion    int32                  `protobuf:"varint,2,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientSecretRequest) Reset() {
+	*x = ClientSecretRequest{}
+	mi := &file_dta_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientSecretRequest) ProtoMessage() {}
+
+func (x *ClientSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientSecretRequest.ProtoReflect.Descriptor instead.
+func (*ClientSecretRequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ClientSecretRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *ClientSecretRequest) GetKeyVersion() int32 {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return 0
+}
+
+type ClientSecretResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientSecret  []byte                 `protobuf:"bytes,1,opt,name=client_secret,json=clientSecret,proto3" json:"client_secret,omitempty"`
+	KeyVersion    int32                  `protobuf:"varint,2,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	SigningKeyId  string                 `protobuf:"bytes,4,opt,name=signing_key_id,json=signingKeyId,proto3" json:"signing_key_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientSecretResponse) Reset() {
+	*x = ClientSecretResponse{}
+	mi := &file_dta_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientSecretResponse) ProtoMessage() {}
+
+func (x *ClientSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientSecretResponse.ProtoReflect.Descriptor instead.
+func (*ClientSecretResponse) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ClientSecretResponse) GetClientSecret() []byte {
+	if x != nil {
+		return x.ClientSecret
+	}
+	return nil
+}
+
+func (x *ClientSecretResponse) GetKeyVersion() int32 {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return 0
+}
+
+func (x *ClientSecretResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *ClientSecretResponse) GetSigningKeyId() string {
+	if x != nil {
+		return x.SigningKeyId
+	}
+	return ""
+}
+
+type TimePermitRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientId      string                 `protobuf:"bytes,1,opt,name=client_id,json=clientId,proto3" json:"client_id,omitempty"`
+	KeyVersion    int32                  `protobuf:"varint,2,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimePermitRequest) Reset() {
+	*x = TimePermitRequest{}
+	mi := &file_dta_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimePermitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimePermitRequest) ProtoMessage() {}
+
+func (x *TimePermitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimePermitRequest.ProtoReflect.Descriptor instead.
+func (*TimePermitRequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TimePermitRequest) GetClientId() string {
+	if x != nil {
+		return x.ClientId
+	}
+	return ""
+}
+
+func (x *TimePermitRequest) GetKeyVersion() int32 {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return 0
+}
+
+type TimePermitResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	TimePermit []byte                 `protobuf:"bytes,1,opt,name=time_permit,json=timePermit,proto3" json:"time_permit,omitempty"`
+	// Days since 1970-01-01 for which the time permit is issued
+	EpochDay      int32  `protobuf:"varint,2,opt,name=epoch_day,json=epochDay,proto3" json:"epoch_day,omitempty"`
+	KeyVersion    int32  `protobuf:"varint,3,opt,name=key_version,json=keyVersion,proto3" json:"key_version,omitempty"`
+	Signature     []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	SigningKeyId  string `protobuf:"bytes,5,opt,name=signing_key_id,json=signingKeyId,proto3" json:"signing_key_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimePermitResponse) Reset() {
+	*x = TimePermitResponse{}
+	mi := &file_dta_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimePermitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimePermitResponse) ProtoMessage() {}
+
+func (x *TimePermitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimePermitResponse.ProtoReflect.Descriptor instead.
+func (*TimePermitResponse) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TimePermitResponse) GetTimePermit() []byte {
+	if x != nil {
+		return x.TimePermit
+	}
+	return nil
+}
+
+func (x *TimePermitResponse) GetEpochDay() int32 {
+	if x != nil {
+		return x.EpochDay
+	}
+	return 0
+}
+
+func (x *TimePermitResponse) GetKeyVersion() int32 {
+	if x != nil {
+		return x.KeyVersion
+	}
+	return 0
+}
+
+func (x *TimePermitResponse) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *TimePermitResponse) GetSigningKeyId() string {
+	if x != nil {
+		return x.SigningKeyId
+	}
+	return ""
+}
+
+type RPAKey struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	KeyId     string                 `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Key       []byte                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	NotBefore *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	// Not set if the key does not expire
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RPAKey) Reset() {
+	*x = RPAKey{}
+	mi := &file_dta_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RPAKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPAKey) ProtoMessage() {}
+
+func (x *RPAKey) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPAKey.ProtoReflect.Descriptor instead.
+func (*RPAKey) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RPAKey) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *RPAKey) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *RPAKey) GetNotBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotBefore
+	}
+	return nil
+}
+
+func (x *RPAKey) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type RelyingPartyApplication struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationId string                 `protobuf:"bytes,1,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	// The latest key. It is the public key of applications with Ed25519 keys
+	ApplicationKey []byte `protobuf:"bytes,2,opt,name=application_key,json=applicationKey,proto3" json:"application_key,omitempty"`
+	// shared or ed25519
+	KeyType string `protobuf:"bytes,3,opt,name=key_type,json=keyType,proto3" json:"key_type,omitempty"`
+	// Every key which is not expired, oldest first
+	Keys                    []*RPAKey              `protobuf:"bytes,4,rep,name=keys,proto3" json:"keys,omitempty"`
+	DisplayName             string                 `protobuf:"bytes,5,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	OwnerContact            string                 `protobuf:"bytes,6,opt,name=owner_contact,json=ownerContact,proto3" json:"owner_contact,omitempty"`
+	CreatedAt               *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt               *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Disabled                bool                   `protobuf:"varint,9,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	AllowedOperations       []string               `protobuf:"bytes,10,rep,name=allowed_operations,json=allowedOperations,proto3" json:"allowed_operations,omitempty"`
+	MaxPermitRange          int32                  `protobuf:"varint,11,opt,name=max_permit_range,json=maxPermitRange,proto3" json:"max_permit_range,omitempty"`
+	AllowedIdentityDomains  []string               `protobuf:"bytes,12,rep,name=allowed_identity_domains,json=allowedIdentityDomains,proto3" json:"allowed_identity_domains,omitempty"`
+	CertificateFingerprints []string               `protobuf:"bytes,13,rep,name=certificate_fingerprints,json=certificateFingerprints,proto3" json:"certificate_fingerprints,omitempty"`
+	Metadata                map[string]string      `protobuf:"bytes,14,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Revision                int64                  `protobuf:"varint,15,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *RelyingPartyApplication) Reset() {
+	*x = RelyingPartyApplication{}
+	mi := &file_dta_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RelyingPartyApplication) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelyingPartyApplication) ProtoMessage() {}
+
+func (x *RelyingPartyApplication) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelyingPartyApplication.ProtoReflect.Descriptor instead.
+func (*RelyingPartyApplication) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RelyingPartyApplication) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+func (x *RelyingPartyApplication) GetApplicationKey() []byte {
+	if x != nil {
+		return x.ApplicationKey
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetKeyType() string {
+	if x != nil {
+		return x.KeyType
+	}
+	return ""
+}
+
+func (x *RelyingPartyApplication) GetKeys() []*RPAKey {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *RelyingPartyApplication) GetOwnerContact() string {
+	if x != nil {
+		return x.OwnerContact
+	}
+	return ""
+}
+
+func (x *RelyingPartyApplication) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *RelyingPartyApplication) GetAllowedOperations() []string {
+	if x != nil {
+		return x.AllowedOperations
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetMaxPermitRange() int32 {
+	if x != nil {
+		return x.MaxPermitRange
+	}
+	return 0
+}
+
+func (x *RelyingPartyApplication) GetAllowedIdentityDomains() []string {
+	if x != nil {
+		return x.AllowedIdentityDomains
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetCertificateFingerprints() []string {
+	if x != nil {
+		return x.CertificateFingerprints
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *RelyingPartyApplication) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type ListRPAsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRPAsRequest) Reset() {
+	*x = ListRPAsRequest{}
+	mi := &file_dta_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRPAsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRPAsRequest) ProtoMessage() {}
+
+func (x *ListRPAsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRPAsRequest.ProtoReflect.Descriptor instead.
+func (*ListRPAsRequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{8}
+}
+
+type ListRPAsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationIds []string               `protobuf:"bytes,1,rep,name=application_ids,json=applicationIds,proto3" json:"application_ids,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ListRPAsResponse) Reset() {
+	*x = ListRPAsResponse{}
+	mi := &file_dta_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRPAsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRPAsResponse) ProtoMessage() {}
+
+func (x *ListRPAsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRPAsResponse.ProtoReflect.Descriptor instead.
+func (*ListRPAsResponse) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListRPAsResponse) GetApplicationIds() []string {
+	if x != nil {
+		return x.ApplicationIds
+	}
+	return nil
+}
+
+type GetRPARequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationId string                 `protobuf:"bytes,1,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRPARequest) Reset() {
+	*x = GetRPARequest{}
+	mi := &file_dta_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRPARequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRPARequest) ProtoMessage() {}
+
+func (x *GetRPARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRPARequest.ProtoReflect.Descriptor instead.
+func (*GetRPARequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetRPARequest) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+type RegisterRPARequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationId string                 `protobuf:"bytes,1,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	// shared, the default, or ed25519
+	KeyType string `protobuf:"bytes,2,opt,name=key_type,json=keyType,proto3" json:"key_type,omitempty"`
+	// Ed25519 public key of the application. Required if key_type is ed25519
+	PublicKey               []byte            `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	DisplayName             string            `protobuf:"bytes,4,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	OwnerContact            string            `protobuf:"bytes,5,opt,name=owner_contact,json=ownerContact,proto3" json:"owner_contact,omitempty"`
+	Disabled                bool              `protobuf:"varint,6,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	AllowedOperations       []string          `protobuf:"bytes,7,rep,name=allowed_operations,json=allowedOperations,proto3" json:"allowed_operations,omitempty"`
+	MaxPermitRange          int32             `protobuf:"varint,8,opt,name=max_permit_range,json=maxPermitRange,proto3" json:"max_permit_range,omitempty"`
+	AllowedIdentityDomains  []string          `protobuf:"bytes,9,rep,name=allowed_identity_domains,json=allowedIdentityDomains,proto3" json:"allowed_identity_domains,omitempty"`
+	CertificateFingerprints []string          `protobuf:"bytes,10,rep,name=certificate_fingerprints,json=certificateFingerprints,proto3" json:"certificate_fingerprints,omitempty"`
+	Metadata                map[string]string `protobuf:"bytes,11,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *RegisterRPARequest) Reset() {
+	*x = RegisterRPARequest{}
+	mi := &file_dta_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRPARequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRPARequest) ProtoMessage() {}
+
+func (x *RegisterRPARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRPARequest.ProtoReflect.Descriptor instead.
+func (*RegisterRPARequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RegisterRPARequest) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+func (x *RegisterRPARequest) GetKeyType() string {
+	if x != nil {
+		return x.KeyType
+	}
+	return ""
+}
+
+func (x *RegisterRPARequest) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *RegisterRPARequest) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *RegisterRPARequest) GetOwnerContact() string {
+	if x != nil {
+		return x.OwnerContact
+	}
+	return ""
+}
+
+func (x *RegisterRPARequest) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *RegisterRPARequest) GetAllowedOperations() []string {
+	if x != nil {
+		return x.AllowedOperations
+	}
+	return nil
+}
+
+func (x *RegisterRPARequest) GetMaxPermitRange() int32 {
+	if x != nil {
+		return x.MaxPermitRange
+	}
+	return 0
+}
+
+func (x *RegisterRPARequest) GetAllowedIdentityDomains() []string {
+	if x != nil {
+		return x.AllowedIdentityDomains
+	}
+	return nil
+}
+
+func (x *RegisterRPARequest) GetCertificateFingerprints() []string {
+	if x != nil {
+		return x.CertificateFingerprints
+	}
+	return nil
+}
+
+func (x *RegisterRPARequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type UpdateRPARequest struct {
+	state                   protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationId           string                 `protobuf:"bytes,1,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	DisplayName             string                 `protobuf:"bytes,2,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	OwnerContact            string                 `protobuf:"bytes,3,opt,name=owner_contact,json=ownerContact,proto3" json:"owner_contact,omitempty"`
+	Disabled                bool                   `protobuf:"varint,4,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	AllowedOperations       []string               `protobuf:"bytes,5,rep,name=allowed_operations,json=allowedOperations,proto3" json:"allowed_operations,omitempty"`
+	MaxPermitRange          int32                  `protobuf:"varint,6,opt,name=max_permit_range,json=maxPermitRange,proto3" json:"max_permit_range,omitempty"`
+	AllowedIdentityDomains  []string               `protobuf:"bytes,7,rep,name=allowed_identity_domains,json=allowedIdentityDomains,proto3" json:"allowed_identity_domains,omitempty"`
+	CertificateFingerprints []string               `protobuf:"bytes,8,rep,name=certificate_fingerprints,json=certificateFingerprints,proto3" json:"certificate_fingerprints,omitempty"`
+	Metadata                map[string]string      `protobuf:"bytes,9,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Revision                int64                  `protobuf:"varint,10,opt,name=revision,proto3" json:"revision,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *UpdateRPARequest) Reset() {
+	*x = UpdateRPARequest{}
+	mi := &file_dta_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRPARequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRPARequest) ProtoMessage() {}
+
+func (x *UpdateRPARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRPARequest.ProtoReflect.Descriptor instead.
+func (*UpdateRPARequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateRPARequest) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+func (x *UpdateRPARequest) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *UpdateRPARequest) GetOwnerContact() string {
+	if x != nil {
+		return x.OwnerContact
+	}
+	return ""
+}
+
+func (x *UpdateRPARequest) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *UpdateRPARequest) GetAllowedOperations() []string {
+	if x != nil {
+		return x.AllowedOperations
+	}
+	return nil
+}
+
+func (x *UpdateRPARequest) GetMaxPermitRange() int32 {
+	if x != nil {
+		return x.MaxPermitRange
+	}
+	return 0
+}
+
+func (x *UpdateRPARequest) GetAllowedIdentityDomains() []string {
+	if x != nil {
+		return x.AllowedIdentityDomains
+	}
+	return nil
+}
+
+func (x *UpdateRPARequest) GetCertificateFingerprints() []string {
+	if x != nil {
+		return x.CertificateFingerprints
+	}
+	return nil
+}
+
+func (x *UpdateRPARequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *UpdateRPARequest) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type DeleteRPARequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationId string                 `protobuf:"bytes,1,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRPARequest) Reset() {
+	*x = DeleteRPARequest{}
+	mi := &file_dta_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRPARequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRPARequest) ProtoMessage() {}
+
+func (x *DeleteRPARequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRPARequest.ProtoReflect.Descriptor instead.
+func (*DeleteRPARequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteRPARequest) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+type DeleteRPAResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRPAResponse) Reset() {
+	*x = DeleteRPAResponse{}
+	mi := &file_dta_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRPAResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRPAResponse) ProtoMessage() {}
+
+func (x *DeleteRPAResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRPAResponse.ProtoReflect.Descriptor instead.
+func (*DeleteRPAResponse) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{14}
+}
+
+type RollRPAKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ApplicationId string                 `protobuf:"bytes,1,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	// The new key is valid from now if it is not set
+	NotBefore *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	// New Ed25519 public key. Required for applications with Ed25519 keys
+	PublicKey     []byte `protobuf:"bytes,3,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollRPAKeyRequest) Reset() {
+	*x = RollRPAKeyRequest{}
+	mi := &file_dta_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollRPAKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollRPAKeyRequest) ProtoMessage() {}
+
+func (x *RollRPAKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dta_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollRPAKeyRequest.ProtoReflect.Descriptor instead.
+func (*RollRPAKeyRequest) Descriptor() ([]byte, []int) {
+	return file_dta_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RollRPAKeyRequest) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+func (x *RollRPAKeyRequest) GetNotBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotBefore
+	}
+	return nil
+}
+
+func (x *RollRPAKeyRequest) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+var File_dta_proto protoreflect.FileDescriptor
+
+const file_dta_proto_rawDesc = "" +
+	"\n" +
+	"\tdta.proto\x12\x0emilagro.dta.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"6\n" +
+	"\x13ServerSecretRequest\x12\x1f\n" +
+	"\vkey_version\x18\x01 \x01(\x05R\n" +
+	"keyVersion\"\xa0\x01\n" +
+	"\x14ServerSecretResponse\x12#\n" +
+	"\rserver_secret\x18\x01 \x01(\fR\fserverSecret\x12\x1f\n" +
+	"\vkey_version\x18\x02 \x01(\x05R\n" +
+	"keyVersion\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\x12$\n" +
+	"\x0esigning_key_id\x18\x04 \x01(\tR\fsigningKeyId\"S\n" +
+	"\x13ClientSecretRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1f\n" +
+	"\vkey_version\x18\x02 \x01(\x05R\n" +
+	"keyVersion\"\xa0\x01\n" +
+	"\x14ClientSecretResponse\x12#\n" +
+	"\rclient_secret\x18\x01 \x01(\fR\fclientSecret\x12\x1f\n" +
+	"\vkey_version\x18\x02 \x01(\x05R\n" +
+	"keyVersion\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\x12$\n" +
+	"\x0esigning_key_id\x18\x04 \x01(\tR\fsigningKeyId\"Q\n" +
+	"\x11TimePermitRequest\x12\x1b\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\x12\x1f\n" +
+	"\vkey_version\x18\x02 \x01(\x05R\n" +
+	"keyVersion\"\xb7\x01\n" +
+	"\x12TimePermitResponse\x12\x1f\n" +
+	"\vtime_permit\x18\x01 \x01(\fR\n" +
+	"timePermit\x12\x1b\n" +
+	"\tepoch_day\x18\x02 \x01(\x05R\bepochDay\x12\x1f\n" +
+	"\vkey_version\x18\x03 \x01(\x05R\n" +
+	"keyVersion\x12\x1c\n" +
+	"\tsignature\x18\x04 \x01(\fR\tsignature\x12$\n" +
+	"\x0esigning_key_id\x18\x05 \x01(\tR\fsigningKeyId\"\xa7\x01\n" +
+	"\x06RPAKey\x12\x15\n" +
+	"\x06key_id\x18\x01 \x01(\tR\x05keyId\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\fR\x03key\x129\n" +
+	"\n" +
+	"not_before\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tnotBefore\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"\x84\x06\n" +
+	"\x17RelyingPartyApplication\x12%\n" +
+	"\x0eapplication_id\x18\x01 \x01(\tR\rapplicationId\x12'\n" +
+	"\x0fapplication_key\x18\x02 \x01(\fR\x0eapplicationKey\x12\x19\n" +
+	"\bkey_type\x18\x03 \x01(\tR\akeyType\x12*\n" +
+	"\x04keys\x18\x04 \x03(\v2\x16.milagro.dta.v1.RPAKeyR\x04keys\x12!\n" +
+	"\fdisplay_name\x18\x05 \x01(\tR\vdisplayName\x12#\n" +
+	"\rowner_contact\x18\x06 \x01(\tR\fownerContact\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x1a\n" +
+	"\bdisabled\x18\t \x01(\bR\bdisabled\x12-\n" +
+	"\x12allowed_operations\x18\n" +
+	" \x03(\tR\x11allowedOperations\x12(\n" +
+	"\x10max_permit_range\x18\v \x01(\x05R\x0emaxPermitRange\x128\n" +
+	"\x18allowed_identity_domains\x18\f \x03(\tR\x16allowedIdentityDomains\x129\n" +
+	"\x18certificate_fingerprints\x18\r \x03(\tR\x17certificateFingerprints\x12Q\n" +
+	"\bmetadata\x18\x0e \x03(\v25.milagro.dta.v1.RelyingPartyApplication.MetadataEntryR\bmetadata\x12\x1a\n" +
+	"\brevision\x18\x0f \x01(\x03R\brevision\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x11\n" +
+	"\x0fListRPAsRequest\";\n" +
+	"\x10ListRPAsResponse\x12'\n" +
+	"\x0fapplication_ids\x18\x01 \x03(\tR\x0eapplicationIds\"6\n" +
+	"\rGetRPARequest\x12%\n" +
+	"\x0eapplication_id\x18\x01 \x01(\tR\rapplicationId\"\xb2\x04\n" +
+	"\x12RegisterRPARequest\x12%\n" +
+	"\x0eapplication_id\x18\x01 \x01(\tR\rapplicationId\x12\x19\n" +
+	"\bkey_type\x18\x02 \x01(\tR\akeyType\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x03 \x01(\fR\tpublicKey\x12!\n" +
+	"\fdisplay_name\x18\x04 \x01(\tR\vdisplayName\x12#\n" +
+	"\rowner_contact\x18\x05 \x01(\tR\fownerContact\x12\x1a\n" +
+	"\bdisabled\x18\x06 \x01(\bR\bdisabled\x12-\n" +
+	"\x12allowed_operations\x18\a \x03(\tR\x11allowedOperations\x12(\n" +
+	"\x10max_permit_range\x18\b \x01(\x05R\x0emaxPermitRange\x128\n" +
+	"\x18allowed_identity_domains\x18\t \x03(\tR\x16allowedIdentityDomains\x129\n" +
+	"\x18certificate_fingerprints\x18\n" +
+	" \x03(\tR\x17certificateFingerprints\x12L\n" +
+	"\bmetadata\x18\v \x03(\v20.milagro.dta.v1.RegisterRPARequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x90\x04\n" +
+	"\x10UpdateRPARequest\x12%\n" +
+	"\x0eapplication_id\x18\x01 \x01(\tR\rapplicationId\x12!\n" +
+	"\fdisplay_name\x18\x02 \x01(\tR\vdisplayName\x12#\n" +
+	"\rowner_contact\x18\x03 \x01(\tR\fownerContact\x12\x1a\n" +
+	"\bdisabled\x18\x04 \x01(\bR\bdisabled\x12-\n" +
+	"\x12allowed_operations\x18\x05 \x03(\tR\x11allowedOperations\x12(\n" +
+	"\x10max_permit_range\x18\x06 \x01(\x05R\x0emaxPermitRange\x128\n" +
+	"\x18allowed_identity_domains\x18\a \x03(\tR\x16allowedIdentityDomains\x129\n" +
+	"\x18certificate_fingerprints\x18\b \x03(\tR\x17certificateFingerprints\x12J\n" +
+	"\bmetadata\x18\t \x03(\v2..milagro.dta.v1.UpdateRPARequest.MetadataEntryR\bmetadata\x12\x1a\n" +
+	"\brevision\x18\n" +
+	" \x01(\x03R\brevision\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"9\n" +
+	"\x10DeleteRPARequest\x12%\n" +
+	"\x0eapplication_id\x18\x01 \x01(\tR\rapplicationId\"\x13\n" +
+	"\x11DeleteRPAResponse\"\x94\x01\n" +
+	"\x11RollRPAKeyRequest\x12%\n" +
+	"\x0eapplication_id\x18\x01 \x01(\tR\rapplicationId\x129\n" +
+	"\n" +
+	"not_before\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tnotBefore\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x03 \x01(\fR\tpublicKey2\x9e\x02\n" +
+	"\bIssuance\x12\\\n" +
+	"\x0fGetServerSecret\x12#.milagro.dta.v1.ServerSecretRequest\x1a$.milagro.dta.v1.ServerSecretResponse\x12\\\n" +
+	"\x0fGetClientSecret\x12#.milagro.dta.v1.ClientSecretRequest\x1a$.milagro.dta.v1.ClientSecretResponse\x12V\n" +
+	"\rGetTimePermit\x12!.milagro.dta.v1.TimePermitRequest\x1a\".milagro.dta.v1.TimePermitResponse2\x8b\x04\n" +
+	"\bRPAAdmin\x12M\n" +
+	"\bListRPAs\x12\x1f.milagro.dta.v1.ListRPAsRequest\x1a .milagro.dta.v1.ListRPAsResponse\x12P\n" +
+	"\x06GetRPA\x12\x1d.milagro.dta.v1.GetRPARequest\x1a'.milagro.dta.v1.RelyingPartyApplication\x12Z\n" +
+	"\vRegisterRPA\x12\".milagro.dta.v1.RegisterRPARequest\x1a'.milagro.dta.v1.RelyingPartyApplication\x12V\n" +
+	"\tUpdateRPA\x12 .milagro.dta.v1.UpdateRPARequest\x1a'.milagro.dta.v1.RelyingPartyApplication\x12P\n" +
+	"\tDeleteRPA\x12 .milagro.dta.v1.DeleteRPARequest\x1a!.milagro.dta.v1.DeleteRPAResponse\x12X\n" +
+	"\n" +
+	"RollRPAKey\x12!.milagro.dta.v1.RollRPAKeyRequest\x1a'.milagro.dta.v1.RelyingPartyApplicationB2Z0github.com/ajanthan/apache-milagro-dta/api/dtapbb\x06proto3"
+
+var (
+	file_dta_proto_rawDescOnce sync.Once
+	file_dta_proto_rawDescData []byte
+)
+
+func file_dta_proto_rawDescGZIP() []byte {
+	file_dta_proto_rawDescOnce.Do(func() {
+		file_dta_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dta_proto_rawDesc), len(file_dta_proto_rawDesc)))
+	})
+	return file_dta_proto_rawDescData
+}
+
+var file_dta_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_dta_proto_goTypes = []any{
+	(*ServerSecretRequest)(nil),     // 0: milagro.dta.v1.ServerSecretRequest
+	(*ServerSecretResponse)(nil),    // 1: milagro.dta.v1.ServerSecretResponse
+	(*ClientSecretRequest)(nil),     // 2: milagro.dta.v1.ClientSecretRequest
+	(*ClientSecretResponse)(nil),    // 3: milagro.dta.v1.ClientSecretResponse
+	(*TimePermitRequest)(nil),       // 4: milagro.dta.v1.TimePermitRequest
+	(*TimePermitResponse)(nil),      // 5: milagro.dta.v1.TimePermitResponse
+	(*RPAKey)(nil),                  // 6: milagro.dta.v1.RPAKey
+	(*RelyingPartyApplication)(nil), // 7: milagro.dta.v1.RelyingPartyApplication
+	(*ListRPAsRequest)(nil),         // 8: milagro.dta.v1.ListRPAsRequest
+	(*ListRPAsResponse)(nil),        // 9: milagro.dta.v1.ListRPAsResponse
+	(*GetRPARequest)(nil),           // 10: milagro.dta.v1.GetRPARequest
+	(*RegisterRPARequest)(nil),      // 11: milagro.dta.v1.RegisterRPARequest
+	(*UpdateRPARequest)(nil),        // 12: milagro.dta.v1.UpdateRPARequest
+	(*DeleteRPARequest)(nil),        // 13: milagro.dta.v1.DeleteRPARequest
+	(*DeleteRPAResponse)(nil),       // 14: milagro.dta.v1.DeleteRPAResponse
+	(*RollRPAKeyRequest)(nil),       // 15: milagro.dta.v1.RollRPAKeyRequest
+	nil,                             // 16: milagro.dta.v1.RelyingPartyApplication.MetadataEntry
+	nil,                             // 17: milagro.dta.v1.RegisterRPARequest.MetadataEntry
+	nil,                             // 18: milagro.dta.v1.UpdateRPARequest.MetadataEntry
+	(*timestamppb.Timestamp)(nil),   // 19: google.protobuf.Timestamp
+}
+var file_dta_proto_depIdxs = []int32{
+	19, // 0: milagro.dta.v1.RPAKey.not_before:type_name -> google.protobuf.Timestamp
+	19, // 1: milagro.dta.v1.RPAKey.expires_at:type_name -> google.protobuf.Timestamp
+	6,  // 2: milagro.dta.v1.RelyingPartyApplication.keys:type_name -> milagro.dta.v1.RPAKey
+	19, // 3: milagro.dta.v1.RelyingPartyApplication.created_at:type_name -> google.protobuf.Timestamp
+	19, // 4: milagro.dta.v1.RelyingPartyApplication.updated_at:type_name -> google.protobuf.Timestamp
+	16, // 5: milagro.dta.v1.RelyingPartyApplication.metadata:type_name -> milagro.dta.v1.RelyingPartyApplication.MetadataEntry
+	17, // 6: milagro.dta.v1.RegisterRPARequest.metadata:type_name -> milagro.dta.v1.RegisterRPARequest.MetadataEntry
+	18, // 7: milagro.dta.v1.UpdateRPARequest.metadata:type_name -> milagro.dta.v1.UpdateRPARequest.MetadataEntry
+	19, // 8: milagro.dta.v1.RollRPAKeyRequest.not_before:type_name -> google.protobuf.Timestamp
+	0,  // 9: milagro.dta.v1.Issuance.GetServerSecret:input_type -> milagro.dta.v1.ServerSecretRequest
+	2,  // 10: milagro.dta.v1.Issuance.GetClientSecret:input_type -> milagro.dta.v1.ClientSecretRequest
+	4,  // 11: milagro.dta.v1.Issuance.GetTimePermit:input_type -> milagro.dta.v1.TimePermitRequest
+	8,  // 12: milagro.dta.v1.RPAAdmin.ListRPAs:input_type -> milagro.dta.v1.ListRPAsRequest
+	10, // 13: milagro.dta.v1.RPAAdmin.GetRPA:input_type -> milagro.dta.v1.GetRPARequest
+	11, // 14: milagro.dta.v1.RPAAdmin.RegisterRPA:input_type -> milagro.dta.v1.RegisterRPARequest
+	12, // 15: milagro.dta.v1.RPAAdmin.UpdateRPA:input_type -> milagro.dta.v1.UpdateRPARequest
+	13, // 16: milagro.dta.v1.RPAAdmin.DeleteRPA:input_type -> milagro.dta.v1.DeleteRPARequest
+	15, // 17: milagro.dta.v1.RPAAdmin.RollRPAKey:input_type -> milagro.dta.v1.RollRPAKeyRequest
+	1,  // 18: milagro.dta.v1.Issuance.GetServerSecret:output_type -> milagro.dta.v1.ServerSecretResponse
+	3,  // 19: milagro.dta.v1.Issuance.GetClientSecret:output_type -> milagro.dta.v1.ClientSecretResponse
+	5,  // 20: milagro.dta.v1.Issuance.GetTimePermit:output_type -> milagro.dta.v1.TimePermitResponse
+	9,  // 21: milagro.dta.v1.RPAAdmin.ListRPAs:output_type -> milagro.dta.v1.ListRPAsResponse
+	7,  // 22: milagro.dta.v1.RPAAdmin.GetRPA:output_type -> milagro.dta.v1.RelyingPartyApplication
+	7,  // 23: milagro.dta.v1.RPAAdmin.RegisterRPA:output_type -> milagro.dta.v1.RelyingPartyApplication
+	7,  // 24: milagro.dta.v1.RPAAdmin.UpdateRPA:output_type -> milagro.dta.v1.RelyingPartyApplication
+	14, // 25: milagro.dta.v1.RPAAdmin.DeleteRPA:output_type -> milagro.dta.v1.DeleteRPAResponse
+	7,  // 26: milagro.dta.v1.RPAAdmin.RollRPAKey:output_type -> milagro.dta.v1.RelyingPartyApplication
+	18, // [18:27] is the sub-list for method output_type
+	9,  // [9:18] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_dta_proto_init() }
+func file_dta_proto_init() {
+	if File_dta_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dta_proto_rawDesc), len(file_dta_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_dta_proto_goTypes,
+		DependencyIndexes: file_dta_proto_depIdxs,
+		MessageInfos:      file_dta_proto_msgTypes,
+	}.Build()
+	File_dta_proto = out.File
+	file_dta_proto_goTypes = nil
+	file_dta_proto_depIdxs = nil
+}
@@ -0,0 +1,545 @@
+//
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements. See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership. The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License. You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied. See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dta.proto
+
+package dtapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Issuance_GetServerSecret_FullMethodName = "/milagro.dta.v1.Issuance/GetServerSecret"
+	Issuance_GetClientSecret_FullMethodName = "/milagro.dta.v1.Issuance/GetClientSecret"
+	Issuance_GetTimePermit_FullMethodName   = "/milagro.dta.v1.Issuance/GetTimePermit"
+)
+
+// IssuanceClient is the client API for Issuance service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Issuance of M-Pin secret shares to relying party applications. Calls are authenticated like the REST API, with the
+// query parameters of a REST request as metadata:
+//
+//	app_id             ID of the relying party application
+//	key_id             optional ID of the application key which signed the call
+//	signature          base64 url encoded signature, not needed if a client certificate authenticates the application
+//	timestamp, nonce   required by hmac.sha256 and ed25519 signatures
+//
+// hmac.sha256 and ed25519 sign the canonical request of POST <full method name>, such as
+// /milagro.dta.v1.Issuance/GetClientSecret, with the metadata above as query and the deterministic serialization of
+// the request message as body.
+type IssuanceClient interface {
+	GetServerSecret(ctx context.Context, in *ServerSecretRequest, opts ...grpc.CallOption) (*ServerSecretResponse, error)
+	GetClientSecret(ctx context.Context, in *ClientSecretRequest, opts ...grpc.CallOption) (*ClientSecretResponse, error)
+	// Time permit for today
+	GetTimePermit(ctx context.Context, in *TimePermitRequest, opts ...grpc.CallOption) (*TimePermitResponse, error)
+}
+
+type issuanceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIssuanceClient(cc grpc.ClientConnInterface) IssuanceClient {
+	return &issuanceClient{cc}
+}
+
+func (c *issuanceClient) GetServerSecret(ctx context.Context, in *ServerSecretRequest, opts ...grpc.CallOption) (*ServerSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ServerSecretResponse)
+	err := c.cc.Invoke(ctx, Issuance_GetServerSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuanceClient) GetClientSecret(ctx context.Context, in *ClientSecretRequest, opts ...grpc.CallOption) (*ClientSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClientSecretResponse)
+	err := c.cc.Invoke(ctx, Issuance_GetClientSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuanceClient) GetTimePermit(ctx context.Context, in *TimePermitRequest, opts ...grpc.CallOption) (*TimePermitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TimePermitResponse)
+	err := c.cc.Invoke(ctx, Issuance_GetTimePermit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IssuanceServer is the server API for Issuance service.
+// All implementations must embed UnimplementedIssuanceServer
+// for forward compatibility.
+//
+// Issuance of M-Pin secret shares to relying party applications. Calls are authenticated like the REST API, with the
+// query parameters of a REST request as metadata:
+//
+//	app_id             ID of the relying party application
+//	key_id             optional ID of the application key which signed the call
+//	signature          base64 url encoded signature, not needed if a client certificate authenticates the application
+//	timestamp, nonce   required by hmac.sha256 and ed25519 signatures
+//
+// hmac.sha256 and ed25519 sign the canonical request of POST <full method name>, such as
+// /milagro.dta.v1.Issuance/GetClientSecret, with the metadata above as query and the deterministic serialization of
+// the request message as body.
+type IssuanceServer interface {
+	GetServerSecret(context.Context, *ServerSecretRequest) (*ServerSecretResponse, error)
+	GetClientSecret(context.Context, *ClientSecretRequest) (*ClientSecretResponse, error)
+	// Time permit for today
+	GetTimePermit(context.Context, *TimePermitRequest) (*TimePermitResponse, error)
+	mustEmbedUnimplementedIssuanceServer()
+}
+
+// UnimplementedIssuanceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIssuanceServer struct{}
+
+func (UnimplementedIssuanceServer) GetServerSecret(context.Context, *ServerSecretRequest) (*ServerSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServerSecret not implemented")
+}
+func (UnimplementedIssuanceServer) GetClientSecret(context.Context, *ClientSecretRequest) (*ClientSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClientSecret not implemented")
+}
+func (UnimplementedIssuanceServer) GetTimePermit(context.Context, *TimePermitRequest) (*TimePermitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTimePermit not implemented")
+}
+func (UnimplementedIssuanceServer) mustEmbedUnimplementedIssuanceServer() {}
+func (UnimplementedIssuanceServer) testEmbeddedByValue()                  {}
+
+// UnsafeIssuanceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IssuanceServer will
+// result in compilation errors.
+type UnsafeIssuanceServer interface {
+	mustEmbedUnimplementedIssuanceServer()
+}
+
+func RegisterIssuanceServer(s grpc.ServiceRegistrar, srv IssuanceServer) {
+	// If the following call pancis, it indicates UnimplementedIssuanceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Issuance_ServiceDesc, srv)
+}
+
+func _Issuance_GetServerSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServerSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuanceServer).GetServerSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Issuance_GetServerSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuanceServer).GetServerSecret(ctx, req.(*ServerSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuance_GetClientSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClientSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuanceServer).GetClientSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Issuance_GetClientSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuanceServer).GetClientSecret(ctx, req.(*ClientSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuance_GetTimePermit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TimePermitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuanceServer).GetTimePermit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Issuance_GetTimePermit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuanceServer).GetTimePermit(ctx, req.(*TimePermitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Issuance_ServiceDesc is the grpc.ServiceDesc for Issuance service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Issuance_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "milagro.dta.v1.Issuance",
+	HandlerType: (*IssuanceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetServerSecret",
+			Handler:    _Issuance_GetServerSecret_Handler,
+		},
+		{
+			MethodName: "GetClientSecret",
+			Handler:    _Issuance_GetClientSecret_Handler,
+		},
+		{
+			MethodName: "GetTimePermit",
+			Handler:    _Issuance_GetTimePermit_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dta.proto",
+}
+
+const (
+	RPAAdmin_ListRPAs_FullMethodName    = "/milagro.dta.v1.RPAAdmin/ListRPAs"
+	RPAAdmin_GetRPA_FullMethodName      = "/milagro.dta.v1.RPAAdmin/GetRPA"
+	RPAAdmin_RegisterRPA_FullMethodName = "/milagro.dta.v1.RPAAdmin/RegisterRPA"
+	RPAAdmin_UpdateRPA_FullMethodName   = "/milagro.dta.v1.RPAAdmin/UpdateRPA"
+	RPAAdmin_DeleteRPA_FullMethodName   = "/milagro.dta.v1.RPAAdmin/DeleteRPA"
+	RPAAdmin_RollRPAKey_FullMethodName  = "/milagro.dta.v1.RPAAdmin/RollRPAKey"
+)
+
+// RPAAdminClient is the client API for RPAAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Management of relying party applications. Calls need the bearer token of an admin in the authorization metadata, or
+// an admin client certificate. ListRPAs and GetRPA need the viewer role, the others the operator role. Only operators
+// get the keys from GetRPA. The service is not served when the admin API has a listener of its own.
+type RPAAdminClient interface {
+	ListRPAs(ctx context.Context, in *ListRPAsRequest, opts ...grpc.CallOption) (*ListRPAsResponse, error)
+	GetRPA(ctx context.Context, in *GetRPARequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error)
+	RegisterRPA(ctx context.Context, in *RegisterRPARequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error)
+	// Replaces the attributes of an application. revision must be the revision which was read
+	UpdateRPA(ctx context.Context, in *UpdateRPARequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error)
+	DeleteRPA(ctx context.Context, in *DeleteRPARequest, opts ...grpc.CallOption) (*DeleteRPAResponse, error)
+	// The previous keys still verify calls during server.rpa.keyGracePeriod
+	RollRPAKey(ctx context.Context, in *RollRPAKeyRequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error)
+}
+
+type rPAAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRPAAdminClient(cc grpc.ClientConnInterface) RPAAdminClient {
+	return &rPAAdminClient{cc}
+}
+
+func (c *rPAAdminClient) ListRPAs(ctx context.Context, in *ListRPAsRequest, opts ...grpc.CallOption) (*ListRPAsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRPAsResponse)
+	err := c.cc.Invoke(ctx, RPAAdmin_ListRPAs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPAAdminClient) GetRPA(ctx context.Context, in *GetRPARequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelyingPartyApplication)
+	err := c.cc.Invoke(ctx, RPAAdmin_GetRPA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPAAdminClient) RegisterRPA(ctx context.Context, in *RegisterRPARequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelyingPartyApplication)
+	err := c.cc.Invoke(ctx, RPAAdmin_RegisterRPA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPAAdminClient) UpdateRPA(ctx context.Context, in *UpdateRPARequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelyingPartyApplication)
+	err := c.cc.Invoke(ctx, RPAAdmin_UpdateRPA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPAAdminClient) DeleteRPA(ctx context.Context, in *DeleteRPARequest, opts ...grpc.CallOption) (*DeleteRPAResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteRPAResponse)
+	err := c.cc.Invoke(ctx, RPAAdmin_DeleteRPA_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPAAdminClient) RollRPAKey(ctx context.Context, in *RollRPAKeyRequest, opts ...grpc.CallOption) (*RelyingPartyApplication, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RelyingPartyApplication)
+	err := c.cc.Invoke(ctx, RPAAdmin_RollRPAKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RPAAdminServer is the server API for RPAAdmin service.
+// All implementations must embed UnimplementedRPAAdminServer
+// for forward compatibility.
+//
+// Management of relying party applications. Calls need the bearer token of an admin in the authorization metadata, or
+// an admin client certificate. ListRPAs and GetRPA need the viewer role, the others the operator role. Only operators
+// get the keys from GetRPA. The service is not served when the admin API has a listener of its own.
+type RPAAdminServer interface {
+	ListRPAs(context.Context, *ListRPAsRequest) (*ListRPAsResponse, error)
+	GetRPA(context.Context, *GetRPARequest) (*RelyingPartyApplication, error)
+	RegisterRPA(context.Context, *RegisterRPARequest) (*RelyingPartyApplication, error)
+	// Replaces the attributes of an application. revision must be the revision which was read
+	UpdateRPA(context.Context, *UpdateRPARequest) (*RelyingPartyApplication, error)
+	DeleteRPA(context.Context, *DeleteRPARequest) (*DeleteRPAResponse, error)
+	// The previous keys still verify calls during server.rpa.keyGracePeriod
+	RollRPAKey(context.Context, *RollRPAKeyRequest) (*RelyingPartyApplication, error)
+	mustEmbedUnimplementedRPAAdminServer()
+}
+
+// UnimplementedRPAAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRPAAdminServer struct{}
+
+func (UnimplementedRPAAdminServer) ListRPAs(context.Context, *ListRPAsRequest) (*ListRPAsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRPAs not implemented")
+}
+func (UnimplementedRPAAdminServer) GetRPA(context.Context, *GetRPARequest) (*RelyingPartyApplication, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRPA not implemented")
+}
+func (UnimplementedRPAAdminServer) RegisterRPA(context.Context, *RegisterRPARequest) (*RelyingPartyApplication, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterRPA not implemented")
+}
+func (UnimplementedRPAAdminServer) UpdateRPA(context.Context, *UpdateRPARequest) (*RelyingPartyApplication, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRPA not implemented")
+}
+func (UnimplementedRPAAdminServer) DeleteRPA(context.Context, *DeleteRPARequest) (*DeleteRPAResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRPA not implemented")
+}
+func (UnimplementedRPAAdminServer) RollRPAKey(context.Context, *RollRPAKeyRequest) (*RelyingPartyApplication, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RollRPAKey not implemented")
+}
+func (UnimplementedRPAAdminServer) mustEmbedUnimplementedRPAAdminServer() {}
+func (UnimplementedRPAAdminServer) testEmbeddedByValue()                  {}
+
+// UnsafeRPAAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RPAAdminServer will
+// result in compilation errors.
+type UnsafeRPAAdminServer interface {
+	mustEmbedUnimplementedRPAAdminServer()
+}
+
+func RegisterRPAAdminServer(s grpc.ServiceRegistrar, srv RPAAdminServer) {
+	// If the following call pancis, it indicates UnimplementedRPAAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RPAAdmin_ServiceDesc, srv)
+}
+
+func _RPAAdmin_ListRPAs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRPAsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPAAdminServer).ListRPAs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RPAAdmin_ListRPAs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPAAdminServer).ListRPAs(ctx, req.(*ListRPAsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPAAdmin_GetRPA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRPARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPAAdminServer).GetRPA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RPAAdmin_GetRPA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPAAdminServer).GetRPA(ctx, req.(*GetRPARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPAAdmin_RegisterRPA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRPARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPAAdminServer).RegisterRPA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RPAAdmin_RegisterRPA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPAAdminServer).RegisterRPA(ctx, req.(*RegisterRPARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPAAdmin_UpdateRPA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRPARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPAAdminServer).UpdateRPA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RPAAdmin_UpdateRPA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPAAdminServer).UpdateRPA(ctx, req.(*UpdateRPARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPAAdmin_DeleteRPA_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRPARequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPAAdminServer).DeleteRPA(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RPAAdmin_DeleteRPA_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPAAdminServer).DeleteRPA(ctx, req.(*DeleteRPARequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPAAdmin_RollRPAKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollRPAKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPAAdminServer).RollRPAKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RPAAdmin_RollRPAKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPAAdminServer).RollRPAKey(ctx, req.(*RollRPAKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RPAAdmin_ServiceDesc is the grpc.ServiceDesc for RPAAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RPAAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "milagro.dta.v1.RPAAdmin",
+	HandlerType: (*RPAAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRPAs",
+			Handler:    _RPAAdmin_ListRPAs_Handler,
+		},
+		{
+			MethodName: "GetRPA",
+			Handler:    _RPAAdmin_GetRPA_Handler,
+		},
+		{
+			MethodName: "RegisterRPA",
+			Handler:    _RPAAdmin_RegisterRPA_Handler,
+		},
+		{
+			MethodName: "UpdateRPA",
+			Handler:    _RPAAdmin_UpdateRPA_Handler,
+		},
+		{
+			MethodName: "DeleteRPA",
+			Handler:    _RPAAdmin_DeleteRPA_Handler,
+		},
+		{
+			MethodName: "RollRPAKey",
+			Handler:    _RPAAdmin_RollRPAKey_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dta.proto",
+}
@@ -0,0 +1,60 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package api
+
+//Codes of ErrorResponse
+const (
+	//The request misses an argument or has an invalid one
+	ErrorInvalidRequest = "invalid_request"
+	//The request is not authenticated: its signature, client certificate or admin credentials are invalid
+	ErrorUnauthorized = "unauthorized"
+	//The request is authenticated but the operation is not allowed
+	ErrorForbidden = "forbidden"
+	//An identity policy rejects the client ID. Details has the policy and the reason
+	ErrorPolicyViolation = "policy_violation"
+	//The client identity is revoked
+	ErrorRevoked = "revoked"
+	//The relying party application or another resource does not exist
+	ErrorNotFound = "not_found"
+	//The resource already exists or was changed concurrently
+	ErrorConflict = "conflict"
+	//The route exists but not for the method of the request
+	ErrorMethodNotAllowed = "method_not_allowed"
+	//The storage of the D-TA is unavailable. The request can be retried
+	ErrorUnavailable = "unavailable"
+	//Failure of the D-TA
+	ErrorInternal = "internal_error"
+)
+
+//Body of every error response of the API
+//       JSON response
+//		{
+//			"code" : "<one of the Error codes>",
+//			"message" : "<description of the error>",
+//			"request_id" : "<same as the X-Request-ID header of the response>",
+//			"details" : {"<name>" : "<value>"}
+//		}
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+	//Optional details of the error such as the policy and the reason of ErrorPolicyViolation
+	Details map[string]string `json:"details,omitempty"`
+}
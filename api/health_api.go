@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package api
+
+//Statuses of HealthResponse, ReadinessResponse and ReadinessCheck
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+//Names of the checks of ReadinessResponse
+const (
+	//A master secret is loaded and issues secrets
+	CheckMasterSecret = "master_secret"
+	//The RPA storage answers
+	CheckRPAStorage = "rpa_storage"
+	//The random number generator of the D-TA is seeded
+	CheckRNG = "rng"
+)
+
+//Body of /healthz
+//       JSON response
+//		{
+//			"status" : "ok"
+//		}
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+//Body of /readyz. Status is ok only if every check is ok
+//       JSON response
+//		{
+//			"status" : "ok|unavailable",
+//			"checks" : {
+//				"master_secret" : {"status" : "ok"},
+//				"rpa_storage" : {"status" : "unavailable", "message" : "<why the check failed>"},
+//				"rng" : {"status" : "ok"}
+//			}
+//		}
+type ReadinessResponse struct {
+	Status string                    `json:"status"`
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+type ReadinessCheck struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
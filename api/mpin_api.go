@@ -18,17 +18,64 @@
  */
 package api
 
+//Signature and SigningKeyID of the issuance responses are described in signature.IssuanceMessage. The key is
+//published in KeysResponse
 type ServerSecretResponse struct {
 	ServerSecret string
+	KeyVersion   int
+	Signature    string
+	SigningKeyID string
 	Message      string
 }
 
 type ClientSecretResponse struct {
 	ClientSecret string
+	KeyVersion   int
+	Signature    string
+	SigningKeyID string
 	Message      string
 }
 
 type TimePermitResponse struct {
 	TimePermit string
+	//Days since 1970-01-01 for which the time permit is issued
+	EpochDay     int
+	KeyVersion   int
+	Signature    string
+	SigningKeyID string
+	Message      string
+}
+
+type DatedTimePermit struct {
+	//Date in YYYY-MM-DD format (UTC)
+	Date string
+	//Days since 1970-01-01 which is used by M-Pin as the time permit date
+	EpochDay   int
+	TimePermit string
+	Signature  string
+}
+
+type TimePermitsResponse struct {
+	TimePermits  []DatedTimePermit
+	KeyVersion   int
+	SigningKeyID string
+	Message      string
+}
+
+//Key verifying the signatures of issuance responses
+type VerificationKey struct {
+	KeyID     string
+	Algorithm string
+	//Base64 url encoded public key
+	PublicKey string
+}
+
+type KeysResponse struct {
+	Keys    []VerificationKey
+	Message string
+}
+
+type MasterSecretRotationResponse struct {
+	KeyVersion int
 	Message    string
 }
@@ -18,11 +18,86 @@
  */
 package api
 
+import "time"
+
 type RelyingPartyApplicationResponse struct {
-	Application_ID  string
-	Application_KEY string
+	Application_ID string
+	//The newest valid key, which the application should sign its requests with. It is the public key of applications
+	//with Ed25519 keys. Only operators get the keys
+	Application_KEY string `json:",omitempty"`
+	KeyType         string
+	//Every key which is not expired, oldest first
+	Keys                   []RPAKeyResponse
+	DisplayName            string `json:",omitempty"`
+	OwnerContact           string `json:",omitempty"`
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	Disabled               bool
+	AllowedOperations      []string `json:",omitempty"`
+	MaxPermitRange         int      `json:",omitempty"`
+	AllowedIdentityDomains []string `json:",omitempty"`
+	//Hex SHA-256 fingerprints of the client certificates which authenticate the application over mutual TLS
+	CertificateFingerprints []string          `json:",omitempty"`
+	Metadata                map[string]string `json:",omitempty"`
+	Revision                int64
 }
 
 type RelyingPartyApplicationRequest struct {
 	Application_ID string
+	//shared, the default, or ed25519. Ignored by PUT /rpa/{appid}
+	KeyType string `json:",omitempty"`
+	//Base64 url encoded Ed25519 public key of the application. Required if KeyType is ed25519
+	PublicKey               string `json:",omitempty"`
+	DisplayName             string
+	OwnerContact            string
+	Disabled                bool
+	AllowedOperations       []string
+	MaxPermitRange          int
+	AllowedIdentityDomains  []string
+	CertificateFingerprints []string
+	Metadata                map[string]string
+	//Revision which is updated. Required by PUT /rpa/{appid}
+	Revision int64 `json:",omitempty"`
+}
+
+//Changes the attributes which are given and keeps the others
+type RelyingPartyApplicationPatch struct {
+	DisplayName             *string
+	OwnerContact            *string
+	Disabled                *bool
+	AllowedOperations       *[]string
+	MaxPermitRange          *int
+	AllowedIdentityDomains  *[]string
+	CertificateFingerprints *[]string
+	Metadata                *map[string]string
+	//Revision which is patched. The current revision is patched if it is not given
+	Revision int64 `json:",omitempty"`
+}
+
+type RPAKeyResponse struct {
+	KeyID string
+	//Only operators get the keys
+	Key       string `json:",omitempty"`
+	NotBefore time.Time
+	//Not set if the key does not expire
+	ExpiresAt *time.Time `json:",omitempty"`
+}
+
+type RPAKeyRequest struct {
+	//The new key is valid from now if it is not set
+	NotBefore time.Time
+	//Base64 url encoded new Ed25519 public key. Required for applications with Ed25519 keys
+	PublicKey string `json:",omitempty"`
+}
+
+type RevocationRequest struct {
+	ClientID string
+	Reason   string
+}
+
+type RevocationResponse struct {
+	ClientID  string
+	Reason    string
+	RevokedAt time.Time
+	Message   string
 }
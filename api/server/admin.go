@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/config"
+)
+
+//Roles of the admin API. Viewers read relying party applications and revocations, operators also change them and
+//rotate the master secret
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+)
+
+//Authenticates the requests of the admin API by bearer token or client certificate
+type adminAuthenticator struct {
+	//Roles by the hex SHA-256 of the tokens
+	tokens map[string]adminCredential
+	//Roles by the names of the client certificates
+	certificates map[string]adminCredential
+	//Every request is allowed as an operator. Only in dev mode when no credentials are configured
+	open bool
+}
+
+type adminCredential struct {
+	name string
+	role string
+}
+
+//Key of the adminCredential in the context of a request served by requireRole
+type adminCredentialKey struct{}
+
+//Returns the credential which requireRole or the gRPC interceptor authenticated for the request
+func adminCredentialFrom(ctx context.Context) (adminCredential, bool) {
+	credential, ok := ctx.Value(adminCredentialKey{}).(adminCredential)
+	return credential, ok
+}
+
+//Returns the hex SHA-256 of an admin token, as in server.admin.tokens
+func HashAdminToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func newAdminAuthenticator(conf config.Config) (*adminAuthenticator, error) {
+	authenticator := &adminAuthenticator{
+		tokens:       make(map[string]adminCredential),
+		certificates: make(map[string]adminCredential),
+	}
+	for _, token := range conf.GetAdminTokens() {
+		if err := checkRole(token.Role); err != nil {
+			return nil, fmt.Errorf("Admin token %s: %s", token.Name, err.Error())
+		}
+		if decoded, err := hex.DecodeString(token.SHA256); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("Admin token %s: sha256 must be the hex SHA-256 of the token", token.Name)
+		}
+		authenticator.tokens[strings.ToLower(token.SHA256)] = adminCredential{name: token.Name, role: token.Role}
+	}
+	for _, certificate := range conf.GetAdminCertificates() {
+		if err := checkRole(certificate.Role); err != nil {
+			return nil, fmt.Errorf("Admin certificate %s: %s", certificate.Name, err.Error())
+		}
+		authenticator.certificates[certificate.Name] = adminCredential{name: certificate.Name, role: certificate.Role}
+	}
+	if len(authenticator.tokens) == 0 && len(authenticator.certificates) == 0 {
+		if !conf.IsDevMode() {
+			log.Println("No admin credentials are configured. The admin API refuses every request")
+			return authenticator, nil
+		}
+		log.Println("WARNING: No admin credentials are configured. The admin API is open in dev mode")
+		authenticator.open = true
+	}
+	return authenticator, nil
+}
+
+func checkRole(role string) error {
+	if role != RoleViewer && role != RoleOperator {
+		return fmt.Errorf("Unknown role %q", role)
+	}
+	return nil
+}
+
+//Returns the credential of the request. A bearer token is tried first, then the verified client certificate
+func (authenticator *adminAuthenticator) authenticate(r *http.Request) (adminCredential, bool) {
+	if authenticator.open {
+		return adminCredential{name: "dev mode", role: RoleOperator}, true
+	}
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		credential, ok := authenticator.tokens[HashAdminToken(strings.TrimPrefix(authorization, "Bearer "))]
+		return credential, ok
+	}
+	if certificate := clientCertificate(r); certificate != nil {
+		for _, name := range certificateNames(certificate) {
+			if credential, ok := authenticator.certificates[name]; ok {
+				return credential, true
+			}
+		}
+	}
+	return adminCredential{}, false
+}
+
+//Returns true if the role has the permissions of the required role
+func (credential adminCredential) allows(role string) bool {
+	return credential.role == RoleOperator || credential.role == role
+}
+
+//Wraps an admin handler so that it is served only to requests with the role. The handler gets the credential with
+//adminCredentialFrom
+//	Status-Codes and Error Codes
+//		Status-Code          Code                 Message
+//		401                  unauthorized         Admin credentials are required
+//		403                  forbidden            Role [role] is required
+func (apiServer *ApiServer) requireRole(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		credential, ok := apiServer.admin.authenticate(r)
+		if !ok {
+			log.Println("Admin request without valid credentials ", r.Method, " ", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dta-admin"`)
+			sendError(w, r, http.StatusUnauthorized, api.ErrorUnauthorized, "Admin credentials are required")
+			return
+		}
+		if !credential.allows(role) {
+			log.Println("Admin ", credential.name, " is not allowed to ", r.Method, " ", r.URL.Path)
+			sendError(w, r, http.StatusForbidden, api.ErrorForbidden, "Role "+role+" is required")
+			return
+		}
+		log.Println("Admin ", credential.name, " ", r.Method, " ", r.URL.Path)
+		handler(w, r.WithContext(context.WithValue(r.Context(), adminCredentialKey{}, credential)))
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/gorilla/mux"
+)
+
+func TestRequireRole(t *testing.T) {
+	conf := config.Config{}
+	conf.SetAdminTokens([]config.AdminTokenConfig{
+		{Name: "viewer", SHA256: HashAdminToken("viewer-token"), Role: RoleViewer},
+		{Name: "operator", SHA256: HashAdminToken("operator-token"), Role: RoleOperator},
+	})
+	conf.SetAdminCertificates([]config.AdminCertificateConfig{{Name: "admin.example.org", Role: RoleViewer}})
+	admin, err := newAdminAuthenticator(conf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer := ApiServer{admin: admin}
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+
+	serve := func(role string, configure func(r *http.Request)) int {
+		request := httptest.NewRequest(http.MethodGet, "/rpas", nil)
+		configure(request)
+		recorder := httptest.NewRecorder()
+		apiServer.requireRole(role, handler)(recorder, request)
+		return recorder.Code
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	certificate := func(r *http.Request) {
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "admin.example.org"}}}}}
+	}
+
+	expected := []struct {
+		role      string
+		configure func(r *http.Request)
+		status    int
+	}{
+		{RoleViewer, func(r *http.Request) {}, http.StatusUnauthorized},
+		{RoleViewer, bearer("unknown-token"), http.StatusUnauthorized},
+		{RoleViewer, bearer("viewer-token"), http.StatusOK},
+		{RoleOperator, bearer("viewer-token"), http.StatusForbidden},
+		{RoleViewer, bearer("operator-token"), http.StatusOK},
+		{RoleOperator, bearer("operator-token"), http.StatusOK},
+		{RoleViewer, certificate, http.StatusOK},
+		{RoleOperator, certificate, http.StatusForbidden},
+	}
+	for i, test := range expected {
+		if status := serve(test.role, test.configure); status != test.status {
+			t.Errorf("Request %d for role %s should return %d but got %d", i, test.role, test.status, status)
+		}
+	}
+}
+
+func TestGetRPAHandler_Keys(t *testing.T) {
+	conf := config.Config{}
+	conf.SetAdminTokens([]config.AdminTokenConfig{
+		{Name: "viewer", SHA256: HashAdminToken("viewer-token"), Role: RoleViewer},
+		{Name: "operator", SHA256: HashAdminToken("operator-token"), Role: RoleOperator},
+	})
+	admin, err := newAdminAuthenticator(conf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	if _, err := appStorage.RegisterRPA(context.Background(), storage.RelyingPartyApplication{Application_ID: "appid0001"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer := ApiServer{admin: admin, appStorage: appStorage}
+
+	getRPA := func(token string) api.RelyingPartyApplicationResponse {
+		request := httptest.NewRequest(http.MethodGet, "/rpa/appid0001", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		request = mux.SetURLVars(request, map[string]string{"appid": "appid0001"})
+		recorder := httptest.NewRecorder()
+		apiServer.requireRole(RoleViewer, apiServer.getRPAHandler)(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatal("Reading the app should succeed but got ", recorder.Code)
+		}
+		response := api.RelyingPartyApplicationResponse{}
+		json.NewDecoder(recorder.Body).Decode(&response)
+		return response
+	}
+
+	viewed := getRPA("viewer-token")
+	if viewed.Application_KEY != "" || len(viewed.Keys) != 1 || viewed.Keys[0].Key != "" {
+		t.Error("Viewers should not get the keys of the app ", viewed)
+	}
+	if viewed.Keys[0].KeyID != "1" || viewed.Keys[0].NotBefore.IsZero() {
+		t.Error("Viewers should get the IDs and validity of the keys ", viewed.Keys)
+	}
+	operated := getRPA("operator-token")
+	if operated.Application_KEY == "" || operated.Keys[0].Key != operated.Application_KEY {
+		t.Error("Operators should get the keys of the app ", operated)
+	}
+}
+
+func TestNewAdminAuthenticator(t *testing.T) {
+	conf := config.Config{}
+	if admin, err := newAdminAuthenticator(conf); err != nil || admin.open {
+		t.Error("Admin API should not be open without credentials")
+	}
+	conf.SetDevMode(true)
+	if admin, err := newAdminAuthenticator(conf); err != nil || !admin.open {
+		t.Error("Admin API should be open in dev mode without credentials")
+	}
+	conf.SetAdminTokens([]config.AdminTokenConfig{{Name: "ops", SHA256: HashAdminToken("token"), Role: "admin"}})
+	if _, err := newAdminAuthenticator(conf); err == nil {
+		t.Error("Unknown role should be refused")
+	}
+	conf.SetAdminTokens([]config.AdminTokenConfig{{Name: "ops", SHA256: "token", Role: RoleOperator}})
+	if _, err := newAdminAuthenticator(conf); err == nil {
+		t.Error("Token which is not hashed should be refused")
+	}
+}
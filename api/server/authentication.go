@@ -0,0 +1,226 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/authserver"
+	"github.com/ajanthan/apache-milagro-dta/client"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/pkg/errors"
+)
+
+//How long server secrets recombined from other D-TAs are reused
+const remoteServerSecretTTL = time.Minute
+
+//Creates the authentication server with the server secrets recombined from the configured D-TAs, or the server secrets
+//of this D-TA if none is configured
+func (apiServer *ApiServer) newAuthenticationServer(conf config.Config) (*authserver.AuthenticationServer, error) {
+	var serverSecrets authserver.ServerSecretSource
+	dtas := conf.GetAuthenticationDTAs()
+	if len(dtas) == 0 {
+		log.Println("Using the server secret of this D-TA for authentication")
+		serverSecrets = func(keyVersion int) ([]byte, error) {
+			if keyVersion == 0 {
+				keyVersion = apiServer.dTA.ActiveKeyVersion()
+			}
+			return apiServer.dTA.IssueServerSecretWithVersion(keyVersion)
+		}
+	} else {
+		var endpoints []client.DTAEndpoint
+		for _, dtaConfig := range dtas {
+			appKey, decodeErr := base64.URLEncoding.DecodeString(dtaConfig.AppKey)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("Invalid app key for %s", dtaConfig.URL)
+			}
+			verificationKey, decodeErr := base64.URLEncoding.DecodeString(dtaConfig.VerificationKey)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("Invalid verification key for %s", dtaConfig.URL)
+			}
+			endpoints = append(endpoints, client.DTAEndpoint{URL: dtaConfig.URL, AppID: dtaConfig.AppID, AppKey: appKey, AppKeyID: dtaConfig.AppKeyID, SignatureAlgorithm: dtaConfig.SignatureAlgorithm, VerificationKey: verificationKey})
+		}
+		log.Println("Getting the server secret for authentication from ", len(endpoints), " D-TAs")
+		serverSecrets = newServerSecretCache(client.NewMultiDTAClient(endpoints).GetServerSecretWithVersion, remoteServerSecretTTL).get
+	}
+	//Fail at start up rather than in the first authentication if the server secret cannot be had
+	if _, err := serverSecrets(0); err != nil {
+		return nil, errors.Wrap(err, "Error in getting server secret for authentication")
+	}
+	return authserver.NewAuthenticationServer(serverSecrets, conf.GetAuthenticationSessionTimeout(), conf.GetAuthenticationMaxSessions())
+}
+
+//Keeps the server secrets fetched from other D-TAs for a while, so that second passes do not each fetch them while a
+//rotated master secret is still picked up
+type serverSecretCache struct {
+	fetch authserver.ServerSecretSource
+	ttl   time.Duration
+
+	lock    sync.Mutex
+	secrets map[int]cachedServerSecret
+}
+
+type cachedServerSecret struct {
+	secret    []byte
+	fetchedAt time.Time
+}
+
+func newServerSecretCache(fetch authserver.ServerSecretSource, ttl time.Duration) *serverSecretCache {
+	return &serverSecretCache{fetch: fetch, ttl: ttl, secrets: make(map[int]cachedServerSecret)}
+}
+
+//Returns the cached server secret of keyVersion, or fetches it if it is not cached or older than the TTL
+func (cache *serverSecretCache) get(keyVersion int) ([]byte, error) {
+	cache.lock.Lock()
+	cached, ok := cache.secrets[keyVersion]
+	cache.lock.Unlock()
+	if ok && time.Since(cached.fetchedAt) < cache.ttl {
+		return cached.secret, nil
+	}
+	secret, err := cache.fetch(keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.secrets[keyVersion] = cachedServerSecret{secret: secret, fetchedAt: time.Now()}
+	return secret, nil
+}
+
+//First pass of M-Pin authentication
+//	URL structure
+//		/v1/authenticate/pass1
+//	HTTP Request Method
+//		POST
+//	Parameters
+//       JSON request
+//		{
+//			"ClientID" : "<M-Pin client ID>",
+//			"U" : "<base64 url encoded U>",
+//			"UT" : "<base64 url encoded UT. Empty if time permits are not used>",
+//			"KeyVersion" : <optional master secret version of the client secret. The active version is used by default>
+//		}
+//	Returns
+//	Starts an authentication session and returns the challenge Y in this JSON object
+//       JSON response
+//		{
+//			"Message" : "OK",
+//			"SessionID" : "<session ID>",
+//			"Y" : "<base64 url encoded Y>"
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Invalid authentication input
+//		503                  Too many authentication sessions
+func (apiServer *ApiServer) authenticationPass1Handler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving post /authenticate/pass1")
+	log.Println(r.UserAgent())
+
+	var request api.AuthenticationPass1Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Println("Error while decoding input", err.Error())
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, authserver.ErrInvalidInput.Error())
+		return
+	}
+	u, uErr := base64.URLEncoding.DecodeString(request.U)
+	ut, utErr := base64.URLEncoding.DecodeString(request.UT)
+	if uErr != nil || utErr != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, authserver.ErrInvalidInput.Error())
+		return
+	}
+
+	sessionID, y, err := apiServer.authServer.Pass1(request.ClientID, u, ut, request.KeyVersion)
+	if err != nil {
+		log.Println("Authentication pass 1 failed ", err.Error())
+		if err == authserver.ErrTooManySessions {
+			sendError(w, r, http.StatusServiceUnavailable, api.ErrorUnavailable, err.Error())
+			return
+		}
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.AuthenticationPass1Response{SessionID: sessionID, Y: base64.URLEncoding.EncodeToString(y), Message: "OK"})
+}
+
+//Second pass of M-Pin authentication
+//	URL structure
+//		/v1/authenticate/pass2
+//	HTTP Request Method
+//		POST
+//	Parameters
+//       JSON request
+//		{
+//			"SessionID" : "<session ID returned from pass 1>",
+//			"V" : "<base64 url encoded V>"
+//		}
+//	Returns
+//	Completes the authentication session and returns the result in this JSON object
+//       JSON response
+//		{
+//			"Message" : "OK",
+//			"Authenticated" : true
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Invalid authentication input
+//		401                  Unknown or expired authentication session
+//		401                  Authentication failed
+//		503                  Error in getting server secret
+func (apiServer *ApiServer) authenticationPass2Handler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving post /authenticate/pass2")
+	log.Println(r.UserAgent())
+
+	var request api.AuthenticationPass2Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Println("Error while decoding input", err.Error())
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, authserver.ErrInvalidInput.Error())
+		return
+	}
+	v, err := base64.URLEncoding.DecodeString(request.V)
+	if err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, authserver.ErrInvalidInput.Error())
+		return
+	}
+
+	if err := apiServer.authServer.Pass2(request.SessionID, v); err != nil {
+		log.Println("Authentication pass 2 failed ", err.Error())
+		switch errors.Cause(err) {
+		case authserver.ErrInvalidInput:
+			sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		case authserver.ErrUnknownSession, authserver.ErrAuthenticationFailed:
+			sendError(w, r, http.StatusUnauthorized, api.ErrorUnauthorized, errors.Cause(err).Error())
+		default:
+			sendError(w, r, http.StatusServiceUnavailable, api.ErrorUnavailable, "Error in getting server secret")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.AuthenticationPass2Response{Authenticated: true, Message: "OK"})
+}
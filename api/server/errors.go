@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/gorilla/mux"
+)
+
+//Prefix of the routes of the current API version. The same routes at the root are deprecated aliases
+const apiVersion = "/v1"
+
+//Header with the ID of a request. An ID sent by the caller is kept so that it can correlate its logs with the D-TA
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+//Creates a router which answers unknown routes and methods with the error envelope
+func newRouter() *mux.Router {
+	router := mux.NewRouter().StrictSlash(true)
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+	return router
+}
+
+//Adds the routes under /v1 and again at the root for clients of the unversioned API. Responses of the root routes
+//have a Deprecation header and link to their /v1 route
+func handleVersioned(router *mux.Router, handle func(router *mux.Router)) {
+	handle(router.PathPrefix(apiVersion).Subrouter())
+	aliases := router.NewRoute().Subrouter()
+	aliases.Use(deprecatedAlias)
+	handle(aliases)
+}
+
+func deprecatedAlias(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+apiVersion+r.URL.Path+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+//Gives every request an ID which is returned in the X-Request-ID header and in error responses
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+//Returns the ID given to the request by withRequestID
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+//Sends the error envelope with the status. Handlers must return after it
+func sendError(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	sendErrorResponse(w, r, status, api.ErrorResponse{Code: code, Message: message})
+}
+
+//A refused request with the HTTP status and the error envelope it is sent with. The gRPC services map the status to a
+//gRPC code
+type requestError struct {
+	status   int
+	response api.ErrorResponse
+}
+
+func newRequestError(status int, code string, message string) *requestError {
+	return &requestError{status: status, response: api.ErrorResponse{Code: code, Message: message}}
+}
+
+func (err *requestError) Error() string {
+	return err.response.Message
+}
+
+func sendRequestError(w http.ResponseWriter, r *http.Request, err *requestError) {
+	sendErrorResponse(w, r, err.status, err.response)
+}
+
+func sendErrorResponse(w http.ResponseWriter, r *http.Request, status int, response api.ErrorResponse) {
+	response.RequestID = requestID(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	sendError(w, r, http.StatusNotFound, api.ErrorNotFound, "No route for "+r.URL.Path)
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	sendError(w, r, http.StatusMethodNotAllowed, api.ErrorMethodNotAllowed, "Method "+r.Method+" is not allowed for "+r.URL.Path)
+}
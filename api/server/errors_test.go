@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/gorilla/mux"
+)
+
+func decodeErrorResponse(t *testing.T, recorder *httptest.ResponseRecorder) api.ErrorResponse {
+	var response api.ErrorResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err.Error())
+	}
+	if response.RequestID == "" || response.RequestID != recorder.Header().Get(requestIDHeader) {
+		t.Errorf("Error response should have the request ID %q but got %q", recorder.Header().Get(requestIDHeader), response.RequestID)
+	}
+	return response
+}
+
+func TestAuthorizeIssuance(t *testing.T) {
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	rpa, err := appStorage.RegisterRPA(context.Background(), storage.RelyingPartyApplication{Application_ID: "appid0001"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer := ApiServer{signatureVerifier: signature.AESSignatureVerifier{}, appStorage: appStorage}
+	validSignature := base64.URLEncoding.EncodeToString(signature.CreateSignature(rpa.Application_KEY, rpa.Application_ID))
+	invalidSignature := base64.URLEncoding.EncodeToString(signature.CreateSignature(make([]byte, 16), rpa.Application_ID))
+
+	expected := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"client_id=user@apache.org&signature=" + validSignature, http.StatusBadRequest, api.ErrorInvalidRequest},
+		{"app_id=appid0001&signature=" + validSignature, http.StatusBadRequest, api.ErrorInvalidRequest},
+		{"app_id=appid0001&client_id=user@apache.org&signature=%25%25", http.StatusBadRequest, api.ErrorInvalidRequest},
+		{"app_id=appid0002&client_id=user@apache.org&signature=" + validSignature, http.StatusNotFound, api.ErrorNotFound},
+		{"app_id=appid0001&client_id=user@apache.org&signature=" + invalidSignature, http.StatusUnauthorized, api.ErrorUnauthorized},
+		{"app_id=appid0001&client_id=user@apache.org&signature=" + validSignature, http.StatusOK, ""},
+	}
+	for i, test := range expected {
+		authorized := false
+		handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, authorized = apiServer.authorizeIssuance(w, r, storage.OperationClientSecret, "client_id")
+		}))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/clientSecret?"+test.query, nil))
+		if authorized != (test.status == http.StatusOK) || recorder.Code != test.status {
+			t.Errorf("Request %d should return %d but got %d, authorized %v", i, test.status, recorder.Code, authorized)
+			continue
+		}
+		if test.status != http.StatusOK {
+			if response := decodeErrorResponse(t, recorder); response.Code != test.code {
+				t.Errorf("Request %d should return error code %s but got %s", i, test.code, response.Code)
+			}
+		}
+	}
+
+	rpa.AllowedIdentityDomains = []string{"apache.org"}
+	rpa, err = appStorage.UpdateRPA(context.Background(), rpa)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	recorder := httptest.NewRecorder()
+	withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiServer.authorizeIssuance(w, r, storage.OperationClientSecret, "client_id")
+	})).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/clientSecret?app_id=appid0001&client_id=user@example.com&signature="+validSignature, nil))
+	if recorder.Code != http.StatusForbidden {
+		t.Fatal("Client ID outside of the allowed domains should be refused but got ", recorder.Code)
+	}
+	if response := decodeErrorResponse(t, recorder); response.Code != api.ErrorPolicyViolation || response.Details["policy"] == "" {
+		t.Errorf("Policy violation should be returned with its policy but got %+v", response)
+	}
+}
+
+func TestHandleVersioned(t *testing.T) {
+	router := newRouter()
+	handleVersioned(router, func(router *mux.Router) {
+		router.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	})
+	handler := withRequestID(router)
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set(requestIDHeader, "test-request-1")
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	if recorder := serve(http.MethodGet, "/v1/keys"); recorder.Code != http.StatusOK || recorder.Header().Get("Deprecation") != "" {
+		t.Errorf("/v1/keys should be served without deprecation but got %d %q", recorder.Code, recorder.Header().Get("Deprecation"))
+	}
+	recorder := serve(http.MethodGet, "/keys")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Deprecation") != "true" {
+		t.Errorf("/keys should be served as a deprecated alias but got %d %q", recorder.Code, recorder.Header().Get("Deprecation"))
+	}
+	if link := recorder.Header().Get("Link"); link != `</v1/keys>; rel="successor-version"` {
+		t.Error("Deprecated alias should link to its /v1 route but got ", link)
+	}
+	if recorder.Header().Get(requestIDHeader) != "test-request-1" {
+		t.Error("Request ID of the caller should be kept but got ", recorder.Header().Get(requestIDHeader))
+	}
+
+	recorder = serve(http.MethodGet, "/v1/unknown")
+	if recorder.Code != http.StatusNotFound || decodeErrorResponse(t, recorder).Code != api.ErrorNotFound {
+		t.Error("Unknown route should return the error envelope with 404 but got ", recorder.Code)
+	}
+	recorder = serve(http.MethodPost, "/v1/keys")
+	if recorder.Code != http.StatusMethodNotAllowed || decodeErrorResponse(t, recorder).Code != api.ErrorMethodNotAllowed {
+		t.Error("Unknown method should return the error envelope with 405 but got ", recorder.Code)
+	}
+}
+
+func TestStorageError(t *testing.T) {
+	for kind, expected := range map[error]int{
+		storage.ErrNotFound:      http.StatusNotFound,
+		storage.ErrAlreadyExists: http.StatusConflict,
+		storage.ErrConflict:      http.StatusConflict,
+		storage.ErrUnavailable:   http.StatusServiceUnavailable,
+		storage.ErrInvalid:       http.StatusBadRequest,
+	} {
+		if err := storageError(kind); err.status != expected {
+			t.Errorf("%s should be sent as %d but got %d", kind, expected, err.status)
+		}
+	}
+}
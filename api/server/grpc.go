@@ -0,0 +1,424 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/api/dtapb"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-cgo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//Domain of the ErrorInfo details of gRPC errors. Their reason is the code of the error envelope of the HTTP API
+const grpcErrorDomain = "milagro.dta"
+
+//Storage operations which the relying party application must be allowed for the issuance methods
+var grpcIssuanceOperations = map[string]string{
+	dtapb.Issuance_GetServerSecret_FullMethodName: storage.OperationServerSecret,
+	dtapb.Issuance_GetClientSecret_FullMethodName: storage.OperationClientSecret,
+	dtapb.Issuance_GetTimePermit_FullMethodName:   storage.OperationTimePermit,
+}
+
+//Roles which the admin methods require, the same as the HTTP admin API
+var grpcAdminRoles = map[string]string{
+	dtapb.RPAAdmin_ListRPAs_FullMethodName:    RoleViewer,
+	dtapb.RPAAdmin_GetRPA_FullMethodName:      RoleViewer,
+	dtapb.RPAAdmin_RegisterRPA_FullMethodName: RoleOperator,
+	dtapb.RPAAdmin_UpdateRPA_FullMethodName:   RoleOperator,
+	dtapb.RPAAdmin_DeleteRPA_FullMethodName:   RoleOperator,
+	dtapb.RPAAdmin_RollRPAKey_FullMethodName:  RoleOperator,
+}
+
+//Metadata of issuance calls which take the place of the query parameters of the HTTP API
+var grpcQueryMetadata = []string{"app_id", "key_id", signature.SignatureParam, signature.TimestampParam, signature.NonceParam}
+
+type grpcRPAKey struct{}
+
+//Issuance service over the D-TA of the server. The interceptor has authorized the calls
+type grpcIssuanceServer struct {
+	dtapb.UnimplementedIssuanceServer
+	apiServer *ApiServer
+}
+
+//RPA administration service over the RPA storage of the server. The interceptor has checked the role of the admin
+type grpcAdminServer struct {
+	dtapb.UnimplementedRPAAdminServer
+	apiServer *ApiServer
+}
+
+//Creates the gRPC server with the issuance service. The RPA administration service is only served with withAdmin,
+//so that it is not reachable on the gRPC port when the admin API has a listener of its own
+func (apiServer *ApiServer) newGRPCServer(withAdmin bool, options ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(options, grpc.UnaryInterceptor(apiServer.grpcAuthInterceptor))...)
+	dtapb.RegisterIssuanceServer(server, &grpcIssuanceServer{apiServer: apiServer})
+	if withAdmin {
+		dtapb.RegisterRPAAdminServer(server, &grpcAdminServer{apiServer: apiServer})
+	}
+	return server
+}
+
+//Applies the checks of the HTTP API to gRPC calls. Issuance calls are authorized by authorizeApp, which gets the
+//relying party application from the metadata, and admin calls need the credentials and roles of the HTTP admin API
+func (apiServer *ApiServer) grpcAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	log.Println("serving gRPC ", info.FullMethod)
+	r, err := grpcHTTPRequest(ctx, info.FullMethod, req)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, status.Error(codes.InvalidArgument, "Invalid request")
+	}
+	if operation, ok := grpcIssuanceOperations[info.FullMethod]; ok {
+		query := r.URL.Query()
+		var clientID string
+		if request, ok := req.(interface{ GetClientId() string }); ok {
+			clientID = request.GetClientId()
+			if clientID == "" {
+				return nil, status.Error(codes.InvalidArgument, "Missing argument client_id")
+			}
+		}
+		if query.Get("app_id") == "" {
+			return nil, status.Error(codes.InvalidArgument, "Missing argument app_id")
+		}
+		if query.Get(signature.SignatureParam) == "" && clientCertificate(r) == nil {
+			return nil, status.Error(codes.InvalidArgument, "Missing argument signature")
+		}
+		rpa, err := apiServer.authorizeApp(r, query.Get("app_id"), query.Get(signature.SignatureParam), operation, clientID)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		return handler(context.WithValue(ctx, grpcRPAKey{}, rpa), req)
+	}
+	if role, ok := grpcAdminRoles[info.FullMethod]; ok {
+		credential, ok := apiServer.admin.authenticate(r)
+		if !ok {
+			log.Println("Admin call without valid credentials ", info.FullMethod)
+			return nil, status.Error(codes.Unauthenticated, "Admin credentials are required")
+		}
+		if !credential.allows(role) {
+			log.Println("Admin ", credential.name, " is not allowed to call ", info.FullMethod)
+			return nil, status.Error(codes.PermissionDenied, "Role "+role+" is required")
+		}
+		return handler(context.WithValue(ctx, adminCredentialKey{}, credential), req)
+	}
+	return nil, status.Error(codes.Unimplemented, "No authorization for "+info.FullMethod)
+}
+
+//Returns the HTTP request which a gRPC call is checked as. It is a POST of the full method name with the query
+//metadata as query, the deterministic serialization of the request message as body, the authorization metadata as
+//Authorization header and the TLS state of the connection
+func grpcHTTPRequest(ctx context.Context, fullMethod string, req interface{}) (*http.Request, error) {
+	var body []byte
+	if message, ok := req.(proto.Message); ok {
+		var err error
+		body, err = proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	query := url.Values{}
+	for _, name := range grpcQueryMetadata {
+		if values := md.Get(name); len(values) > 0 {
+			query.Set(name, values[0])
+		}
+	}
+	r, err := http.NewRequest(http.MethodPost, fullMethod, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.URL.RawQuery = query.Encode()
+	if authorization := md.Get("authorization"); len(authorization) > 0 {
+		r.Header.Set("Authorization", authorization[0])
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := tlsInfo.State
+			r.TLS = &state
+		}
+	}
+	return r, nil
+}
+
+//Maps the HTTP status of a refused request to a gRPC code. The error code and details are in an ErrorInfo
+func grpcError(err *requestError) error {
+	code := codes.Internal
+	switch err.status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.Aborted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+	grpcStatus := status.New(code, err.response.Message)
+	if detailed, detailsErr := grpcStatus.WithDetails(&errdetails.ErrorInfo{Reason: err.response.Code, Domain: grpcErrorDomain, Metadata: err.response.Details}); detailsErr == nil {
+		grpcStatus = detailed
+	}
+	return grpcStatus.Err()
+}
+
+//Storage errors are refused like the HTTP API, except that applications which are already registered are refused with
+//AlreadyExists instead of Aborted
+func grpcStorageError(err error) error {
+	if storage.Kind(err) == storage.ErrAlreadyExists {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return grpcError(storageError(err))
+}
+
+//Returns the master secret version of the request or the active version if it is 0
+func (apiServer *ApiServer) grpcKeyVersion(keyVersion int32) int {
+	if keyVersion == 0 {
+		return apiServer.dTA.ActiveKeyVersion()
+	}
+	return int(keyVersion)
+}
+
+func (server *grpcIssuanceServer) GetServerSecret(ctx context.Context, request *dtapb.ServerSecretRequest) (*dtapb.ServerSecretResponse, error) {
+	rpa := ctx.Value(grpcRPAKey{}).(storage.RelyingPartyApplication)
+	keyVersion := server.apiServer.grpcKeyVersion(request.GetKeyVersion())
+	started := time.Now()
+	secret, err := server.apiServer.dTA.IssueServerSecretWithVersion(keyVersion)
+	server.apiServer.metrics.observeIssuance(storage.OperationServerSecret, started)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, grpcError(issuanceError(err))
+	}
+	server.apiServer.metrics.countIssued(storage.OperationServerSecret, rpa.Application_ID, 1)
+	sig, keyID := server.apiServer.dTA.SignIssuance(signature.IssuanceServerSecret, secret, "", 0, keyVersion)
+	return &dtapb.ServerSecretResponse{ServerSecret: secret, KeyVersion: int32(keyVersion), Signature: sig, SigningKeyId: keyID}, nil
+}
+
+func (server *grpcIssuanceServer) GetClientSecret(ctx context.Context, request *dtapb.ClientSecretRequest) (*dtapb.ClientSecretResponse, error) {
+	rpa := ctx.Value(grpcRPAKey{}).(storage.RelyingPartyApplication)
+	log.Println("Generating client secret for ", request.GetClientId())
+	keyVersion := server.apiServer.grpcKeyVersion(request.GetKeyVersion())
+	started := time.Now()
+	secret, err := server.apiServer.dTA.IssueClientSecretForApp(rpa.Application_ID, request.GetClientId(), keyVersion)
+	server.apiServer.metrics.observeIssuance(storage.OperationClientSecret, started)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, grpcError(issuanceError(err))
+	}
+	server.apiServer.metrics.countIssued(storage.OperationClientSecret, rpa.Application_ID, 1)
+	sig, keyID := server.apiServer.dTA.SignIssuance(signature.IssuanceClientSecret, secret, request.GetClientId(), 0, keyVersion)
+	return &dtapb.ClientSecretResponse{ClientSecret: secret, KeyVersion: int32(keyVersion), Signature: sig, SigningKeyId: keyID}, nil
+}
+
+func (server *grpcIssuanceServer) GetTimePermit(ctx context.Context, request *dtapb.TimePermitRequest) (*dtapb.TimePermitResponse, error) {
+	rpa := ctx.Value(grpcRPAKey{}).(storage.RelyingPartyApplication)
+	log.Println("Generating client time permit for ", request.GetClientId())
+	keyVersion := server.apiServer.grpcKeyVersion(request.GetKeyVersion())
+	today := amcl.MPIN_today()
+	started := time.Now()
+	permits, err := server.apiServer.dTA.IssueTimePermitsForApp(rpa.Application_ID, request.GetClientId(), today, today, keyVersion)
+	server.apiServer.metrics.observeIssuance(storage.OperationTimePermit, started)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, grpcError(issuanceError(err))
+	}
+	server.apiServer.metrics.countIssued(storage.OperationTimePermit, rpa.Application_ID, 1)
+	sig, keyID := server.apiServer.dTA.SignIssuance(signature.IssuanceTimePermit, permits[0], request.GetClientId(), today, keyVersion)
+	return &dtapb.TimePermitResponse{TimePermit: permits[0], EpochDay: int32(today), KeyVersion: int32(keyVersion), Signature: sig, SigningKeyId: keyID}, nil
+}
+
+func (server *grpcAdminServer) ListRPAs(ctx context.Context, request *dtapb.ListRPAsRequest) (*dtapb.ListRPAsResponse, error) {
+	rpas, err := server.apiServer.appStorage.GetAllRPAs(ctx)
+	if err != nil {
+		log.Println("Error while listing RPAs ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	response := &dtapb.ListRPAsResponse{}
+	for _, rpa := range rpas {
+		response.ApplicationIds = append(response.ApplicationIds, rpa.Application_ID)
+	}
+	return response, nil
+}
+
+func (server *grpcAdminServer) GetRPA(ctx context.Context, request *dtapb.GetRPARequest) (*dtapb.RelyingPartyApplication, error) {
+	rpa, err := server.apiServer.appStorage.GetRPA(ctx, request.GetApplicationId())
+	if err != nil {
+		log.Println("Error while reading RPA ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	message := rpaMessage(rpa)
+	//Only operators get the key material, as with the HTTP API
+	if credential, ok := adminCredentialFrom(ctx); !ok || !credential.allows(RoleOperator) {
+		message = redactRPAMessageKeys(message)
+	}
+	return message, nil
+}
+
+func (server *grpcAdminServer) RegisterRPA(ctx context.Context, request *dtapb.RegisterRPARequest) (*dtapb.RelyingPartyApplication, error) {
+	if request.GetApplicationId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Invalid request")
+	}
+	rpa := storage.RelyingPartyApplication{
+		Application_ID:          request.GetApplicationId(),
+		KeyType:                 request.GetKeyType(),
+		DisplayName:             request.GetDisplayName(),
+		OwnerContact:            request.GetOwnerContact(),
+		Disabled:                request.GetDisabled(),
+		AllowedOperations:       request.GetAllowedOperations(),
+		MaxPermitRange:          int(request.GetMaxPermitRange()),
+		AllowedIdentityDomains:  request.GetAllowedIdentityDomains(),
+		CertificateFingerprints: request.GetCertificateFingerprints(),
+		Metadata:                request.GetMetadata(),
+	}
+	if err := server.apiServer.validateRPA(rpa); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	publicKey, err := grpcPublicKey(request.GetKeyType(), request.GetPublicKey())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rpa.Application_KEY = publicKey
+	rpa, err = server.apiServer.appStorage.RegisterRPA(ctx, rpa)
+	if err != nil {
+		log.Println("Error while registering RPA ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	return rpaMessage(rpa), nil
+}
+
+func (server *grpcAdminServer) UpdateRPA(ctx context.Context, request *dtapb.UpdateRPARequest) (*dtapb.RelyingPartyApplication, error) {
+	if request.GetRevision() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid request")
+	}
+	rpa := storage.RelyingPartyApplication{
+		Application_ID:          request.GetApplicationId(),
+		DisplayName:             request.GetDisplayName(),
+		OwnerContact:            request.GetOwnerContact(),
+		Disabled:                request.GetDisabled(),
+		AllowedOperations:       request.GetAllowedOperations(),
+		MaxPermitRange:          int(request.GetMaxPermitRange()),
+		AllowedIdentityDomains:  request.GetAllowedIdentityDomains(),
+		CertificateFingerprints: request.GetCertificateFingerprints(),
+		Metadata:                request.GetMetadata(),
+		Revision:                request.GetRevision(),
+	}
+	if err := server.apiServer.validateRPA(rpa); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rpa, err := server.apiServer.appStorage.UpdateRPA(ctx, rpa)
+	if err != nil {
+		log.Println("Error while updating RPA ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	return rpaMessage(rpa), nil
+}
+
+func (server *grpcAdminServer) DeleteRPA(ctx context.Context, request *dtapb.DeleteRPARequest) (*dtapb.DeleteRPAResponse, error) {
+	if err := server.apiServer.appStorage.DeleteRPA(ctx, request.GetApplicationId()); err != nil {
+		log.Println("Error while deleting RPA ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	return &dtapb.DeleteRPAResponse{}, nil
+}
+
+func (server *grpcAdminServer) RollRPAKey(ctx context.Context, request *dtapb.RollRPAKeyRequest) (*dtapb.RelyingPartyApplication, error) {
+	rpa, err := server.apiServer.appStorage.GetRPA(ctx, request.GetApplicationId())
+	if err != nil {
+		log.Println("Error while reading RPA ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	publicKey, err := grpcPublicKey(rpa.KeyType, request.GetPublicKey())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var notBefore time.Time
+	if request.GetNotBefore() != nil {
+		notBefore = request.GetNotBefore().AsTime()
+	}
+	rpa, err = server.apiServer.appStorage.RotateRPAKey(ctx, rpa.Application_ID, publicKey, notBefore, server.apiServer.rpaKeyGracePeriod)
+	if err != nil {
+		log.Println("Error while rolling RPA key ", err.Error())
+		return nil, grpcStorageError(err)
+	}
+	log.Println("Rolled key ", rpa.Keys[len(rpa.Keys)-1].ID, " of ", rpa.Application_ID)
+	return rpaMessage(rpa), nil
+}
+
+//Checks the public key of an application with rpaPublicKey, which takes it base64 url encoded like the HTTP API
+func grpcPublicKey(keyType string, publicKey []byte) ([]byte, error) {
+	var encodedPublicKey string
+	if len(publicKey) > 0 {
+		encodedPublicKey = base64.URLEncoding.EncodeToString(publicKey)
+	}
+	return rpaPublicKey(keyType, encodedPublicKey)
+}
+
+func rpaMessage(rpa storage.RelyingPartyApplication) *dtapb.RelyingPartyApplication {
+	message := &dtapb.RelyingPartyApplication{
+		ApplicationId:           rpa.Application_ID,
+		ApplicationKey:          rpa.Application_KEY,
+		KeyType:                 rpa.KeyType,
+		DisplayName:             rpa.DisplayName,
+		OwnerContact:            rpa.OwnerContact,
+		CreatedAt:               timestamppb.New(rpa.CreatedAt),
+		UpdatedAt:               timestamppb.New(rpa.UpdatedAt),
+		Disabled:                rpa.Disabled,
+		AllowedOperations:       rpa.AllowedOperations,
+		MaxPermitRange:          int32(rpa.MaxPermitRange),
+		AllowedIdentityDomains:  rpa.AllowedIdentityDomains,
+		CertificateFingerprints: rpa.CertificateFingerprints,
+		Metadata:                rpa.Metadata,
+		Revision:                rpa.Revision,
+	}
+	for _, key := range rpa.Keys {
+		keyMessage := &dtapb.RPAKey{KeyId: key.ID, Key: key.Key, NotBefore: timestamppb.New(key.NotBefore)}
+		if !key.ExpiresAt.IsZero() {
+			keyMessage.ExpiresAt = timestamppb.New(key.ExpiresAt)
+		}
+		message.Keys = append(message.Keys, keyMessage)
+	}
+	return message
+}
+
+func redactRPAMessageKeys(message *dtapb.RelyingPartyApplication) *dtapb.RelyingPartyApplication {
+	message.ApplicationKey = nil
+	for _, key := range message.Keys {
+		key.Key = nil
+	}
+	return message
+}
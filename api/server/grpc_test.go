@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/api/dtapb"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//Serves the gRPC services of the server on an in-memory listener and returns a connection to them
+func dialGRPC(t *testing.T, apiServer *ApiServer, withAdmin bool) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := apiServer.newGRPCServer(withAdmin)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCAdmin(t *testing.T) {
+	conf := config.Config{}
+	conf.SetAdminTokens([]config.AdminTokenConfig{
+		{Name: "viewer", SHA256: HashAdminToken("viewer-token"), Role: RoleViewer},
+		{Name: "operator", SHA256: HashAdminToken("operator-token"), Role: RoleOperator},
+	})
+	admin, err := newAdminAuthenticator(conf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	client := dtapb.NewRPAAdminClient(dialGRPC(t, &ApiServer{admin: admin, appStorage: appStorage, maxTimePermitRange: 31}, true))
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	request := &dtapb.RegisterRPARequest{ApplicationId: "appid0001", DisplayName: "Test"}
+	if _, err := client.RegisterRPA(context.Background(), request); status.Code(err) != codes.Unauthenticated {
+		t.Error("Call without credentials should be unauthenticated but got ", err)
+	}
+	if _, err := client.RegisterRPA(withToken("viewer-token"), request); status.Code(err) != codes.PermissionDenied {
+		t.Error("Viewer should not register an RPA but got ", err)
+	}
+	registered, err := client.RegisterRPA(withToken("operator-token"), request)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if registered.GetRevision() != 1 || len(registered.GetApplicationKey()) == 0 {
+		t.Errorf("Registered RPA should have revision 1 and a key but got %v", registered)
+	}
+	if _, err := client.RegisterRPA(withToken("operator-token"), request); status.Code(err) != codes.AlreadyExists {
+		t.Error("Registering twice should be refused but got ", err)
+	}
+	rpa, err := client.GetRPA(withToken("viewer-token"), &dtapb.GetRPARequest{ApplicationId: "appid0001"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rpa.GetDisplayName() != "Test" {
+		t.Errorf("RPA should be read with its display name but got %v", rpa)
+	}
+	if len(rpa.GetApplicationKey()) != 0 || len(rpa.GetKeys()) != 1 || len(rpa.GetKeys()[0].GetKey()) != 0 {
+		t.Errorf("Viewer should not get the keys of the RPA but got %v", rpa)
+	}
+	rpa, err = client.GetRPA(withToken("operator-token"), &dtapb.GetRPARequest{ApplicationId: "appid0001"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(rpa.GetApplicationKey()) == 0 || len(rpa.GetKeys()) != 1 || len(rpa.GetKeys()[0].GetKey()) == 0 {
+		t.Errorf("Operator should get the keys of the RPA but got %v", rpa)
+	}
+	if _, err := client.GetRPA(withToken("viewer-token"), &dtapb.GetRPARequest{ApplicationId: "appid0002"}); status.Code(err) != codes.NotFound {
+		t.Error("Unknown RPA should not be found but got ", err)
+	}
+	if _, err := client.UpdateRPA(withToken("operator-token"), &dtapb.UpdateRPARequest{ApplicationId: "appid0001", Revision: 2}); status.Code(err) != codes.Aborted {
+		t.Error("Update of a stale revision should be aborted but got ", err)
+	}
+}
+
+func TestGRPCAdmin_AdminListener(t *testing.T) {
+	conf := config.Config{}
+	conf.SetAdminTokens([]config.AdminTokenConfig{{Name: "operator", SHA256: HashAdminToken("operator-token"), Role: RoleOperator}})
+	admin, err := newAdminAuthenticator(conf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	//With an admin listener the gRPC port serves only issuance
+	client := dtapb.NewRPAAdminClient(dialGRPC(t, &ApiServer{admin: admin, appStorage: appStorage}, false))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer operator-token")
+	if _, err := client.ListRPAs(ctx, &dtapb.ListRPAsRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Error("RPA administration should not be served but got ", err)
+	}
+}
+
+func TestGRPCIssuanceAuthorization(t *testing.T) {
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	rpa, err := appStorage.RegisterRPA(context.Background(), storage.RelyingPartyApplication{Application_ID: "appid0001", AllowedOperations: []string{storage.OperationClientSecret}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	client := dtapb.NewIssuanceClient(dialGRPC(t, &ApiServer{signatureVerifier: signature.AESSignatureVerifier{}, appStorage: appStorage}, false))
+	validSignature := base64.URLEncoding.EncodeToString(signature.CreateSignature(rpa.Application_KEY, rpa.Application_ID))
+	invalidSignature := base64.URLEncoding.EncodeToString(signature.CreateSignature(make([]byte, 16), rpa.Application_ID))
+	withApp := func(appID string, sig string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "app_id", appID, "signature", sig)
+	}
+
+	if _, err := client.GetClientSecret(withApp("appid0001", validSignature), &dtapb.ClientSecretRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Error("Call without client_id should be invalid but got ", err)
+	}
+	if _, err := client.GetServerSecret(context.Background(), &dtapb.ServerSecretRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Error("Call without app_id should be invalid but got ", err)
+	}
+	if _, err := client.GetServerSecret(withApp("appid0002", validSignature), &dtapb.ServerSecretRequest{}); status.Code(err) != codes.NotFound {
+		t.Error("Call of an unknown app should not be found but got ", err)
+	}
+	if _, err := client.GetServerSecret(withApp("appid0001", invalidSignature), &dtapb.ServerSecretRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Error("Call with an invalid signature should be unauthenticated but got ", err)
+	}
+	_, err = client.GetServerSecret(withApp("appid0001", validSignature), &dtapb.ServerSecretRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatal("Operation which is not allowed for the RPA should be denied but got ", err)
+	}
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() != api.ErrorForbidden {
+			t.Errorf("Error should have the reason %s but got %s", api.ErrorForbidden, info.GetReason())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+)
+
+//How long /readyz waits for the RPA storage
+const readinessStorageTimeout = 2 * time.Second
+
+//Tells when the server started listening. The zero value is a server which has not started
+type readiness struct {
+	once    sync.Once
+	started chan struct{}
+}
+
+//Returns the channel which is closed when the server is listening
+func (state *readiness) startedChan() chan struct{} {
+	state.once.Do(func() {
+		state.started = make(chan struct{})
+	})
+	return state.started
+}
+
+//Returns the result of the readiness checks: the master secret is loaded, the RPA storage is reachable and the random
+//number generator is seeded. Every check fails until the server is listening. It is safe to call while
+//BootstrapWithConfig runs in another goroutine
+func (apiServer *ApiServer) Readiness(ctx context.Context) api.ReadinessResponse {
+	response := api.ReadinessResponse{Status: api.StatusOK, Checks: map[string]api.ReadinessCheck{}}
+	check := func(name string, message string) {
+		if message == "" {
+			response.Checks[name] = api.ReadinessCheck{Status: api.StatusOK}
+			return
+		}
+		response.Status = api.StatusUnavailable
+		response.Checks[name] = api.ReadinessCheck{Status: api.StatusUnavailable, Message: message}
+	}
+	select {
+	case <-apiServer.readiness.startedChan():
+	default:
+		for _, name := range []string{api.CheckMasterSecret, api.CheckRPAStorage, api.CheckRNG} {
+			check(name, "D-TA is starting")
+		}
+		return response
+	}
+
+	if apiServer.dTA.ActiveKeyVersion() == 0 {
+		check(api.CheckMasterSecret, "No master secret is loaded")
+	} else {
+		check(api.CheckMasterSecret, "")
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessStorageTimeout)
+	defer cancel()
+	if err := apiServer.appStorage.Ping(ctx); err != nil {
+		log.Println("RPA storage is not ready ", err.Error())
+		check(api.CheckRPAStorage, "RPA storage is unavailable")
+	} else {
+		check(api.CheckRPAStorage, "")
+	}
+	if !apiServer.dTA.IsRNGSeeded() {
+		check(api.CheckRNG, "Random number generator is not seeded")
+	} else {
+		check(api.CheckRNG, "")
+	}
+	return response
+}
+
+//Blocks until the server is listening and Readiness reports it ready, or until the context is done
+func (apiServer *ApiServer) WaitReady(ctx context.Context) error {
+	select {
+	case <-apiServer.readiness.startedChan():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for apiServer.Readiness(ctx).Status != api.StatusOK {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+//Tells that the process is alive. It does not check the dependencies of the D-TA, see /readyz
+//	URL structure
+//		/healthz
+//	HTTP Request Method
+//		GET
+//	Returns
+//		api.HealthResponse
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.HealthResponse{Status: api.StatusOK})
+}
+
+//Tells whether the D-TA can issue secrets, with the result of each check of Readiness
+//	URL structure
+//		/readyz
+//	HTTP Request Method
+//		GET
+//	Returns
+//		api.ReadinessResponse
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		503                  A check failed
+func (apiServer *ApiServer) readyHandler(w http.ResponseWriter, r *http.Request) {
+	response := apiServer.Readiness(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != api.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
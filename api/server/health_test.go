@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta"
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+)
+
+func TestReadiness(t *testing.T) {
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	apiServer := &ApiServer{dTA: &dta.DTA{}, appStorage: appStorage}
+	router, _ := apiServer.newRouters(false)
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	if recorder := serve("/healthz"); recorder.Code != http.StatusOK {
+		t.Error("/healthz should be served before the server is ready but got ", recorder.Code)
+	}
+	if response := apiServer.Readiness(context.Background()); response.Status != api.StatusUnavailable || response.Checks[api.CheckRPAStorage].Status != api.StatusUnavailable {
+		t.Errorf("Server which is not listening should not be ready but got %+v", response)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := apiServer.WaitReady(ctx); err != context.DeadlineExceeded {
+		t.Error("WaitReady should time out before the server is listening but got ", err)
+	}
+
+	//The D-TA is not initialised, so it has no master secret and no random number generator
+	close(apiServer.readiness.startedChan())
+	recorder := serve("/readyz")
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatal("/readyz of a D-TA without master secret should be unavailable but got ", recorder.Code)
+	}
+	var response api.ReadinessResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := map[string]string{
+		api.CheckMasterSecret: api.StatusUnavailable,
+		api.CheckRPAStorage:   api.StatusOK,
+		api.CheckRNG:          api.StatusUnavailable,
+	}
+	for name, status := range expected {
+		if check := response.Checks[name]; check.Status != status || (status != api.StatusOK && check.Message == "") {
+			t.Errorf("Check %s should be %s with a message if it failed but got %+v", name, status, check)
+		}
+	}
+}
+
+func TestReadiness_UnavailableRPAStorage(t *testing.T) {
+	appStorage := &storage.SQLRPAStorage{DataSource: filepath.Join(t.TempDir(), "rpa.db")}
+	if err := appStorage.Init(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer := &ApiServer{dTA: &dta.DTA{}, appStorage: appStorage}
+	close(apiServer.readiness.startedChan())
+	if response := apiServer.Readiness(context.Background()); response.Checks[api.CheckRPAStorage].Status != api.StatusOK {
+		t.Errorf("Open RPA storage should be ready but got %+v", response)
+	}
+	appStorage.Close()
+	if response := apiServer.Readiness(context.Background()); response.Checks[api.CheckRPAStorage].Status != api.StatusUnavailable {
+		t.Errorf("Closed RPA storage should not be ready but got %+v", response)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//Reasons of signature failures in dta_signature_failures_total
+const (
+	signatureFailureEncoding = "invalid_encoding"
+	signatureFailureInvalid  = "invalid_signature"
+	signatureFailureStale    = "stale_request"
+	signatureFailureReplayed = "replayed_request"
+	signatureFailureRequest  = "invalid_request"
+)
+
+//Prometheus metrics of a server. Each server has its own registry so that several D-TA instances can run in the same
+//process. A nil *metrics records nothing, so servers which are not bootstrapped need none
+type metrics struct {
+	registry          *prometheus.Registry
+	issued            *prometheus.CounterVec
+	issuanceDuration  *prometheus.HistogramVec
+	signatureFailures *prometheus.CounterVec
+	storageErrors     *prometheus.CounterVec
+	secretErrors      *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+}
+
+//Creates the metrics of the server. The gauges read the RPA storage and the D-TA of the server when they are scraped
+func newMetrics(apiServer *ApiServer) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		issued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dta_issued_total",
+			Help: "Secrets and time permits issued, by type and relying party application.",
+		}, []string{"type", "rpa"}),
+		issuanceDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dta_issuance_duration_seconds",
+			Help:    "Time to issue secrets and time permits, by type.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"type"}),
+		signatureFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dta_signature_failures_total",
+			Help: "Requests of relying party applications refused by signature verification, by reason.",
+		}, []string{"reason"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dta_rpa_storage_errors_total",
+			Help: "Errors of the RPA storage, by storage operation and kind.",
+		}, []string{"operation", "kind"}),
+		secretErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dta_master_secret_storage_errors_total",
+			Help: "Errors of the master secret storage while rotating the master secret, by kind.",
+		}, []string{"kind"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dta_http_request_duration_seconds",
+			Help:    "Duration of HTTP requests, by route template, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+	}
+	m.registry.MustRegister(m.issued, m.issuanceDuration, m.signatureFailures, m.storageErrors, m.secretErrors, m.requestDuration)
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dta_registered_rpas",
+		Help: "Relying party applications in the RPA storage.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		count, err := apiServer.appStorage.CountRPAs(ctx)
+		if err != nil {
+			log.Println("Error while counting RPAs ", err.Error())
+			return math.NaN()
+		}
+		return float64(count)
+	}))
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "dta_active_master_key_version",
+		Help: "Version of the master secret which issues secrets and time permits by default.",
+	}, func() float64 {
+		return float64(apiServer.dTA.ActiveKeyVersion())
+	}))
+	m.registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+//Serves the metrics in the Prometheus text format
+//	URL structure
+//		/metrics
+//	HTTP Request Method
+//		GET
+func (apiServer *ApiServer) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if apiServer.metrics == nil {
+		notFoundHandler(w, r)
+		return
+	}
+	promhttp.HandlerFor(apiServer.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+//Router middleware which observes the duration of the requests of a route by its path template
+func (m *metrics) instrumentRoute(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		observer := m.requestDuration.MustCurryWith(prometheus.Labels{"route": route})
+		promhttp.InstrumentHandlerDuration(observer, next).ServeHTTP(w, r)
+	})
+}
+
+//Observes the duration of an issuance of the type, which is the storage operation of the RPA capabilities
+func (m *metrics) observeIssuance(operation string, started time.Time) {
+	if m == nil {
+		return
+	}
+	m.issuanceDuration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+}
+
+//Counts secrets or time permits issued to the relying party application
+func (m *metrics) countIssued(operation string, appID string, count int) {
+	if m == nil {
+		return
+	}
+	m.issued.WithLabelValues(operation, appID).Add(float64(count))
+}
+
+//Counts a request refused by signature verification. err is the error of the request verifier, if there is one
+func (m *metrics) countSignatureFailure(reason string, err error) {
+	if m == nil {
+		return
+	}
+	switch err {
+	case signature.ErrStaleRequest:
+		reason = signatureFailureStale
+	case signature.ErrReplayedRequest:
+		reason = signatureFailureReplayed
+	}
+	m.signatureFailures.WithLabelValues(reason).Inc()
+}
+
+//Counts an error of the storage operation by its kind and returns it
+func (m *metrics) countStorageError(operation string, err error) error {
+	if m == nil || err == nil {
+		return err
+	}
+	m.storageErrors.WithLabelValues(operation, storageErrorKind(err)).Inc()
+	return err
+}
+
+//Counts an error of the master secret storage by its kind and returns it
+func (m *metrics) countSecretStorageError(err error) error {
+	if m == nil || err == nil {
+		return err
+	}
+	m.secretErrors.WithLabelValues(storageErrorKind(err)).Inc()
+	return err
+}
+
+//Returns the kind label of a storage error
+func storageErrorKind(err error) string {
+	switch storage.Kind(err) {
+	case storage.ErrNotFound:
+		return "not_found"
+	case storage.ErrAlreadyExists:
+		return "already_exists"
+	case storage.ErrConflict:
+		return "conflict"
+	case storage.ErrUnavailable:
+		return "unavailable"
+	case storage.ErrInvalid:
+		return "invalid"
+	}
+	return "other"
+}
+
+//RPA storage which counts the errors of the storage it wraps
+type instrumentedRPAStorage struct {
+	storage.RPAStorage
+	metrics *metrics
+}
+
+func (appStorage instrumentedRPAStorage) RegisterRPA(ctx context.Context, rpa storage.RelyingPartyApplication) (storage.RelyingPartyApplication, error) {
+	rpa, err := appStorage.RPAStorage.RegisterRPA(ctx, rpa)
+	return rpa, appStorage.metrics.countStorageError("RegisterRPA", err)
+}
+
+func (appStorage instrumentedRPAStorage) UpdateRPA(ctx context.Context, rpa storage.RelyingPartyApplication) (storage.RelyingPartyApplication, error) {
+	rpa, err := appStorage.RPAStorage.UpdateRPA(ctx, rpa)
+	return rpa, appStorage.metrics.countStorageError("UpdateRPA", err)
+}
+
+func (appStorage instrumentedRPAStorage) GetAllRPAs(ctx context.Context) ([]storage.RelyingPartyApplication, error) {
+	rpas, err := appStorage.RPAStorage.GetAllRPAs(ctx)
+	return rpas, appStorage.metrics.countStorageError("GetAllRPAs", err)
+}
+
+func (appStorage instrumentedRPAStorage) Ping(ctx context.Context) error {
+	return appStorage.metrics.countStorageError("Ping", appStorage.RPAStorage.Ping(ctx))
+}
+
+func (appStorage instrumentedRPAStorage) CountRPAs(ctx context.Context) (int, error) {
+	count, err := appStorage.RPAStorage.CountRPAs(ctx)
+	return count, appStorage.metrics.countStorageError("CountRPAs", err)
+}
+
+func (appStorage instrumentedRPAStorage) GetRPA(ctx context.Context, rpaID string) (storage.RelyingPartyApplication, error) {
+	rpa, err := appStorage.RPAStorage.GetRPA(ctx, rpaID)
+	return rpa, appStorage.metrics.countStorageError("GetRPA", err)
+}
+
+func (appStorage instrumentedRPAStorage) DeleteRPA(ctx context.Context, appID string) error {
+	return appStorage.metrics.countStorageError("DeleteRPA", appStorage.RPAStorage.DeleteRPA(ctx, appID))
+}
+
+func (appStorage instrumentedRPAStorage) RotateRPAKey(ctx context.Context, appID string, publicKey []byte, notBefore time.Time, gracePeriod time.Duration) (storage.RelyingPartyApplication, error) {
+	rpa, err := appStorage.RPAStorage.RotateRPAKey(ctx, appID, publicKey, notBefore, gracePeriod)
+	return rpa, appStorage.metrics.countStorageError("RotateRPAKey", err)
+}
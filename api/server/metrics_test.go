@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+)
+
+func TestMetrics(t *testing.T) {
+	conf := config.Config{}
+	conf.SetAdminTokens([]config.AdminTokenConfig{{Name: "viewer", SHA256: HashAdminToken("viewer-token"), Role: RoleViewer}})
+	admin, err := newAdminAuthenticator(conf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer := &ApiServer{dTA: &dta.DTA{}, signatureVerifier: signature.AESSignatureVerifier{}, admin: admin}
+	apiServer.metrics = newMetrics(apiServer)
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	apiServer.appStorage = instrumentedRPAStorage{RPAStorage: appStorage, metrics: apiServer.metrics}
+	rpa, err := apiServer.appStorage.RegisterRPA(context.Background(), storage.RelyingPartyApplication{Application_ID: "appid0001"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	invalidSignature := base64.URLEncoding.EncodeToString(signature.CreateSignature(make([]byte, 16), rpa.Application_ID))
+	router, _ := apiServer.newRouters(false)
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Authorization", "Bearer viewer-token")
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	serve("/v1/clientSecret?app_id=appid0001&client_id=user@apache.org&signature=" + invalidSignature)
+	serve("/v1/clientSecret?app_id=appid0001&client_id=user@apache.org&signature=%25%25")
+	serve("/v1/clientSecret?app_id=appid0002&client_id=user@apache.org&signature=" + invalidSignature)
+	apiServer.metrics.countIssued(storage.OperationClientSecret, "appid0001", 1)
+	apiServer.metrics.observeIssuance(storage.OperationClientSecret, time.Now())
+
+	//Without an admin listener /metrics shares the port of the API and needs the viewer role
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Error("/metrics without admin credentials should be unauthorized but got ", recorder.Code)
+	}
+	recorder = serve("/metrics")
+	if recorder.Code != http.StatusOK {
+		t.Fatal("/metrics should be served but got ", recorder.Code)
+	}
+	body, _ := ioutil.ReadAll(recorder.Body)
+	for _, expected := range []string{
+		`dta_signature_failures_total{reason="invalid_signature"} 1`,
+		`dta_signature_failures_total{reason="invalid_encoding"} 1`,
+		`dta_rpa_storage_errors_total{kind="not_found",operation="GetRPA"} 1`,
+		`dta_issued_total{rpa="appid0001",type="clientSecret"} 1`,
+		`dta_issuance_duration_seconds_count{type="clientSecret"} 1`,
+		`dta_http_request_duration_seconds_count{code="401",method="get",route="/v1/clientSecret"} 1`,
+		`dta_http_request_duration_seconds_count{code="400",method="get",route="/v1/clientSecret"} 1`,
+		`dta_registered_rpas 1`,
+		`dta_active_master_key_version 0`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Metrics should contain %s", expected)
+		}
+	}
+}
+
+//Servers which are not bootstrapped have no metrics and must still serve requests
+func TestMetrics_Nil(t *testing.T) {
+	var m *metrics
+	m.countIssued(storage.OperationServerSecret, "appid0001", 1)
+	m.observeIssuance(storage.OperationServerSecret, time.Now())
+	m.countSignatureFailure(signatureFailureInvalid, nil)
+	if err := m.countStorageError("GetRPA", storage.ErrNotFound); err != storage.ErrNotFound {
+		t.Error("Storage error should be returned unchanged but got ", err)
+	}
+	apiServer := ApiServer{admin: &adminAuthenticator{open: true}}
+	router, _ := apiServer.newRouters(false)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Error("/metrics without metrics should not be found but got ", recorder.Code)
+	}
+}
+
+func TestMetrics_MasterSecretStorageErrors(t *testing.T) {
+	os.Setenv("DTA_HOME", t.TempDir())
+	defer os.Unsetenv("DTA_HOME")
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("plain.text.file")
+	conf.SetSigningKeyStorage("memory")
+	apiServer := &ApiServer{dTA: &dta.DTA{}}
+	if err := apiServer.dTA.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer.metrics = newMetrics(apiServer)
+	apiServer.appStorage = &storage.InMemoryRPAManager{}
+
+	//The file storage refuses to store the secret once the request is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recorder := httptest.NewRecorder()
+	apiServer.rotateMasterSecretHandler(recorder, httptest.NewRequest(http.MethodPost, "/v1/masterSecret/rotate", nil).WithContext(ctx))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatal("Rotation should fail with the storage but got ", recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	apiServer.metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+	if expected := `dta_master_secret_storage_errors_total{kind="unavailable"} 1`; !strings.Contains(string(body), expected) {
+		t.Errorf("Metrics should contain %s", expected)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/authserver"
+	"github.com/gorilla/mux"
+)
+
+//Every route of the router must be in the OpenAPI document and every operation of the document must be routed.
+//Routes without /v1 are checked as aliases of their /v1 route
+func TestOpenAPISpec_Routes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(api.OpenAPISpec, &spec); err != nil {
+		t.Fatal("Invalid OpenAPI document ", err.Error())
+	}
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	apiServer := ApiServer{authServer: &authserver.AuthenticationServer{}}
+	router, _ := apiServer.newRouters(false)
+	routed := map[string]bool{}
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, pathErr := route.GetPathTemplate()
+		methods, methodsErr := route.GetMethods()
+		if pathErr != nil || methodsErr != nil {
+			//Path prefixes and subrouters
+			return nil
+		}
+		for _, method := range methods {
+			operation := method + " " + path
+			if !documented[operation] {
+				operation = method + " " + apiVersion + path
+			}
+			if !documented[operation] {
+				t.Errorf("Route %s %s is not in the OpenAPI document", method, path)
+				continue
+			}
+			routed[operation] = true
+		}
+		return nil
+	})
+	for operation := range documented {
+		if !routed[operation] {
+			t.Errorf("Operation %s of the OpenAPI document is not routed", operation)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK || !bytes.Equal(recorder.Body.Bytes(), api.OpenAPISpec) {
+		t.Error("/openapi.json should return the OpenAPI document but got ", recorder.Code)
+	}
+}
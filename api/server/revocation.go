@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/gorilla/mux"
+)
+
+//Lists the revoked client identities of a relying party application
+//	URL structure
+//		/v1/rpa/{appid}/revocations
+//	HTTP Request Method
+//		GET
+//	Returns
+//       JSON response
+//		[
+//			{
+//				"ClientID" : "<M-Pin client ID>",
+//				"Reason" : "<reason>",
+//				"RevokedAt" : "<RFC 3339 time>"
+//			}
+//		]
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		404                  Unknown App
+func (apiServer *ApiServer) getRevocationsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving get /rpa/{appid}/revocations")
+	log.Println(r.UserAgent())
+	appID := mux.Vars(r)["appid"]
+	if !apiServer.rpaExists(r.Context(), appID) {
+		sendError(w, r, http.StatusNotFound, api.ErrorNotFound, "Unknown App")
+		return
+	}
+
+	revocations := []api.RevocationResponse{}
+	for _, revocation := range apiServer.dTA.GetRevocations(appID) {
+		revocations = append(revocations, api.RevocationResponse{ClientID: revocation.ClientID, Reason: revocation.Reason, RevokedAt: revocation.RevokedAt})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revocations)
+}
+
+//Revokes a client identity so that the relying party application can no longer get its client secret or time permits
+//	URL structure
+//		/v1/rpa/{appid}/revocations
+//	HTTP Request Method
+//		POST
+//	Parameters
+//       JSON request
+//		{
+//			"ClientID" : "<M-Pin client ID>",
+//			"Reason" : "<reason>"
+//		}
+//	Returns
+//       JSON response
+//		{
+//			"Message" : "OK",
+//			"ClientID" : "<M-Pin client ID>",
+//			"Reason" : "<reason>",
+//			"RevokedAt" : "<RFC 3339 time>"
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Missing argument ClientID
+//		404                  Unknown App
+//		500                  Error in storing revocation
+func (apiServer *ApiServer) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving post /rpa/{appid}/revocations")
+	log.Println(r.UserAgent())
+	appID := mux.Vars(r)["appid"]
+	if !apiServer.rpaExists(r.Context(), appID) {
+		sendError(w, r, http.StatusNotFound, api.ErrorNotFound, "Unknown App")
+		return
+	}
+
+	var request api.RevocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Println("Error while decoding input", err.Error())
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Invalid request")
+		return
+	}
+	if request.ClientID == "" {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Missing argument ClientID")
+		return
+	}
+
+	revocation, err := apiServer.dTA.Revoke(appID, request.ClientID, request.Reason)
+	if err != nil {
+		log.Println(err.Error())
+		sendError(w, r, http.StatusInternalServerError, api.ErrorInternal, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.RevocationResponse{ClientID: revocation.ClientID, Reason: revocation.Reason, RevokedAt: revocation.RevokedAt, Message: "OK"})
+}
+
+//Removes the revocation of a client identity
+//	URL structure
+//		/v1/rpa/{appid}/revocations?client_id=<M-Pin client ID>
+//	HTTP Request Method
+//		DELETE
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Missing argument client_id
+//		404                  Unknown App
+//		404                  Client identity is not revoked
+//		500                  Error in removing revocation
+func (apiServer *ApiServer) unrevokeHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving delete /rpa/{appid}/revocations")
+	log.Println(r.UserAgent())
+	appID := mux.Vars(r)["appid"]
+	if !apiServer.rpaExists(r.Context(), appID) {
+		sendError(w, r, http.StatusNotFound, api.ErrorNotFound, "Unknown App")
+		return
+	}
+	clientID := r.URL.Query().Get("client_id")
+	if clientID == "" {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Missing argument client_id")
+		return
+	}
+
+	removed, err := apiServer.dTA.Unrevoke(appID, clientID)
+	if err != nil {
+		log.Println(err.Error())
+		sendError(w, r, http.StatusInternalServerError, api.ErrorInternal, err.Error())
+		return
+	}
+	if !removed {
+		sendError(w, r, http.StatusNotFound, api.ErrorNotFound, "Client identity is not revoked")
+		return
+	}
+	log.Println("Removed revocation of ", clientID, " for ", appID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (apiServer *ApiServer) rpaExists(ctx context.Context, appID string) bool {
+	_, err := apiServer.appStorage.GetRPA(ctx, appID)
+	return err == nil
+}
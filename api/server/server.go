@@ -20,51 +20,100 @@
 package server
 
 import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ajanthan/apache-milagro-dta"
 	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/authserver"
 	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/policy"
 	"github.com/ajanthan/apache-milagro-dta/signature"
 	"github.com/ajanthan/apache-milagro-dta/storage"
 	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-cgo"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"gopkg.in/tylerb/graceful.v1"
 )
 
-var dTA *dta.DTA
-var signatureVerifier signature.SignatureVerifier
-var appStorage storage.RPAStorage
+const (
+	dateLayout    = "2006-01-02"
+	secondsPerDay = 60 * 60 * 24
+)
 
 type ApiServer struct {
 	Server *graceful.Server
+	//Set only if the admin API has its own listener
+	AdminServer *graceful.Server
+	//Set only if server.grpc.address is configured
+	GRPCServer        *grpc.Server
+	dTA               *dta.DTA
+	signatureVerifier signature.SignatureVerifier
+	//Verifies requests of relying party applications with Ed25519 keys
+	publicKeyVerifier signature.SignatureVerifier
+	appStorage        storage.RPAStorage
+	//Maximum number of days which can be requested from /timePermits
+	maxTimePermitRange int
+	//Previous RPA keys verify requests for this long after a new key is rolled
+	rpaKeyGracePeriod time.Duration
+	//Set only if the authentication server is enabled
+	authServer *authserver.AuthenticationServer
+	//Application IDs by the names of the client certificates which authenticate them
+	clientIdentities map[string]string
+	admin            *adminAuthenticator
+	metrics          *metrics
+	readiness        readiness
 }
 
 //Initialing all the sub components,configuration and starts the http server to expose the api
 func (apiServer *ApiServer) Bootstrap() {
 	conf := config.Config{}
 	conf.ParseDTAConfigFile()
-	dTA = &dta.DTA{}
-	signatureVerifier = conf.GetSignatureVerifier()
-	appStorage = conf.GetRPAStorage()
-	if err := dTA.Init(conf); err != nil {
+	apiServer.BootstrapWithConfig(conf)
+}
+
+//Same as Bootstrap but uses the given configuration instead of dta-server.yaml. This allows several D-TA instances
+//to run in the same process
+func (apiServer *ApiServer) BootstrapWithConfig(conf config.Config) {
+	apiServer.dTA = &dta.DTA{}
+	apiServer.metrics = newMetrics(apiServer)
+	apiServer.signatureVerifier = conf.GetSignatureVerifier()
+	apiServer.publicKeyVerifier = conf.GetPublicKeySignatureVerifier()
+	apiServer.appStorage = instrumentedRPAStorage{RPAStorage: conf.GetRPAStorage(), metrics: apiServer.metrics}
+	apiServer.maxTimePermitRange = conf.GetMaxTimePermitRange()
+	apiServer.rpaKeyGracePeriod = conf.GetRPAKeyGracePeriod()
+	apiServer.clientIdentities = conf.GetTLSClientIdentities()
+	if err := apiServer.dTA.Init(conf); err != nil {
 		log.Fatal(err.Error())
 		panic(err)
 	}
-	router := mux.NewRouter().StrictSlash(true)
-	router.HandleFunc("/serverSecret", serverSecretHandler).Methods("GET")
-	router.HandleFunc("/clientSecret", clientSecretHandler).Methods("GET")
-	router.HandleFunc("/timePermit", timePermitHandler).Methods("GET")
-	router.HandleFunc("/rpas", getAllRPAsHandler).Methods("GET")
-	router.HandleFunc("/rpa/{appid}", getRPAHandler).Methods("GET")
-	router.HandleFunc("/rpa", registerRPAHandler).Methods("POST")
-	router.HandleFunc("/rpa/{appid}", deleteRPAHandler).Methods("DELETE")
+	admin, err := newAdminAuthenticator(conf)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	apiServer.admin = admin
+	if conf.IsAuthenticationEnabled() {
+		authServer, err := apiServer.newAuthenticationServer(conf)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		apiServer.authServer = authServer
+	}
+	router, adminRouter := apiServer.newRouters(conf.GetAdminAddress() != "")
 
 	serverAddress := conf.GetBindAddress() + ":" + strconv.Itoa(conf.GetBindPort())
 	apiServer.Server = &graceful.Server{
@@ -72,30 +121,152 @@ func (apiServer *ApiServer) Bootstrap() {
 
 		Server: &http.Server{
 			Addr:    serverAddress,
-			Handler: router,
+			Handler: withRequestID(router),
 		},
 	}
+	if conf.GetAdminAddress() != "" {
+		apiServer.AdminServer = &graceful.Server{
+			Timeout: 10 * time.Second,
+
+			Server: &http.Server{
+				Addr:    conf.GetAdminAddress(),
+				Handler: withRequestID(adminRouter),
+			},
+		}
+	}
+	var certificates *tlsCertificates
+	var clientAuth tls.ClientAuthType
+	if conf.IsTLSEnabled() {
+		clientAuth, err = tlsClientAuth(conf.GetTLSClientAuth(), conf.GetTLSClientCAFile())
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		certFile, keyFile := conf.GetTLSCertificateFiles()
+		certificates, err = newTLSCertificates(certFile, keyFile, conf.GetTLSClientCAFile())
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		certificates.reloadOnSIGHUP(apiServer.Server.StopChan())
+	}
+	if conf.GetGRPCAddress() != "" {
+		listener, err := net.Listen("tcp", conf.GetGRPCAddress())
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if certificates != nil {
+			apiServer.GRPCServer = apiServer.newGRPCServer(conf.GetAdminAddress() == "", grpc.Creds(credentials.NewTLS(certificates.config(clientAuth))))
+		} else {
+			apiServer.GRPCServer = apiServer.newGRPCServer(conf.GetAdminAddress() == "")
+		}
+		log.Println("Starting gRPC server on ", conf.GetGRPCAddress())
+		go apiServer.GRPCServer.Serve(listener)
+	}
+	//The servers are ready once every listener is open
+	listener := listen(serverAddress, certificates, clientAuth)
+	var adminListener net.Listener
+	if apiServer.AdminServer != nil {
+		adminListener = listen(conf.GetAdminAddress(), certificates, clientAuth)
+	}
+	close(apiServer.readiness.startedChan())
+	if adminListener != nil {
+		log.Println("Starting admin server on ", conf.GetAdminAddress())
+		go apiServer.AdminServer.Serve(adminListener)
+	}
 	log.Println("Starting server on ", (serverAddress))
-	apiServer.Server.ListenAndServe()
+	apiServer.Server.Serve(listener)
+
+}
 
+//Listens on the address, with TLS if certificates are given
+func listen(address string, certificates *tlsCertificates, clientAuth tls.ClientAuthType) net.Listener {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if certificates != nil {
+		return tls.NewListener(listener, certificates.config(clientAuth))
+	}
+	return listener
 }
 
 //Gracefully stops the server
 func (apiServer *ApiServer) StopServer() {
 	log.Println("Shutting down the server..")
+	if apiServer.GRPCServer != nil {
+		apiServer.GRPCServer.GracefulStop()
+	}
+	if apiServer.AdminServer != nil {
+		apiServer.AdminServer.Stop(10 * time.Second)
+		<-apiServer.AdminServer.StopChan()
+	}
 	apiServer.Server.Stop(10 * time.Second)
 	<-apiServer.Server.StopChan()
 	log.Println("Stopped the server")
 }
 
+//Creates the router of the API and the router of the admin API, which is the same router unless the admin API has its
+//own listener. /healthz and /readyz are served with the API and /metrics with the admin API. /metrics needs the viewer
+//role when it shares the listener of the API
+func (apiServer *ApiServer) newRouters(separateAdmin bool) (*mux.Router, *mux.Router) {
+	router := newRouter()
+	router.Use(apiServer.metrics.instrumentRoute)
+	router.HandleFunc("/openapi.json", openAPIHandler).Methods("GET")
+	router.HandleFunc("/healthz", healthHandler).Methods("GET")
+	router.HandleFunc("/readyz", apiServer.readyHandler).Methods("GET")
+	if !separateAdmin {
+		router.HandleFunc("/metrics", apiServer.requireRole(RoleViewer, apiServer.metricsHandler)).Methods("GET")
+		handleVersioned(router, func(router *mux.Router) {
+			apiServer.handleAPI(router)
+			apiServer.handleAdminAPI(router)
+		})
+		return router, router
+	}
+	adminRouter := newRouter()
+	adminRouter.Use(apiServer.metrics.instrumentRoute)
+	adminRouter.HandleFunc("/metrics", apiServer.metricsHandler).Methods("GET")
+	handleVersioned(router, apiServer.handleAPI)
+	handleVersioned(adminRouter, apiServer.handleAdminAPI)
+	return router, adminRouter
+}
+
+//Adds the issuance endpoints, /keys and, if the authentication server is enabled, the authentication endpoints
+func (apiServer *ApiServer) handleAPI(router *mux.Router) {
+	router.HandleFunc("/serverSecret", apiServer.serverSecretHandler).Methods("GET")
+	router.HandleFunc("/clientSecret", apiServer.clientSecretHandler).Methods("GET")
+	router.HandleFunc("/timePermit", apiServer.timePermitHandler).Methods("GET")
+	router.HandleFunc("/timePermits", apiServer.timePermitsHandler).Methods("GET")
+	router.HandleFunc("/keys", apiServer.keysHandler).Methods("GET")
+	if apiServer.authServer != nil {
+		router.HandleFunc("/authenticate/pass1", apiServer.authenticationPass1Handler).Methods("POST")
+		router.HandleFunc("/authenticate/pass2", apiServer.authenticationPass2Handler).Methods("POST")
+	}
+}
+
+//Adds the RPA management, revocation and master secret rotation endpoints, which need admin credentials
+func (apiServer *ApiServer) handleAdminAPI(router *mux.Router) {
+	router.HandleFunc("/masterSecret/rotate", apiServer.requireRole(RoleOperator, apiServer.rotateMasterSecretHandler)).Methods("POST")
+	router.HandleFunc("/rpas", apiServer.requireRole(RoleViewer, apiServer.getAllRPAsHandler)).Methods("GET")
+	router.HandleFunc("/rpa/{appid}", apiServer.requireRole(RoleViewer, apiServer.getRPAHandler)).Methods("GET")
+	router.HandleFunc("/rpa", apiServer.requireRole(RoleOperator, apiServer.registerRPAHandler)).Methods("POST")
+	router.HandleFunc("/rpa/{appid}", apiServer.requireRole(RoleOperator, apiServer.updateRPAHandler)).Methods("PUT")
+	router.HandleFunc("/rpa/{appid}", apiServer.requireRole(RoleOperator, apiServer.patchRPAHandler)).Methods("PATCH")
+	router.HandleFunc("/rpa/{appid}", apiServer.requireRole(RoleOperator, apiServer.deleteRPAHandler)).Methods("DELETE")
+	router.HandleFunc("/rpa/{appid}/keys", apiServer.requireRole(RoleOperator, apiServer.rollRPAKeyHandler)).Methods("POST")
+	router.HandleFunc("/rpa/{appid}/revocations", apiServer.requireRole(RoleViewer, apiServer.getRevocationsHandler)).Methods("GET")
+	router.HandleFunc("/rpa/{appid}/revocations", apiServer.requireRole(RoleOperator, apiServer.revokeHandler)).Methods("POST")
+	router.HandleFunc("/rpa/{appid}/revocations", apiServer.requireRole(RoleOperator, apiServer.unrevokeHandler)).Methods("DELETE")
+}
+
 //Retrieves the M-Pin server secret
 //	URL structure
-//		/serverSecret?app_id=<app_id>&signature=<signature>
+//		/v1/serverSecret?app_id=<app_id>&signature=<signature>[&key_id=<key ID>][&key_version=<version>]
 //	HTTP Request Method
 //		GET
 //	Parameters
 //		- app_id: <identity of the Application>
-//		- signature: <signature>
+//		- key_id: <optional ID of the application key which signed the request. Every valid key is tried by default>
+//		- key_version: <optional master secret version. The active version is used by default>
+//		- signature: <signature. Not needed if a client certificate authenticates the application>
 //			Signature
 //				The signature is generated for this message  and base64 url encoded
 //				message =<app_id>
@@ -104,67 +275,55 @@ func (apiServer *ApiServer) StopServer() {
 //       JSON response
 //		{
 //			"Message" : "OK",
-//			"ServerSecret" : "<base64 url encoded serverSecret>"
+//			"ServerSecret" : "<base64 url encoded serverSecret>",
+//			"KeyVersion" : <master secret version>,
+//			"Signature" : "<base64 url encoded signature of the D-TA>",
+//			"SigningKeyID" : "<ID of the key in /keys>"
 //		}
 //	Status-Codes and Response-Phrases
 //		Status-Code          Response-Phrase
 //		200                  OK
-//		401                  Invalid signature
-//		403                  Missing argument [value]
-//		403                  Invalid App key
+//		400                  Unknown or expired key version
 //		500                  M-Pin Server Secret Generation
-func serverSecretHandler(w http.ResponseWriter, r *http.Request) {
+//	and the errors of authorizeIssuance
+func (apiServer *ApiServer) serverSecretHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("serving /serverSecret")
 	log.Println(r.UserAgent())
-	appID := r.URL.Query().Get("app_id")
-	if appID == "" {
-		message := "Missing argument app_id"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ServerSecretResponse{Message: message}, w)
-	}
-	signatureBase64URLEncoded := r.URL.Query().Get("signature")
-	if signatureBase64URLEncoded == "" {
-		message := "Missing argument signature"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ServerSecretResponse{Message: message}, w)
+	rpa, ok := apiServer.authorizeIssuance(w, r, storage.OperationServerSecret)
+	if !ok {
+		return
 	}
-	signature, err := base64.URLEncoding.DecodeString(signatureBase64URLEncoded)
+	keyVersion, err := apiServer.keyVersion(r)
 	if err != nil {
-		message := "Invalid signature encoding"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ServerSecretResponse{Message: message}, w)
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
 	}
-	app_key := appStorage.GetRPA(appID).Application_KEY
-	if app_key == nil {
-		message := "Invalid App key"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ServerSecretResponse{Message: message}, w)
-	}
-	if signatureVerifier.VerifySignature(signature, app_key, appID) {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		secret, mpinErr := dTA.IssueServerSecret()
-		if mpinErr != nil {
-			sendError(http.StatusInternalServerError, api.ServerSecretResponse{Message: mpinErr.Error()}, w)
-		}
-		serverSecretResponse := api.ServerSecretResponse{Message: "OK", ServerSecret: base64.URLEncoding.EncodeToString(secret)}
-		json.NewEncoder(w).Encode(serverSecretResponse)
-	} else {
-		message := "Signature varification is failed"
-		log.Println(message)
-		sendError(http.StatusUnauthorized, api.ServerSecretResponse{Message: message}, w)
+	started := time.Now()
+	secret, err := apiServer.dTA.IssueServerSecretWithVersion(keyVersion)
+	apiServer.metrics.observeIssuance(storage.OperationServerSecret, started)
+	if err != nil {
+		sendIssuanceError(w, r, err)
+		return
 	}
+	apiServer.metrics.countIssued(storage.OperationServerSecret, rpa.Application_ID, 1)
+	sig, keyID := apiServer.dTA.SignIssuance(signature.IssuanceServerSecret, secret, "", 0, keyVersion)
+	serverSecretResponse := api.ServerSecretResponse{Message: "OK", ServerSecret: base64.URLEncoding.EncodeToString(secret), KeyVersion: keyVersion, Signature: base64.URLEncoding.EncodeToString(sig), SigningKeyID: keyID}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(serverSecretResponse)
 }
 
 //Retrieves the M-Pin client secret
 //	URL structure
-//		/clientSecret?app_id=<app_id>&client_id=<M-Pin client ID>&signature=<signature>
+//		/v1/clientSecret?app_id=<app_id>&client_id=<M-Pin client ID>&signature=<signature>[&key_id=<key ID>][&key_version=<version>]
 //	HTTP Request Method
 //		GET
 //	Parameters
 //		- app_id: <identity of the Application>
 //		-client_id: <M-Pin identity for which client secret is requested>
-//		- signature: <signature>
+//		- key_id: <optional ID of the application key which signed the request. Every valid key is tried by default>
+//		- key_version: <optional master secret version. The active version is used by default>
+//		- signature: <signature. Not needed if a client certificate authenticates the application>
 //			Signature
 //				The signature is generated for this message and base64 url encoded
 //				message =<app_id>
@@ -173,224 +332,888 @@ func serverSecretHandler(w http.ResponseWriter, r *http.Request) {
 //       JSON response
 //		{
 //			"Message" : "OK",
-//			"ClientSecret" : "<base64 url encoded Client Secret>"
+//			"ClientSecret" : "<base64 url encoded Client Secret>",
+//			"KeyVersion" : <master secret version>,
+//			"Signature" : "<base64 url encoded signature of the D-TA>",
+//			"SigningKeyID" : "<ID of the key in /keys>"
 //		}
 //	Status-Codes and Response-Phrases
 //		Status-Code          Response-Phrase
 //		200                  OK
-//		401                  Invalid signature
-//		403                  Missing argument [value]
-//		403                  Invalid App key
-//		403                  Invalid signature encoding
+//		400                  Unknown or expired key version
+//		403                  Client identity is revoked
+//		403                  Identity rejected by policy [name]: [reason]
+//			{
+//				"code" : "policy_violation",
+//				"message" : "Identity rejected by policy [name]: [reason]",
+//				"request_id" : "<request ID>",
+//				"details" : {"policy" : "<name of the policy>", "reason" : "<reason>"}
+//			}
 //		500                  M-Pin Client Secret Generation
-func clientSecretHandler(w http.ResponseWriter, r *http.Request) {
+//	and the errors of authorizeIssuance
+func (apiServer *ApiServer) clientSecretHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("serving /clientSecret ")
 	log.Println(r.UserAgent())
-	appID := r.URL.Query().Get("app_id")
-
-	if appID == "" {
-		message := "Missing argument app_id"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ClientSecretResponse{Message: message}, w)
+	rpa, ok := apiServer.authorizeIssuance(w, r, storage.OperationClientSecret, "client_id")
+	if !ok {
+		return
 	}
-
 	clientID := r.URL.Query().Get("client_id")
+	log.Println("Generating client secret for ", clientID)
 
-	if clientID == "" {
-		message := "Missing argument client_id"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ClientSecretResponse{Message: message}, w)
+	keyVersion, err := apiServer.keyVersion(r)
+	if err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
 	}
-	signatureBase64URLEncoded := r.URL.Query().Get("signature")
-
-	if signatureBase64URLEncoded == "" {
-		message := "Missing argument signature"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ClientSecretResponse{Message: message}, w)
+	started := time.Now()
+	secret, err := apiServer.dTA.IssueClientSecretForApp(rpa.Application_ID, clientID, keyVersion)
+	apiServer.metrics.observeIssuance(storage.OperationClientSecret, started)
+	if err != nil {
+		sendIssuanceError(w, r, err)
+		return
 	}
+	apiServer.metrics.countIssued(storage.OperationClientSecret, rpa.Application_ID, 1)
+
+	response := api.ClientSecretResponse{}
+	response.ClientSecret = base64.URLEncoding.EncodeToString(secret)
+	response.KeyVersion = keyVersion
+	sig, keyID := apiServer.dTA.SignIssuance(signature.IssuanceClientSecret, secret, clientID, 0, keyVersion)
+	response.Signature = base64.URLEncoding.EncodeToString(sig)
+	response.SigningKeyID = keyID
+	response.Message = "OK"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
 
-	signature, err := base64.URLEncoding.DecodeString(signatureBase64URLEncoded)
+//Retrieves the M-Pin time permit
+//	URL structure
+//		/v1/timePermit?app_id=<app_id>&client_id=<M-Pin client ID>&signature=<signature>[&key_id=<key ID>][&key_version=<version>]
+//	HTTP Request Method
+//		GET
+//	Parameters
+//		- app_id: <identity of the Application>
+//		-client_id: <M-Pin identity for which client secret is requested>
+//		- key_id: <optional ID of the application key which signed the request. Every valid key is tried by default>
+//		- key_version: <optional master secret version. The active version is used by default>
+//		- signature: <signature. Not needed if a client certificate authenticates the application>
+//			Signature
+//				The signature is generated for this message  and base64 url encoded
+//				message =<app_id>
+//	Returns
+//	Calculates the MPIN time permit which is returned in this JSON object
+//       JSON response
+//		{
+//			"Message" : "OK",
+//			"TimePermit" : "<base64 url encoded time permit>",
+//			"EpochDay" : <days since 1970-01-01>,
+//			"KeyVersion" : <master secret version>,
+//			"Signature" : "<base64 url encoded signature of the D-TA>",
+//			"SigningKeyID" : "<ID of the key in /keys>"
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Unknown or expired key version
+//		403                  Client identity is revoked
+//		500                  M-Pin Time Permit Generation
+//	and the errors of authorizeIssuance
+func (apiServer *ApiServer) timePermitHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving /timePermit")
+	log.Println(r.UserAgent())
+	rpa, ok := apiServer.authorizeIssuance(w, r, storage.OperationTimePermit, "client_id")
+	if !ok {
+		return
+	}
+	clientID := r.URL.Query().Get("client_id")
+	log.Println("Generating client time permit for ", clientID)
 
+	keyVersion, err := apiServer.keyVersion(r)
 	if err != nil {
-		message := "Invalid signature encoding"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ClientSecretResponse{Message: message}, w)
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+	today := amcl.MPIN_today()
+	started := time.Now()
+	permits, err := apiServer.dTA.IssueTimePermitsForApp(rpa.Application_ID, clientID, today, today, keyVersion)
+	apiServer.metrics.observeIssuance(storage.OperationTimePermit, started)
+	if err != nil {
+		sendIssuanceError(w, r, err)
+		return
 	}
+	apiServer.metrics.countIssued(storage.OperationTimePermit, rpa.Application_ID, 1)
 
-	app_key := appStorage.GetRPA(appID).Application_KEY
+	response := api.TimePermitResponse{}
+	response.TimePermit = base64.URLEncoding.EncodeToString(permits[0])
+	response.EpochDay = today
+	response.KeyVersion = keyVersion
+	sig, keyID := apiServer.dTA.SignIssuance(signature.IssuanceTimePermit, permits[0], clientID, today, keyVersion)
+	response.Signature = base64.URLEncoding.EncodeToString(sig)
+	response.SigningKeyID = keyID
+	response.Message = "OK"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
 
-	if app_key == nil {
-		message := "Invalid App key"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.ClientSecretResponse{Message: message}, w)
+//Retrieves M-Pin time permits for a range of dates
+//	URL structure
+//		/v1/timePermits?app_id=<app_id>&client_id=<M-Pin client ID>&from=<YYYY-MM-DD>&to=<YYYY-MM-DD>&signature=<signature>[&key_id=<key ID>][&key_version=<version>]
+//	HTTP Request Method
+//		GET
+//	Parameters
+//		- app_id: <identity of the Application>
+//		-client_id: <M-Pin identity for which time permits are requested>
+//		- from: <first date (UTC) of the range, inclusive>
+//		- to: <last date (UTC) of the range, inclusive>
+//		- key_id: <optional ID of the application key which signed the request. Every valid key is tried by default>
+//		- key_version: <optional master secret version. The active version is used by default>
+//		- signature: <signature. Not needed if a client certificate authenticates the application>
+//			Signature
+//				The signature is generated for this message  and base64 url encoded
+//				message =<app_id>
+//	Returns
+//	Calculates one MPIN time permit per day which are returned in this JSON object
+//       JSON response
+//		{
+//			"Message" : "OK",
+//			"TimePermits" : [
+//				{
+//					"Date" : "<YYYY-MM-DD>",
+//					"EpochDay" : <days since 1970-01-01>,
+//					"TimePermit" : "<base64 url encoded time permit>",
+//					"Signature" : "<base64 url encoded signature of the D-TA>"
+//				}
+//			],
+//			"KeyVersion" : <master secret version>,
+//			"SigningKeyID" : "<ID of the key in /keys>"
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Invalid date [value]
+//		400                  Invalid date range
+//		400                  Unknown or expired key version
+//		403                  Client identity is revoked
+//		500                  M-Pin Time Permit Generation
+//	and the errors of authorizeIssuance
+func (apiServer *ApiServer) timePermitsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving /timePermits")
+	log.Println(r.UserAgent())
+	rpa, ok := apiServer.authorizeIssuance(w, r, storage.OperationTimePermit, "client_id", "from", "to")
+	if !ok {
+		return
 	}
+	query := r.URL.Query()
+	clientID := query.Get("client_id")
 
-	if signatureVerifier.VerifySignature(signature, app_key, appID) {
-
-		log.Println("Generating client secret for ", clientID)
-		hash_client_id := amcl.MPIN_HASH_ID([]byte(clientID))
+	maxRange := apiServer.maxTimePermitRange
+	if rpa.MaxPermitRange > 0 {
+		maxRange = rpa.MaxPermitRange
+	}
+	from, to, err := parseTimePermitRange(query.Get("from"), query.Get("to"), maxRange)
+	if err != nil {
+		log.Println(err.Error())
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
 
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		response := api.ClientSecretResponse{}
-		secret, mpinError := dTA.IssueClientSecret(hash_client_id)
+	keyVersion, err := apiServer.keyVersion(r)
+	if err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
 
-		if mpinError != nil {
-			sendError(http.StatusInternalServerError, api.ClientSecretResponse{Message: mpinError.Error()}, w)
-		}
+	log.Println("Generating time permits for ", clientID, " from ", from, " to ", to)
+	started := time.Now()
+	permits, err := apiServer.dTA.IssueTimePermitsForApp(rpa.Application_ID, clientID, from, to, keyVersion)
+	apiServer.metrics.observeIssuance(storage.OperationTimePermit, started)
+	if err != nil {
+		sendIssuanceError(w, r, err)
+		return
+	}
+	apiServer.metrics.countIssued(storage.OperationTimePermit, rpa.Application_ID, len(permits))
+	response := api.TimePermitsResponse{KeyVersion: keyVersion}
+	for i, permit := range permits {
+		sig, keyID := apiServer.dTA.SignIssuance(signature.IssuanceTimePermit, permit, clientID, from+i, keyVersion)
+		response.SigningKeyID = keyID
+		response.TimePermits = append(response.TimePermits, api.DatedTimePermit{
+			Date:       epochDayToDate(from + i),
+			EpochDay:   from + i,
+			TimePermit: base64.URLEncoding.EncodeToString(permit),
+			Signature:  base64.URLEncoding.EncodeToString(sig),
+		})
+	}
+	response.Message = "OK"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
 
-		response.ClientSecret = base64.URLEncoding.EncodeToString(secret)
-		response.Message = "OK"
-		json.NewEncoder(w).Encode(response)
+//Converts from and to dates (YYYY-MM-DD) into epoch days and checks that the range does not exceed maxRange days
+func parseTimePermitRange(fromDate string, toDate string, maxRange int) (int, int, error) {
+	from, err := dateToEpochDay(fromDate)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid date %s", fromDate)
+	}
+	to, err := dateToEpochDay(toDate)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid date %s", toDate)
+	}
+	if to < from {
+		return 0, 0, errors.New("Invalid date range")
+	}
+	if to-from+1 > maxRange {
+		return 0, 0, fmt.Errorf("Invalid date range. Maximum %d days are allowed", maxRange)
+	}
+	return from, to, nil
+}
 
-	} else {
-		message := "Signature varification is failed"
-		log.Println(message)
-		sendError(http.StatusUnauthorized, api.ClientSecretResponse{Message: message}, w)
+//Returns days since 1970-01-01 for the given YYYY-MM-DD date in UTC, same as amcl.MPIN_today. Earlier dates have no
+//time permits and are refused
+func dateToEpochDay(date string) (int, error) {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return 0, err
 	}
+	if t.Unix() < 0 {
+		return 0, errors.New("Date before 1970-01-01")
+	}
+	return int(t.Unix() / secondsPerDay), nil
+}
 
+func epochDayToDate(epochDay int) string {
+	return time.Unix(int64(epochDay)*secondsPerDay, 0).UTC().Format(dateLayout)
 }
 
-//Retrieves the M-Pin time permit
+//Publishes the keys verifying the signatures of issued secrets
 //	URL structure
-//		/clientSecret?app_id=<app_id>&client_id=<M-Pin client ID>&signature=<signature>
+//		/v1/keys
 //	HTTP Request Method
 //		GET
-//	Parameters
-//		- app_id: <identity of the Application>
-//		-client_id: <M-Pin identity for which client secret is requested>
-//		- signature: <signature>
-//			Signature
-//				The signature is generated for this message  and base64 url encoded
-//				message =<app_id>
 //	Returns
-//	Calculates the MPIN time permit which is returned in this JSON object
 //       JSON response
 //		{
 //			"Message" : "OK",
-//			"TimePermit" : "<base64 url encoded time permit>"
+//			"Keys" : [
+//				{
+//					"KeyID" : "<ID of the key used as SigningKeyID>",
+//					"Algorithm" : "Ed25519",
+//					"PublicKey" : "<base64 url encoded public key>"
+//				}
+//			]
 //		}
 //	Status-Codes and Response-Phrases
 //		Status-Code          Response-Phrase
 //		200                  OK
-//		401                  Invalid signature
-//		403                  Missing argument [value]
-//		403                  Invalid App key
-//		403                  Invalid signature encoding
-//		500                  M-Pin Client Secret Generation
-func timePermitHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("serving /timePermit")
+func (apiServer *ApiServer) keysHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving /keys")
+	verificationKey := apiServer.dTA.VerificationKey()
+	response := api.KeysResponse{
+		Keys: []api.VerificationKey{{
+			KeyID:     signature.SigningKeyID(verificationKey),
+			Algorithm: signature.IssuanceSigningAlgorithm,
+			PublicKey: base64.URLEncoding.EncodeToString(verificationKey),
+		}},
+		Message: "OK",
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+//Publishes the OpenAPI document of the API, api.OpenAPISpec
+//	URL structure
+//		/openapi.json
+//	HTTP Request Method
+//		GET
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(api.OpenAPISpec)
+}
+
+//Rotates the master secret. The previous versions are still served for the configured grace period
+//	URL structure
+//		/v1/masterSecret/rotate
+//	HTTP Request Method
+//		POST
+//	Returns
+//       JSON response
+//		{
+//			"Message" : "OK",
+//			"KeyVersion" : <new active master secret version>
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		409                  Master secret was rotated concurrently
+//		500                  Error in storing master secret
+//		503                  Master secret storage is unavailable
+func (apiServer *ApiServer) rotateMasterSecretHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving post /masterSecret/rotate")
 	log.Println(r.UserAgent())
 
-	appID := r.URL.Query().Get("app_id")
-	if appID == "" {
-		message := "Missing argument app_id"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.TimePermitResponse{Message: message}, w)
+	keyVersion, err := apiServer.dTA.RotateMasterSecret(r.Context())
+	if err != nil {
+		apiServer.metrics.countSecretStorageError(err)
+		log.Println("Error while rotating master secret ", err.Error())
+		sendStorageError(w, r, err)
+		return
 	}
-	clientID := r.URL.Query().Get("client_id")
-	if clientID == "" {
-		message := "Missing argument client_id"
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.MasterSecretRotationResponse{KeyVersion: keyVersion, Message: "OK"})
+}
+
+//Returns the key_version parameter of the request or the active master secret version if it is not given
+func (apiServer *ApiServer) keyVersion(r *http.Request) (int, error) {
+	keyVersion := r.URL.Query().Get("key_version")
+	if keyVersion == "" {
+		return apiServer.dTA.ActiveKeyVersion(), nil
+	}
+	version, err := strconv.Atoi(keyVersion)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid key version %s", keyVersion)
+	}
+	return version, nil
+}
+
+//Checks the arguments of an issuance request, authenticates the relying party application and checks that it may
+//request the operation for the client_id of the request. The arguments app_id and signature are always required, the
+//signature only if no client certificate authenticates the application. If the request is refused the error is sent
+//and false is returned
+//	Status-Codes and Error Codes
+//		Status-Code          Code                 Message
+//		400                  invalid_request      Missing argument [value]
+//		400                  invalid_request      Invalid signature encoding
+//		401                  unauthorized         Invalid signature
+//		403                  forbidden            RPA is disabled
+//		403                  forbidden            Operation [operation] is not allowed for the RPA
+//		403                  policy_violation     Identity rejected by policy [name]: [reason]
+//		404                  not_found            Unknown App
+//		503                  unavailable          RPA storage is unavailable
+func (apiServer *ApiServer) authorizeIssuance(w http.ResponseWriter, r *http.Request, operation string, arguments ...string) (storage.RelyingPartyApplication, bool) {
+	query := r.URL.Query()
+	for _, argument := range append(append([]string{"app_id"}, arguments...), "signature") {
+		if query.Get(argument) == "" && !(argument == "signature" && clientCertificate(r) != nil) {
+			message := "Missing argument " + argument
+			log.Println(message)
+			sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, message)
+			return storage.RelyingPartyApplication{}, false
+		}
+	}
+	rpa, err := apiServer.authorizeApp(r, query.Get("app_id"), query.Get("signature"), operation, query.Get("client_id"))
+	if err != nil {
+		sendRequestError(w, r, err)
+		return storage.RelyingPartyApplication{}, false
+	}
+	return rpa, true
+}
+
+//Authenticates the relying party application of an issuance request by its client certificate or by the base64 url
+//encoded signature and checks that it may request the operation for the client ID. This is shared by the HTTP handlers
+//and the gRPC services
+func (apiServer *ApiServer) authorizeApp(r *http.Request, appID string, encodedSignature string, operation string, clientID string) (storage.RelyingPartyApplication, *requestError) {
+	appSignature, err := base64.URLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		message := "Invalid signature encoding"
 		log.Println(message)
-		sendError(http.StatusForbidden, api.TimePermitResponse{Message: message}, w)
+		apiServer.metrics.countSignatureFailure(signatureFailureEncoding, nil)
+		return storage.RelyingPartyApplication{}, newRequestError(http.StatusBadRequest, api.ErrorInvalidRequest, message)
+	}
+	rpa, err := apiServer.appStorage.GetRPA(r.Context(), appID)
+	if err != nil {
+		log.Println("Error while reading RPA ", err.Error())
+		if storage.Kind(err) == storage.ErrNotFound {
+			return storage.RelyingPartyApplication{}, newRequestError(http.StatusNotFound, api.ErrorNotFound, "Unknown App")
+		}
+		return storage.RelyingPartyApplication{}, newRequestError(http.StatusServiceUnavailable, api.ErrorUnavailable, "RPA storage is unavailable")
+	}
+	if !apiServer.authenticateApp(r, rpa, appSignature) {
+		message := "Invalid signature"
+		log.Println(message, " of ", rpa.Application_ID)
+		return storage.RelyingPartyApplication{}, newRequestError(http.StatusUnauthorized, api.ErrorUnauthorized, message)
 	}
+	if err := checkCapabilities(rpa, operation, clientID); err != nil {
+		log.Println(err.Error())
+		return storage.RelyingPartyApplication{}, capabilityError(err)
+	}
+	return rpa, nil
+}
 
-	signatureBase64URLEncoded := r.URL.Query().Get("signature")
+func sendIssuanceError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Println(err.Error())
+	sendRequestError(w, r, issuanceError(err))
+}
 
-	signature, _ := base64.URLEncoding.DecodeString(signatureBase64URLEncoded)
+//Unknown or expired master secret versions are client errors, revoked identities and identities rejected by a
+//policy are refused and everything else is a failure of the D-TA
+func issuanceError(err error) *requestError {
+	switch err {
+	case dta.ErrUnknownKeyVersion, dta.ErrExpiredKeyVersion:
+		return newRequestError(http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+	case dta.ErrRevokedIdentity:
+		return newRequestError(http.StatusForbidden, api.ErrorRevoked, err.Error())
+	}
+	if _, ok := err.(*policy.PolicyViolation); ok {
+		return capabilityError(err)
+	}
+	return newRequestError(http.StatusInternalServerError, api.ErrorInternal, err.Error())
+}
 
-	if signatureBase64URLEncoded == "" {
-		message := "Missing argument signature"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.TimePermitResponse{Message: message}, w)
+//Verifies the signature of the request with the keys of the relying party application which are valid now. If the
+//request names a key only that key is tried. Verifiers which sign the whole request verify it with VerifyRequest.
+//Applications with Ed25519 keys are verified with their public keys whatever server.signatureVerifier is
+func (apiServer *ApiServer) verifyAppSignature(r *http.Request, rpa storage.RelyingPartyApplication, appSignature []byte) bool {
+	verifier := apiServer.signatureVerifier
+	if rpa.KeyType == storage.KeyTypeEd25519 {
+		verifier = apiServer.publicKeyVerifier
 	}
-	app_key := appStorage.GetRPA(appID).Application_KEY
+	requestVerifier, signsRequest := verifier.(signature.RequestSignatureVerifier)
+	for _, key := range rpa.ValidKeys(time.Now(), r.URL.Query().Get("key_id")) {
+		if signsRequest {
+			err := requestVerifier.VerifyRequest(r, appSignature, key.Key)
+			if err == nil {
+				return true
+			}
+			if err != signature.ErrInvalidRequestSignature {
+				log.Println("Request of ", rpa.Application_ID, " is refused: ", err.Error())
+				apiServer.metrics.countSignatureFailure(signatureFailureRequest, err)
+				return false
+			}
+			continue
+		}
+		//The verifier decrypts the signature in place
+		if verifier.VerifySignature(append([]byte(nil), appSignature...), key.Key, rpa.Application_ID) {
+			return true
+		}
+	}
+	apiServer.metrics.countSignatureFailure(signatureFailureInvalid, nil)
+	return false
+}
 
-	if app_key == nil {
-		message := "Invalid App key"
-		log.Println(message)
-		sendError(http.StatusForbidden, api.TimePermitResponse{Message: message}, w)
+//Checks that the relying party application is enabled and may request the operation for the client ID. The client ID
+//is empty for server secrets. Returns a *policy.PolicyViolation if the client ID is not in an allowed identity domain
+func checkCapabilities(rpa storage.RelyingPartyApplication, operation string, clientID string) error {
+	if rpa.Disabled {
+		return errors.New("RPA is disabled")
 	}
+	if !rpa.Allows(operation) {
+		return fmt.Errorf("Operation %s is not allowed for the RPA", operation)
+	}
+	if clientID != "" && len(rpa.AllowedIdentityDomains) > 0 {
+		return policy.DomainPolicy{Allow: rpa.AllowedIdentityDomains}.Check(clientID)
+	}
+	return nil
+}
 
-	if signatureVerifier.VerifySignature(signature, app_key, appID) {
-		log.Println("Generating client time permit for ", clientID)
-		hash_client_id := amcl.MPIN_HASH_ID([]byte(clientID))
+//Refuses a request which checkCapabilities rejected. The policy and the reason of a policy violation are in the
+//details of the error
+func capabilityError(err error) *requestError {
+	if violation, ok := err.(*policy.PolicyViolation); ok {
+		return &requestError{status: http.StatusForbidden, response: api.ErrorResponse{
+			Code:    api.ErrorPolicyViolation,
+			Message: violation.Error(),
+			Details: map[string]string{"policy": violation.Policy, "reason": violation.Reason},
+		}}
+	}
+	return newRequestError(http.StatusForbidden, api.ErrorForbidden, err.Error())
+}
 
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "application/json")
-		response := api.TimePermitResponse{}
-		permit, mpinError := dTA.IssueTimePermit(hash_client_id)
+//Checks the capabilities and certificate fingerprints given by an admin
+func (apiServer *ApiServer) validateRPA(rpa storage.RelyingPartyApplication) error {
+	for _, operation := range rpa.AllowedOperations {
+		valid := false
+		for _, known := range storage.Operations() {
+			valid = valid || operation == known
+		}
+		if !valid {
+			return fmt.Errorf("Unknown operation %s", operation)
+		}
+	}
+	if rpa.MaxPermitRange < 0 || rpa.MaxPermitRange > apiServer.maxTimePermitRange {
+		return fmt.Errorf("MaxPermitRange must be between 0 and %d", apiServer.maxTimePermitRange)
+	}
+	for _, domain := range rpa.AllowedIdentityDomains {
+		if domain == "" || strings.ContainsAny(domain, ",@ ") {
+			return fmt.Errorf("Invalid identity domain %q", domain)
+		}
+	}
+	for _, fingerprint := range rpa.CertificateFingerprints {
+		if decoded, err := hex.DecodeString(fingerprint); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("Invalid certificate fingerprint %q. It must be the hex SHA-256 of the certificate", fingerprint)
+		}
+	}
+	return nil
+}
 
-		if mpinError != nil {
-			sendError(http.StatusInternalServerError, api.TimePermitResponse{Message: mpinError.Error()}, w)
+//Decodes the public key given for an application with the key type. Only applications with Ed25519 keys give one
+func rpaPublicKey(keyType string, encodedPublicKey string) ([]byte, error) {
+	switch keyType {
+	case "", storage.KeyTypeShared:
+		if encodedPublicKey != "" {
+			return nil, errors.New("PublicKey is given only for Ed25519 keys")
+		}
+		return nil, nil
+	case storage.KeyTypeEd25519:
+		publicKey, err := base64.URLEncoding.DecodeString(encodedPublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, errors.New("PublicKey must be a base64 url encoded Ed25519 public key")
 		}
+		return publicKey, nil
+	}
+	return nil, fmt.Errorf("Unknown key type %s", keyType)
+}
 
-		response.TimePermit = base64.URLEncoding.EncodeToString(permit)
-		response.Message = "OK"
-		json.NewEncoder(w).Encode(response)
-	} else {
-		message := "Signature varification is failed"
-		log.Println(message)
-		sendError(http.StatusUnauthorized, api.TimePermitResponse{Message: message}, w)
+func sendStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	sendRequestError(w, r, storageError(err))
+}
+
+//Returns the status and code of the kind of a storage error
+func storageError(err error) *requestError {
+	switch storage.Kind(err) {
+	case storage.ErrNotFound:
+		return newRequestError(http.StatusNotFound, api.ErrorNotFound, err.Error())
+	case storage.ErrAlreadyExists, storage.ErrConflict:
+		return newRequestError(http.StatusConflict, api.ErrorConflict, err.Error())
+	case storage.ErrUnavailable:
+		return newRequestError(http.StatusServiceUnavailable, api.ErrorUnavailable, err.Error())
+	case storage.ErrInvalid:
+		return newRequestError(http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
 	}
+	return newRequestError(http.StatusInternalServerError, api.ErrorInternal, err.Error())
+}
+
+func rpaResponse(rpa storage.RelyingPartyApplication) api.RelyingPartyApplicationResponse {
+	response := api.RelyingPartyApplicationResponse{
+		Application_ID:          rpa.Application_ID,
+		Application_KEY:         base64.URLEncoding.EncodeToString(rpa.Application_KEY),
+		KeyType:                 rpa.KeyType,
+		DisplayName:             rpa.DisplayName,
+		OwnerContact:            rpa.OwnerContact,
+		CreatedAt:               rpa.CreatedAt,
+		UpdatedAt:               rpa.UpdatedAt,
+		Disabled:                rpa.Disabled,
+		AllowedOperations:       rpa.AllowedOperations,
+		MaxPermitRange:          rpa.MaxPermitRange,
+		AllowedIdentityDomains:  rpa.AllowedIdentityDomains,
+		CertificateFingerprints: rpa.CertificateFingerprints,
+		Metadata:                rpa.Metadata,
+		Revision:                rpa.Revision,
+	}
+	for _, key := range rpa.Keys {
+		keyResponse := api.RPAKeyResponse{KeyID: key.ID, Key: base64.URLEncoding.EncodeToString(key.Key), NotBefore: key.NotBefore}
+		if !key.ExpiresAt.IsZero() {
+			expiresAt := key.ExpiresAt
+			keyResponse.ExpiresAt = &expiresAt
+		}
+		response.Keys = append(response.Keys, keyResponse)
+	}
+	return response
+}
 
+//Removes the keys from the response so that it does not allow signing requests as the application
+func redactRPAKeys(response api.RelyingPartyApplicationResponse) api.RelyingPartyApplicationResponse {
+	response.Application_KEY = ""
+	keys := make([]api.RPAKeyResponse, len(response.Keys))
+	for i, key := range response.Keys {
+		key.Key = ""
+		keys[i] = key
+	}
+	response.Keys = keys
+	return response
+}
+
+func rpaFromRequest(appID string, request api.RelyingPartyApplicationRequest) storage.RelyingPartyApplication {
+	return storage.RelyingPartyApplication{
+		Application_ID:          appID,
+		KeyType:                 request.KeyType,
+		DisplayName:             request.DisplayName,
+		OwnerContact:            request.OwnerContact,
+		Disabled:                request.Disabled,
+		AllowedOperations:       request.AllowedOperations,
+		MaxPermitRange:          request.MaxPermitRange,
+		AllowedIdentityDomains:  request.AllowedIdentityDomains,
+		CertificateFingerprints: request.CertificateFingerprints,
+		Metadata:                request.Metadata,
+		Revision:                request.Revision,
+	}
 }
 
-func getAllRPAsHandler(w http.ResponseWriter, r *http.Request) {
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) getAllRPAsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("serving get /rpas")
 	log.Println(r.UserAgent())
 
+	rpas, err := apiServer.appStorage.GetAllRPAs(r.Context())
+	if err != nil {
+		log.Println("Error while listing RPAs ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	fmt.Println("App list ", appStorage.GetAllRPAs())
 	var apps []api.RelyingPartyApplicationResponse
-	for _, app := range appStorage.GetAllRPAs() {
+	for _, app := range rpas {
 		apps = append(apps, api.RelyingPartyApplicationResponse{Application_ID: app.Application_ID})
 	}
 	json.NewEncoder(w).Encode(apps)
 
 }
 
-func getRPAHandler(w http.ResponseWriter, r *http.Request) {
+//Reads a relying party application. Viewers get the IDs and validity of its keys, only operators get the keys
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		404                  RPA [appid] not found
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) getRPAHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("serving get /rpa")
 	log.Println(r.UserAgent())
 	vars := mux.Vars(r)
 	appID := vars["appid"]
 
+	rpa, err := apiServer.appStorage.GetRPA(r.Context(), appID)
+	if err != nil {
+		log.Println("Error while reading RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	response := rpaResponse(rpa)
+	if credential, ok := adminCredentialFrom(r.Context()); !ok || !credential.allows(RoleOperator) {
+		response = redactRPAKeys(response)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	appKey := appStorage.GetRPA(appID).Application_KEY
-	appKeyEncoded := base64.URLEncoding.EncodeToString(appKey)
-	app := api.RelyingPartyApplicationResponse{Application_ID: appID, Application_KEY: appKeyEncoded}
-	json.NewEncoder(w).Encode(app)
+	json.NewEncoder(w).Encode(response)
 
 }
-func registerRPAHandler(w http.ResponseWriter, r *http.Request) {
+
+//Registers a relying party application with its display name, owner contact, capabilities and metadata. The D-TA
+//generates a shared key unless KeyType is ed25519, then the application signs its requests with the private key of
+//PublicKey
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		201                  Created
+//		400                  Invalid request
+//		409                  RPA [appid] already exists
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) registerRPAHandler(w http.ResponseWriter, r *http.Request) {
 	log.Println("serving post /rpa")
 	log.Println(r.UserAgent())
 
-	var rpApp storage.RelyingPartyApplication
-	err := json.NewDecoder(r.Body).Decode(&rpApp)
+	var request api.RelyingPartyApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Application_ID == "" {
+		log.Println("Invalid RPA registration request")
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Invalid request")
+		return
+	}
+
+	rpa := rpaFromRequest(request.Application_ID, request)
+	if err := apiServer.validateRPA(rpa); err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+	publicKey, err := rpaPublicKey(request.KeyType, request.PublicKey)
 	if err != nil {
-		log.Println("Error while decoding input", err.Error())
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+	rpa.Application_KEY = publicKey
+	rpa, err = apiServer.appStorage.RegisterRPA(r.Context(), rpa)
+	if err != nil {
+		log.Println("Error while registering RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rpaResponse(rpa))
+
+}
+
+//Replaces the display name, owner contact, capabilities and metadata of a relying party application. Revision must
+//be the revision which was read, so that concurrent updates are detected
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Invalid request
+//		404                  RPA [appid] not found
+//		409                  RPA [appid] is at revision [revision]
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) updateRPAHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving put /rpa")
+	log.Println(r.UserAgent())
+	vars := mux.Vars(r)
+	appID := vars["appid"]
+
+	var request api.RelyingPartyApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Revision == 0 {
+		log.Println("Invalid RPA update request")
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Invalid request")
+		return
 	}
 
-	appStorage.RegisterRPA(rpApp)
+	rpa := rpaFromRequest(appID, request)
+	if err := apiServer.validateRPA(rpa); err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+	rpa, err := apiServer.appStorage.UpdateRPA(r.Context(), rpa)
+	if err != nil {
+		log.Println("Error while updating RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rpaResponse(rpa))
 
 }
 
-func deleteRPAHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("serving delete /rpa")
+//Changes the attributes of a relying party application which are given in the request. If Revision is given the
+//patch is refused when the application changed since that revision
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Invalid request
+//		404                  RPA [appid] not found
+//		409                  RPA [appid] is at revision [revision]
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) patchRPAHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving patch /rpa")
 	log.Println(r.UserAgent())
 	vars := mux.Vars(r)
 	appID := vars["appid"]
 
-	appStorage.DeleteRPA(appID)
-	w.WriteHeader(http.StatusOK)
+	var patch api.RelyingPartyApplicationPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		log.Println("Invalid RPA patch request")
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Invalid request")
+		return
+	}
+	rpa, err := apiServer.appStorage.GetRPA(r.Context(), appID)
+	if err != nil {
+		log.Println("Error while reading RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	if patch.Revision != 0 {
+		rpa.Revision = patch.Revision
+	}
+	if patch.DisplayName != nil {
+		rpa.DisplayName = *patch.DisplayName
+	}
+	if patch.OwnerContact != nil {
+		rpa.OwnerContact = *patch.OwnerContact
+	}
+	if patch.Disabled != nil {
+		rpa.Disabled = *patch.Disabled
+	}
+	if patch.AllowedOperations != nil {
+		rpa.AllowedOperations = *patch.AllowedOperations
+	}
+	if patch.MaxPermitRange != nil {
+		rpa.MaxPermitRange = *patch.MaxPermitRange
+	}
+	if patch.AllowedIdentityDomains != nil {
+		rpa.AllowedIdentityDomains = *patch.AllowedIdentityDomains
+	}
+	if patch.CertificateFingerprints != nil {
+		rpa.CertificateFingerprints = *patch.CertificateFingerprints
+	}
+	if patch.Metadata != nil {
+		rpa.Metadata = *patch.Metadata
+	}
+	if err := apiServer.validateRPA(rpa); err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	rpa, err = apiServer.appStorage.UpdateRPA(r.Context(), rpa)
+	if err != nil {
+		log.Println("Error while patching RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rpaResponse(rpa))
 
 }
 
-//Sets error code and error message as a JSON
-func sendError(errorCode int, errorMessage interface{}, w http.ResponseWriter) {
-	w.WriteHeader(errorCode)
-	json.NewEncoder(w).Encode(errorMessage)
+//Rolls a new key of a relying party application. The new key is valid from NotBefore, or from now if it is not given,
+//and becomes Application_KEY from then on. The previous keys still verify requests during server.rpa.keyGracePeriod, so clients
+//can switch to the new key one by one. Applications with Ed25519 keys give their new public key
+//	URL structure
+//		/v1/rpa/{appid}/keys
+//	HTTP Request Method
+//		POST
+//	Request
+//		{
+//			"NotBefore" : "<optional RFC 3339 time>",
+//			"PublicKey" : "<base64 url encoded Ed25519 public key, only for ed25519 keys>"
+//		}
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		400                  Invalid request
+//		404                  RPA [appid] not found
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) rollRPAKeyHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving post /rpa/keys")
+	log.Println(r.UserAgent())
+	vars := mux.Vars(r)
+	appID := vars["appid"]
+
+	var request api.RPAKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		log.Println("Invalid RPA key request")
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, "Invalid request")
+		return
+	}
+	rpa, err := apiServer.appStorage.GetRPA(r.Context(), appID)
+	if err != nil {
+		log.Println("Error while reading RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	publicKey, err := rpaPublicKey(rpa.KeyType, request.PublicKey)
+	if err != nil {
+		sendError(w, r, http.StatusBadRequest, api.ErrorInvalidRequest, err.Error())
+		return
+	}
+	rpa, err = apiServer.appStorage.RotateRPAKey(r.Context(), appID, publicKey, request.NotBefore, apiServer.rpaKeyGracePeriod)
+	if err != nil {
+		log.Println("Error while rolling RPA key ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	log.Println("Rolled key ", rpa.Keys[len(rpa.Keys)-1].ID, " of ", appID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rpaResponse(rpa))
+
+}
+
+//	Status-Codes and Response-Phrases
+//		Status-Code          Response-Phrase
+//		200                  OK
+//		404                  RPA [appid] not found
+//		503                  RPA storage is unavailable
+func (apiServer *ApiServer) deleteRPAHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("serving delete /rpa")
+	log.Println(r.UserAgent())
+	vars := mux.Vars(r)
+	appID := vars["appid"]
+
+	if err := apiServer.appStorage.DeleteRPA(r.Context(), appID); err != nil {
+		log.Println("Error while deleting RPA ", err.Error())
+		sendStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
 }
@@ -21,14 +21,23 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"github.com/ajanthan/apache-milagro-dta"
 	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/policy"
+	dtasignature "github.com/ajanthan/apache-milagro-dta/signature"
 	"github.com/ajanthan/apache-milagro-dta/storage"
 	"github.com/ajanthan/apache-milagro-dta/utils"
-	"github.com/ajanthan/milagro/dta/signature"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -37,11 +46,19 @@ import (
 func TestServerAPI_Complete(t *testing.T) {
 
 	apiServer := ApiServer{}
+	adminToken := "admin-token"
 	go func() {
-		apiServer.Bootstrap()
+		conf := config.Config{}
+		conf.ParseDTAConfigFile()
+		conf.SetAdminTokens([]config.AdminTokenConfig{{Name: "test", SHA256: HashAdminToken(adminToken), Role: RoleOperator}})
+		apiServer.BootstrapWithConfig(conf)
 	}()
 
-	time.Sleep(10 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := apiServer.WaitReady(ctx); err != nil {
+		t.Fatal("D-TA did not become ready ", err.Error())
+	}
 
 	httpClient := &http.Client{
 		Timeout: time.Second * 10,
@@ -58,12 +75,14 @@ func TestServerAPI_Complete(t *testing.T) {
 		t.FailNow()
 	}
 
-	if response, err := httpClient.Post("http://0.0.0.0:8088/rpa", "application/json", buffer); err != nil {
+	request, _ := http.NewRequest(http.MethodPost, "http://0.0.0.0:8088/v1/rpa", buffer)
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	if response, err := httpClient.Do(request); err != nil {
 		t.Error("Error in registering the app ", err.Error())
 		t.FailNow()
 	} else {
 
-		if response.StatusCode != 200 {
+		if response.StatusCode != http.StatusCreated {
 			t.Error("Registering the app is failed")
 			t.FailNow()
 		}
@@ -72,7 +91,9 @@ func TestServerAPI_Complete(t *testing.T) {
 	//Step 2: Getting application key
 
 	registeredApp := api.RelyingPartyApplicationResponse{}
-	if response, err := httpClient.Get("http://0.0.0.0:8088/rpa/" + appID); err != nil {
+	request, _ = http.NewRequest(http.MethodGet, "http://0.0.0.0:8088/v1/rpa/"+appID, nil)
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	if response, err := httpClient.Do(request); err != nil {
 		t.Error("Error in getting the app ", err.Error())
 		t.FailNow()
 	} else {
@@ -88,12 +109,12 @@ func TestServerAPI_Complete(t *testing.T) {
 	}
 
 	appKey, _ := base64.URLEncoding.DecodeString(registeredApp.Application_KEY)
-	signature := signature.CreateSignature(appKey, appID)
+	signature := dtasignature.CreateSignature(appKey, appID)
 	encodedSignature := base64.URLEncoding.EncodeToString(signature)
 
 	//Step 3: Getting Server key
 	serverSecretResponse := api.ServerSecretResponse{}
-	if response, err := httpClient.Get(fmt.Sprintf("http://0.0.0.0:8088/serverSecret?app_id=%s&signature=%s", appID, encodedSignature)); err != nil {
+	if response, err := httpClient.Get(fmt.Sprintf("http://0.0.0.0:8088/v1/serverSecret?app_id=%s&signature=%s", appID, encodedSignature)); err != nil {
 		t.Error("Error in getting M-Pin server secret ", err.Error())
 		t.FailNow()
 	} else {
@@ -114,7 +135,7 @@ func TestServerAPI_Complete(t *testing.T) {
 
 	clientSecretResponse := api.ClientSecretResponse{}
 
-	if response, err := httpClient.Get(fmt.Sprintf("http://0.0.0.0:8088/clientSecret?app_id=%s&client_id=%s&signature=%s", appID, clientID, encodedSignature)); err != nil {
+	if response, err := httpClient.Get(fmt.Sprintf("http://0.0.0.0:8088/v1/clientSecret?app_id=%s&client_id=%s&signature=%s", appID, clientID, encodedSignature)); err != nil {
 		t.Error("Error in getting M-Pin client  secret ", err.Error())
 		t.FailNow()
 	} else {
@@ -133,7 +154,7 @@ func TestServerAPI_Complete(t *testing.T) {
 
 	//Step 5: Getting TimePermit
 	timePermitResponse := api.TimePermitResponse{}
-	if response, err := httpClient.Get(fmt.Sprintf("http://0.0.0.0:8088/timePermit?app_id=%s&client_id=%s&signature=%s", appID, clientID, encodedSignature)); err != nil {
+	if response, err := httpClient.Get(fmt.Sprintf("http://0.0.0.0:8088/v1/timePermit?app_id=%s&client_id=%s&signature=%s", appID, clientID, encodedSignature)); err != nil {
 		t.Error("Error in getting M-Pin time permit ", err.Error())
 		t.FailNow()
 	} else {
@@ -168,8 +189,243 @@ func TestServerAPI_Complete(t *testing.T) {
 		t.FailNow()
 	}
 
-	utils.ValidateMpin(ss, cs, tp, clientID, 4973)
+	if err := utils.ValidateMpin(ss, cs, tp, clientID, 4973); err != nil {
+		t.Error("M-Pin authentication failed ", err.Error())
+	}
 
 	apiServer.StopServer()
 
 }
+
+func TestParseTimePermitRange(t *testing.T) {
+	from, to, err := parseTimePermitRange("2019-01-30", "2019-02-02", 31)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if to-from != 3 {
+		t.Error("Expected 4 days in the range but got ", to-from+1)
+	}
+	if epochDayToDate(from) != "2019-01-30" || epochDayToDate(to) != "2019-02-02" {
+		t.Error("Epoch days should convert back to the same dates ", epochDayToDate(from), epochDayToDate(to))
+	}
+	if _, _, err := parseTimePermitRange("2019-02-02", "2019-01-30", 31); err == nil {
+		t.Error("Range ending before it starts should be rejected")
+	}
+	if _, _, err := parseTimePermitRange("2019-01-01", "2019-03-01", 31); err == nil {
+		t.Error("Range longer than the maximum should be rejected")
+	}
+	if _, _, err := parseTimePermitRange("30/01/2019", "2019-02-02", 31); err == nil {
+		t.Error("Invalid date format should be rejected")
+	}
+	if _, _, err := parseTimePermitRange("1969-12-31", "1970-01-01", 31); err == nil {
+		t.Error("Date before 1970-01-01 should be rejected")
+	}
+}
+
+func TestTimePermitsHandler(t *testing.T) {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	dTA := &dta.DTA{}
+	if err := dTA.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	appStorage := &storage.InMemoryRPAManager{}
+	appStorage.Init(context.Background())
+	rpa, err := appStorage.RegisterRPA(context.Background(), storage.RelyingPartyApplication{Application_ID: "appid0001"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	limited, err := appStorage.RegisterRPA(context.Background(), storage.RelyingPartyApplication{Application_ID: "appid0002", MaxPermitRange: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	apiServer := &ApiServer{dTA: dTA, signatureVerifier: dtasignature.AESSignatureVerifier{}, appStorage: appStorage, maxTimePermitRange: 7}
+	router, _ := apiServer.newRouters(false)
+	serve := func(app storage.RelyingPartyApplication, from string, to string) *httptest.ResponseRecorder {
+		query := url.Values{}
+		query.Set("app_id", app.Application_ID)
+		query.Set("client_id", "user@apache.org")
+		query.Set("from", from)
+		query.Set("to", to)
+		query.Set("signature", base64.URLEncoding.EncodeToString(dtasignature.CreateSignature(app.Application_KEY, app.Application_ID)))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/timePermits?"+query.Encode(), nil))
+		return recorder
+	}
+
+	recorder := serve(rpa, "2020-01-01", "2020-01-03")
+	if recorder.Code != http.StatusOK {
+		t.Fatal("Time permits should be issued but got ", recorder.Code, recorder.Body.String())
+	}
+	var response api.TimePermitsResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(response.TimePermits) != 3 {
+		t.Fatal("Expected 3 time permits but got ", len(response.TimePermits))
+	}
+	for i, permit := range response.TimePermits {
+		if permit.EpochDay != 18262+i || permit.Date != epochDayToDate(18262+i) || permit.TimePermit == "" {
+			t.Errorf("Time permit %d should be for epoch day %d but got %+v", i, 18262+i, permit)
+		}
+	}
+
+	expected := []struct {
+		app      storage.RelyingPartyApplication
+		from, to string
+	}{
+		{rpa, "2020-13-01", "2020-01-03"},
+		{rpa, "2020-01-01", "03/01/2020"},
+		{rpa, "2020-01-03", "2020-01-01"},
+		{rpa, "1969-12-31", "1970-01-01"},
+		{rpa, "2020-01-01", "2020-01-08"},
+		{limited, "2020-01-01", "2020-01-03"},
+	}
+	for _, test := range expected {
+		if recorder := serve(test.app, test.from, test.to); recorder.Code != http.StatusBadRequest {
+			t.Errorf("Range %s to %s of %s should be refused but got %d", test.from, test.to, test.app.Application_ID, recorder.Code)
+		}
+	}
+	if recorder := serve(rpa, "2020-01-01", "2020-01-07"); recorder.Code != http.StatusOK {
+		t.Error("Range of server.maxTimePermitRange days should be issued but got ", recorder.Code)
+	}
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	rpa := storage.RelyingPartyApplication{AllowedOperations: []string{storage.OperationClientSecret}, AllowedIdentityDomains: []string{"apache.org"}}
+	if err := checkCapabilities(rpa, storage.OperationClientSecret, "user@apache.org"); err != nil {
+		t.Error("Allowed operation and domain should pass ", err)
+	}
+	if err := checkCapabilities(rpa, storage.OperationServerSecret, ""); err == nil {
+		t.Error("Operation which is not allowed should be refused")
+	}
+	if _, ok := checkCapabilities(rpa, storage.OperationClientSecret, "user@example.com").(*policy.PolicyViolation); !ok {
+		t.Error("Identity outside the allowed domains should be a policy violation")
+	}
+	if err := checkCapabilities(storage.RelyingPartyApplication{}, storage.OperationTimePermit, "user@example.com"); err != nil {
+		t.Error("Application without capabilities should allow everything ", err)
+	}
+	rpa.Disabled = true
+	if err := checkCapabilities(rpa, storage.OperationClientSecret, "user@apache.org"); err == nil {
+		t.Error("Disabled application should be refused")
+	}
+}
+
+func TestValidateRPA(t *testing.T) {
+	apiServer := ApiServer{maxTimePermitRange: 31}
+	if err := apiServer.validateRPA(storage.RelyingPartyApplication{AllowedOperations: storage.Operations(), MaxPermitRange: 7, AllowedIdentityDomains: []string{"apache.org"}}); err != nil {
+		t.Error(err.Error())
+	}
+	if err := apiServer.validateRPA(storage.RelyingPartyApplication{AllowedOperations: []string{"rotate"}}); err == nil {
+		t.Error("Unknown operation should be rejected")
+	}
+	if err := apiServer.validateRPA(storage.RelyingPartyApplication{MaxPermitRange: 32}); err == nil {
+		t.Error("Permit range above the server maximum should be rejected")
+	}
+	if err := apiServer.validateRPA(storage.RelyingPartyApplication{AllowedIdentityDomains: []string{"user@apache.org"}}); err == nil {
+		t.Error("Identity instead of a domain should be rejected")
+	}
+	if err := apiServer.validateRPA(storage.RelyingPartyApplication{CertificateFingerprints: []string{strings.Repeat("ab", 32)}}); err != nil {
+		t.Error(err.Error())
+	}
+	if err := apiServer.validateRPA(storage.RelyingPartyApplication{CertificateFingerprints: []string{"AB:CD"}}); err == nil {
+		t.Error("Certificate fingerprint which is not a hex SHA-256 should be rejected")
+	}
+}
+
+func TestVerifyAppSignature(t *testing.T) {
+	now := time.Now()
+	oldKey := storage.RPAKey{ID: "1", Key: bytes.Repeat([]byte{1}, 16), ExpiresAt: now.Add(time.Hour)}
+	newKey := storage.RPAKey{ID: "2", Key: bytes.Repeat([]byte{2}, 16)}
+	rpa := storage.RelyingPartyApplication{Application_ID: "appid0001", Keys: []storage.RPAKey{oldKey, newKey}}
+	apiServer := ApiServer{signatureVerifier: dtasignature.AESSignatureVerifier{}}
+	request := func(keyID string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/serverSecret?key_id="+keyID, nil)
+	}
+
+	oldSignature := dtasignature.CreateSignature(oldKey.Key, rpa.Application_ID)
+	if !apiServer.verifyAppSignature(request(""), rpa, oldSignature) || !apiServer.verifyAppSignature(request("1"), rpa, oldSignature) {
+		t.Error("Previous key should verify during the grace period")
+	}
+	if apiServer.verifyAppSignature(request("2"), rpa, oldSignature) {
+		t.Error("Signature should be verified only with the named key")
+	}
+	rpa.Keys[0].ExpiresAt = now.Add(-time.Second)
+	if apiServer.verifyAppSignature(request(""), rpa, oldSignature) {
+		t.Error("Expired key should not verify")
+	}
+	if !apiServer.verifyAppSignature(request(""), rpa, dtasignature.CreateSignature(newKey.Key, rpa.Application_ID)) {
+		t.Error("New key should verify")
+	}
+}
+
+func TestVerifyAppSignature_HMAC(t *testing.T) {
+	key := storage.RPAKey{ID: "1", Key: bytes.Repeat([]byte{1}, 16)}
+	rpa := storage.RelyingPartyApplication{Application_ID: "appid0001", Keys: []storage.RPAKey{key}}
+	apiServer := ApiServer{signatureVerifier: dtasignature.NewHMACSignatureVerifier(time.Minute, 10, 1024)}
+
+	query := url.Values{"app_id": {rpa.Application_ID}, "client_id": {"user@apache.org"}}
+	requestSignature, err := dtasignature.SignRequest(key.Key, http.MethodGet, "/clientSecret", query, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !apiServer.verifyAppSignature(httptest.NewRequest(http.MethodGet, "/clientSecret?"+query.Encode(), nil), rpa, requestSignature) {
+		t.Fatal("Signed request should be verified")
+	}
+	if apiServer.verifyAppSignature(httptest.NewRequest(http.MethodGet, "/clientSecret?"+query.Encode(), nil), rpa, requestSignature) {
+		t.Error("Replayed request should be refused")
+	}
+	query.Set("client_id", "other@apache.org")
+	if apiServer.verifyAppSignature(httptest.NewRequest(http.MethodGet, "/clientSecret?"+query.Encode(), nil), rpa, requestSignature) {
+		t.Error("Signature should not verify a request for another client ID")
+	}
+}
+
+func TestVerifyAppSignature_Ed25519(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	rpa := storage.RelyingPartyApplication{
+		Application_ID: "appid0001",
+		KeyType:        storage.KeyTypeEd25519,
+		Keys:           []storage.RPAKey{{ID: "1", Key: publicKey}},
+	}
+	apiServer := ApiServer{
+		signatureVerifier: dtasignature.AESSignatureVerifier{},
+		publicKeyVerifier: dtasignature.NewEd25519SignatureVerifier(time.Minute, 10, 1024),
+	}
+
+	query := url.Values{"app_id": {rpa.Application_ID}}
+	requestSignature, err := dtasignature.SignRequestEd25519(privateKey, http.MethodGet, "/serverSecret", query, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !apiServer.verifyAppSignature(httptest.NewRequest(http.MethodGet, "/serverSecret?"+query.Encode(), nil), rpa, requestSignature) {
+		t.Fatal("Request signed with the private key should be verified")
+	}
+	if apiServer.verifyAppSignature(httptest.NewRequest(http.MethodGet, "/serverSecret", nil), rpa, dtasignature.CreateSignature(publicKey[:16], rpa.Application_ID)) {
+		t.Error("Public key should not be usable as a shared key")
+	}
+}
+
+func TestRPAPublicKey(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	encoded := base64.URLEncoding.EncodeToString(publicKey)
+	if key, err := rpaPublicKey(storage.KeyTypeEd25519, encoded); err != nil || !bytes.Equal(key, publicKey) {
+		t.Error("Public key should be decoded ", err)
+	}
+	if key, err := rpaPublicKey("", ""); err != nil || key != nil {
+		t.Error("Shared keys should not need a public key ", err)
+	}
+	invalid := []struct{ keyType, publicKey string }{
+		{storage.KeyTypeEd25519, ""},
+		{storage.KeyTypeEd25519, base64.URLEncoding.EncodeToString(publicKey[1:])},
+		{storage.KeyTypeShared, encoded},
+		{"rsa", encoded},
+	}
+	for _, request := range invalid {
+		if _, err := rpaPublicKey(request.keyType, request.publicKey); err == nil {
+			t.Errorf("Public key %q of %s keys should be refused", request.publicKey, request.keyType)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/pkg/errors"
+)
+
+//Server certificate and client CA bundle of the HTTPS server. They are reloaded on SIGHUP, so that certificates can be
+//renewed without restarting the D-TA
+type tlsCertificates struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	lock         sync.RWMutex
+	certificate  *tls.Certificate
+	clientCAs    *x509.CertPool
+}
+
+func newTLSCertificates(certFile string, keyFile string, clientCAFile string) (*tlsCertificates, error) {
+	certificates := &tlsCertificates{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	return certificates, certificates.load()
+}
+
+//Reads the files again. The previous certificates are kept if any of the files is invalid
+func (certificates *tlsCertificates) load() error {
+	certificate, err := tls.LoadX509KeyPair(certificates.certFile, certificates.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "Error in loading the server certificate")
+	}
+	var clientCAs *x509.CertPool
+	if certificates.clientCAFile != "" {
+		bundle, err := ioutil.ReadFile(certificates.clientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "Error in reading the client CA bundle")
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("No certificates in %s", certificates.clientCAFile)
+		}
+	}
+	certificates.lock.Lock()
+	defer certificates.lock.Unlock()
+	certificates.certificate = &certificate
+	certificates.clientCAs = clientCAs
+	return nil
+}
+
+//Returns the TLS config of the server. Every handshake uses the certificates which were loaded last
+func (certificates *tlsCertificates) config(clientAuth tls.ClientAuthType) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificates.lock.RLock()
+			defer certificates.lock.RUnlock()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certificates.certificate},
+			}
+			if clientAuth != tls.NoClientCert {
+				config.ClientAuth = clientAuth
+				config.ClientCAs = certificates.clientCAs
+			}
+			return config, nil
+		},
+	}
+}
+
+//Reloads the certificates on SIGHUP until stop is closed
+func (certificates *tlsCertificates) reloadOnSIGHUP(stop <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				if err := certificates.load(); err != nil {
+					log.Println("Error while reloading the TLS certificates ", err.Error())
+				} else {
+					log.Println("Reloaded the TLS certificates")
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+//Maps server.tls.clientAuth to the TLS client authentication. Client certificates are not requested without a client
+//CA bundle, so required client authentication is refused rather than turned off
+func tlsClientAuth(clientAuth string, clientCAFile string) (tls.ClientAuthType, error) {
+	switch clientAuth {
+	case "none":
+		return tls.NoClientCert, nil
+	case "", "optional":
+		if clientCAFile == "" {
+			return tls.NoClientCert, nil
+		}
+		return tls.VerifyClientCertIfGiven, nil
+	case "required":
+		if clientCAFile == "" {
+			return tls.NoClientCert, errors.New("TLS client authentication required needs server.tls.clientCAFile")
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("Unknown TLS client authentication %s", clientAuth)
+}
+
+//Returns the client certificate of the request if it is verified by a client CA
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+//Returns the subject common name, if it is set, and the DNS, email and URI subject alternative names of the certificate
+func certificateNames(certificate *x509.Certificate) []string {
+	var names []string
+	if certificate.Subject.CommonName != "" {
+		names = append(names, certificate.Subject.CommonName)
+	}
+	names = append(names, certificate.DNSNames...)
+	names = append(names, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+//Returns the hex SHA-256 of the DER encoded certificate, as in CertificateFingerprints of relying party applications
+func certificateFingerprint(certificate *x509.Certificate) string {
+	fingerprint := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+//Returns true if the certificate authenticates the relying party application, either by its subject common name or
+//subject alternative names in server.tls.clientIdentities or by its fingerprint registered for the application
+func (apiServer *ApiServer) certificateAuthenticates(certificate *x509.Certificate, rpa storage.RelyingPartyApplication) bool {
+	for _, name := range certificateNames(certificate) {
+		if appID, ok := apiServer.clientIdentities[name]; ok && appID == rpa.Application_ID {
+			return true
+		}
+	}
+	fingerprint := certificateFingerprint(certificate)
+	for _, registered := range rpa.CertificateFingerprints {
+		if strings.EqualFold(registered, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+//Authenticates a request of the relying party application by its verified client certificate or else by the
+//signature of the request
+func (apiServer *ApiServer) authenticateApp(r *http.Request, rpa storage.RelyingPartyApplication, appSignature []byte) bool {
+	if certificate := clientCertificate(r); certificate != nil && apiServer.certificateAuthenticates(certificate, rpa) {
+		return true
+	}
+	return apiServer.verifyAppSignature(r, rpa, appSignature)
+}
@@ -0,0 +1,198 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/storage"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+//Issues a certificate signed by the issuer, or a self signed CA certificate if the issuer is nil
+func newTestCertificate(t *testing.T, template *x509.Certificate, issuer *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	return testCertificate{certificate: certificate, key: key}
+}
+
+func (certificate testCertificate) write(t *testing.T, certFile string, keyFile string) {
+	keyDER, _ := x509.MarshalECPrivateKey(certificate.key)
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.certificate.Raw}), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if keyFile != "" {
+		if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+}
+
+func (certificate testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{certificate.certificate.Raw}, PrivateKey: certificate.key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dta-tls")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, caFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.pem")
+
+	ca := newTestCertificate(t, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "D-TA test CA"},
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+	ca.write(t, caFile, "")
+	serverTemplate := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{SerialNumber: big.NewInt(serial), Subject: pkix.Name{CommonName: "dta"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+	}
+	newTestCertificate(t, serverTemplate(2), &ca).write(t, certFile, keyFile)
+	mappedClient := newTestCertificate(t, &x509.Certificate{SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "client"},
+		DNSNames: []string{"rpa.example.org"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, &ca)
+	registeredClient := newTestCertificate(t, &x509.Certificate{SerialNumber: big.NewInt(4), Subject: pkix.Name{CommonName: "other"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, &ca)
+
+	certificates, err := newTLSCertificates(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rpa := storage.RelyingPartyApplication{
+		Application_ID:          "appid0001",
+		CertificateFingerprints: []string{certificateFingerprint(registeredClient.certificate)},
+	}
+	apiServer := ApiServer{clientIdentities: map[string]string{"rpa.example.org": "appid0001", "client": "appid0002"}}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if certificate := clientCertificate(r); certificate == nil || !apiServer.certificateAuthenticates(certificate, rpa) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	server.Listener = tls.NewListener(server.Listener, certificates.config(tls.VerifyClientCertIfGiven))
+	server.Start()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	get := func(clientCertificates ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: clientCertificates}}}
+		return client.Get("https://" + server.Listener.Addr().String() + "/serverSecret")
+	}
+
+	for _, client := range []testCertificate{mappedClient, registeredClient} {
+		response, err := get(client.tlsCertificate())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Error("Client certificate should authenticate the application ", client.certificate.Subject.CommonName)
+		}
+	}
+	if response, err := get(); err != nil {
+		t.Error("Client certificate should be optional ", err)
+	} else if response.Body.Close(); response.StatusCode != http.StatusUnauthorized {
+		t.Error("Request without a client certificate should not be authenticated")
+	}
+	untrusted := newTestCertificate(t, &x509.Certificate{SerialNumber: big.NewInt(5), Subject: pkix.Name{CommonName: "rpa.example.org"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, nil)
+	if response, err := get(untrusted.tlsCertificate()); err == nil {
+		if response.Body.Close(); response.StatusCode != http.StatusUnauthorized {
+			t.Error("Client certificate of another CA should not authenticate the application")
+		}
+	}
+
+	newTestCertificate(t, serverTemplate(6), &ca).write(t, certFile, keyFile)
+	if err := certificates.load(); err != nil {
+		t.Fatal(err.Error())
+	}
+	response, err := get()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	response.Body.Close()
+	if serial := response.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 6 {
+		t.Error("Reloaded server certificate should be used, got serial ", serial)
+	}
+	if err := ioutil.WriteFile(certFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := certificates.load(); err == nil {
+		t.Error("Invalid certificate should not be loaded")
+	}
+	if _, err := get(); err != nil {
+		t.Error("Previous certificate should be kept when reloading fails ", err)
+	}
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	expected := map[string]tls.ClientAuthType{
+		"none":     tls.NoClientCert,
+		"":         tls.VerifyClientCertIfGiven,
+		"optional": tls.VerifyClientCertIfGiven,
+		"required": tls.RequireAndVerifyClientCert,
+	}
+	for clientAuth, clientAuthType := range expected {
+		if parsed, err := tlsClientAuth(clientAuth, "ca.pem"); err != nil || parsed != clientAuthType {
+			t.Errorf("Client authentication %q should be %v but got %v", clientAuth, clientAuthType, parsed)
+		}
+	}
+	if _, err := tlsClientAuth("request", "ca.pem"); err == nil {
+		t.Error("Unknown client authentication should be refused")
+	}
+	//Without a client CA bundle no client certificate can be verified
+	if parsed, err := tlsClientAuth("optional", ""); err != nil || parsed != tls.NoClientCert {
+		t.Errorf("Optional client authentication without CAs should request no certificate but got %v", parsed)
+	}
+	if _, err := tlsClientAuth("required", ""); err == nil {
+		t.Error("Required client authentication without CAs should be refused")
+	}
+}
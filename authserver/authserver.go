@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package authserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta"
+	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidInput         = errors.New("Invalid authentication input")
+	ErrUnknownSession       = errors.New("Unknown or expired authentication session")
+	ErrAuthenticationFailed = errors.New("Authentication failed")
+	ErrTooManySessions      = errors.New("Too many authentication sessions")
+)
+
+//Returns the (recombined) server secret of the given master secret version. Version 0 stands for the active version
+type ServerSecretSource func(keyVersion int) ([]byte, error)
+
+//Server side of the M-Pin authentication protocol. The client sends U and UT in the first pass and receives the
+//challenge Y, then sends V in the second pass. State between the passes is kept per session until it expires
+type AuthenticationServer struct {
+	serverSecrets ServerSecretSource
+	sessionTTL    time.Duration
+	maxSessions   int
+
+	rng     *amcl.RAND
+	rngLock sync.Mutex
+
+	lock     sync.Mutex
+	sessions map[string]*session
+}
+
+//State kept between the first and the second pass
+type session struct {
+	keyVersion int
+	date       int
+	hid        [dta.G1S]byte
+	htid       [dta.G1S]byte
+	y          [amcl.MPIN_EGS]byte
+	u          []byte
+	ut         []byte
+	expiresAt  time.Time
+}
+
+//Creates an authentication server which verifies clients against the server secrets of serverSecrets. The server
+//secret is looked up in every second pass, so that a rotated master secret is picked up. Sessions which do not complete
+//the second pass within sessionTTL are discarded, and no more than maxSessions are kept at a time
+func NewAuthenticationServer(serverSecrets ServerSecretSource, sessionTTL time.Duration, maxSessions int) (*AuthenticationServer, error) {
+	if serverSecrets == nil {
+		return nil, errors.New("Missing server secret source")
+	}
+	if maxSessions < 1 {
+		return nil, errors.New("Invalid maximum number of authentication sessions")
+	}
+	seed := make([]byte, 128)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, errors.Wrap(err, "Error in reading entropy from the OS")
+	}
+	rng := amcl.NewRAND()
+	rng.Seed(len(seed), seed)
+	return &AuthenticationServer{
+		serverSecrets: serverSecrets,
+		sessionTTL:    sessionTTL,
+		maxSessions:   maxSessions,
+		rng:           rng,
+		sessions:      make(map[string]*session),
+	}, nil
+}
+
+//First pass. Takes the client identity, U=x.H(ID) and UT=x.(H(ID)+H(date|H(ID))). UT is empty when the client does
+//not use time permits. keyVersion is the master secret version of the client secret, 0 for the active version.
+//Returns the session ID and the challenge Y for the second pass, or ErrTooManySessions if the maximum number of
+//sessions wait for the second pass
+func (authServer *AuthenticationServer) Pass1(clientID string, u []byte, ut []byte, keyVersion int) (string, []byte, error) {
+	if clientID == "" || len(u) != dta.G1S || (len(ut) != 0 && len(ut) != dta.G1S) || keyVersion < 0 {
+		return "", nil, ErrInvalidInput
+	}
+	s := &session{keyVersion: keyVersion, u: u, ut: ut, expiresAt: time.Now().Add(authServer.sessionTTL)}
+	if len(ut) != 0 {
+		s.date = amcl.MPIN_today()
+	}
+	amcl.MPIN_SERVER_1(s.date, []byte(clientID), s.hid[:], s.htid[:])
+
+	authServer.rngLock.Lock()
+	amcl.MPIN_RANDOM_GENERATE(authServer.rng, s.y[:])
+	authServer.rngLock.Unlock()
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		return "", nil, err
+	}
+	authServer.lock.Lock()
+	defer authServer.lock.Unlock()
+	authServer.removeExpiredSessions()
+	if len(authServer.sessions) >= authServer.maxSessions {
+		return "", nil, ErrTooManySessions
+	}
+	authServer.sessions[sessionID] = s
+	return sessionID, s.y[:], nil
+}
+
+//Second pass. Takes V=-(x+y).(client secret) of the session and returns nil if the client is authenticated. A session
+//can be used only once
+func (authServer *AuthenticationServer) Pass2(sessionID string, v []byte) error {
+	if len(v) != dta.G1S {
+		return ErrInvalidInput
+	}
+	authServer.lock.Lock()
+	s, ok := authServer.sessions[sessionID]
+	delete(authServer.sessions, sessionID)
+	authServer.lock.Unlock()
+	if !ok || time.Now().After(s.expiresAt) {
+		return ErrUnknownSession
+	}
+	serverSecret, err := authServer.serverSecrets(s.keyVersion)
+	if err != nil {
+		switch errors.Cause(err) {
+		case dta.ErrUnknownKeyVersion, dta.ErrExpiredKeyVersion:
+			return errors.Wrap(ErrAuthenticationFailed, err.Error())
+		}
+		return errors.Wrap(err, "Error in getting server secret")
+	}
+	if len(serverSecret) != dta.G2S {
+		return errors.New("Invalid server secret size")
+	}
+
+	var e, f [12 * amcl.MPIN_EFS]byte
+	rtn := amcl.MPIN_SERVER_2(s.date, s.hid[:], s.htid[:], s.y[:], serverSecret, s.u, s.ut, v, e[:], f[:])
+	if rtn != 0 {
+		return errors.Wrapf(ErrAuthenticationFailed, "M-Pin error %d", rtn)
+	}
+	return nil
+}
+
+//Number of sessions waiting for the second pass
+func (authServer *AuthenticationServer) SessionCount() int {
+	authServer.lock.Lock()
+	defer authServer.lock.Unlock()
+	return len(authServer.sessions)
+}
+
+//Must be called holding lock
+func (authServer *AuthenticationServer) removeExpiredSessions() {
+	now := time.Now()
+	for sessionID, s := range authServer.sessions {
+		if now.After(s.expiresAt) {
+			delete(authServer.sessions, sessionID)
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "Error in generating session ID")
+	}
+	return hex.EncodeToString(b), nil
+}
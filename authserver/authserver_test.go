@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package authserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	clientID = "test@apache.milagro.org"
+	pin      = 1234
+)
+
+//Client side of the protocol holding a token created with pin
+type testClient struct {
+	rng        *amcl.RAND
+	token      []byte
+	timePermit []byte
+}
+
+func newTestClient(t *testing.T) (*testClient, *dta.DTA) {
+	d := dta.DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	if err := d.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	hashedClientID := amcl.MPIN_HASH_ID([]byte(clientID))
+	clientSecret, err := d.IssueClientSecret(hashedClientID)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timePermit, err := d.IssueTimePermit(hashedClientID)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rtn := amcl.MPIN_EXTRACT_PIN([]byte(clientID), pin, clientSecret); rtn != 0 {
+		t.Fatal("Error in extracting PIN ", rtn)
+	}
+	rng := amcl.NewRAND()
+	seed := []byte("authserver test seed")
+	rng.Seed(len(seed), seed)
+	return &testClient{rng: rng, token: clientSecret, timePermit: timePermit}, &d
+}
+
+//Server secrets of the given D-TA
+func serverSecrets(d *dta.DTA) ServerSecretSource {
+	return func(keyVersion int) ([]byte, error) {
+		if keyVersion == 0 {
+			keyVersion = d.ActiveKeyVersion()
+		}
+		return d.IssueServerSecretWithVersion(keyVersion)
+	}
+}
+
+//Runs both passes against the authentication server with the given pin
+func (client *testClient) authenticate(t *testing.T, authServer *AuthenticationServer, pin int, keyVersion int) error {
+	var x [amcl.MPIN_EGS]byte
+	var sec, u, ut [dta.G1S]byte
+	if rtn := amcl.MPIN_CLIENT_1(amcl.MPIN_today(), []byte(clientID), client.rng, x[:], pin, client.token, sec[:], u[:], ut[:], client.timePermit); rtn != 0 {
+		t.Fatal("CLIENT_1 failed ", rtn)
+	}
+	sessionID, y, err := authServer.Pass1(clientID, u[:], ut[:], keyVersion)
+	if err != nil {
+		t.Fatal("Pass 1 failed ", err.Error())
+	}
+	if rtn := amcl.MPIN_CLIENT_2(x[:], y, sec[:]); rtn != 0 {
+		t.Fatal("CLIENT_2 failed ", rtn)
+	}
+	return authServer.Pass2(sessionID, sec[:])
+}
+
+func TestAuthenticationServer_Authenticate(t *testing.T) {
+	client, d := newTestClient(t)
+	authServer, err := NewAuthenticationServer(serverSecrets(d), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := client.authenticate(t, authServer, pin, 0); err != nil {
+		t.Error("Client should be authenticated ", err.Error())
+	}
+	if err := client.authenticate(t, authServer, pin+1, 0); errors.Cause(err) != ErrAuthenticationFailed {
+		t.Error("Wrong PIN should fail the authentication but got ", err)
+	}
+	if authServer.SessionCount() != 0 {
+		t.Error("Completed sessions should be removed")
+	}
+}
+
+func TestAuthenticationServer_RotatedMasterSecret(t *testing.T) {
+	client, d := newTestClient(t)
+	authServer, err := NewAuthenticationServer(serverSecrets(d), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := d.RotateMasterSecret(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := client.authenticate(t, authServer, pin, 0); errors.Cause(err) != ErrAuthenticationFailed {
+		t.Error("Client secret of the superseded version should fail against the active version but got ", err)
+	}
+	if err := client.authenticate(t, authServer, pin, 1); err != nil {
+		t.Error("Client secret of the superseded version should be authenticated with its version ", err.Error())
+	}
+	if err := client.authenticate(t, authServer, pin, 5); errors.Cause(err) != ErrAuthenticationFailed {
+		t.Error("Unknown key version should fail the authentication but got ", err)
+	}
+}
+
+func TestAuthenticationServer_MaxSessions(t *testing.T) {
+	_, d := newTestClient(t)
+	authServer, err := NewAuthenticationServer(serverSecrets(d), time.Minute, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	u := make([]byte, dta.G1S)
+	for i := 0; i < 2; i++ {
+		if _, _, err := authServer.Pass1(clientID, u, nil, 0); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if _, _, err := authServer.Pass1(clientID, u, nil, 0); err != ErrTooManySessions {
+		t.Error("Pass 1 should be refused at the maximum number of sessions but got ", err)
+	}
+	if authServer.SessionCount() != 2 {
+		t.Error("Refused pass 1 should not add a session")
+	}
+}
+
+func TestAuthenticationServer_Session(t *testing.T) {
+	_, d := newTestClient(t)
+	authServer, err := NewAuthenticationServer(serverSecrets(d), time.Millisecond, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	u := make([]byte, dta.G1S)
+	sessionID, _, err := authServer.Pass1(clientID, u, nil, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := authServer.Pass2(sessionID, make([]byte, dta.G1S)); err != ErrUnknownSession {
+		t.Error("Expired session should be rejected but got ", err)
+	}
+	if err := authServer.Pass2("unknown", make([]byte, dta.G1S)); err != ErrUnknownSession {
+		t.Error("Unknown session should be rejected but got ", err)
+	}
+	if _, _, err := authServer.Pass1(clientID, []byte("short"), nil, 0); err != ErrInvalidInput {
+		t.Error("Invalid U should be rejected but got ", err)
+	}
+	if _, _, err := authServer.Pass1(clientID, u, nil, -1); err != ErrInvalidInput {
+		t.Error("Negative key version should be rejected but got ", err)
+	}
+	if _, err := NewAuthenticationServer(nil, time.Minute, 10); err == nil {
+		t.Error("Missing server secret source should be rejected")
+	}
+	if _, err := NewAuthenticationServer(serverSecrets(d), time.Minute, 0); err == nil {
+		t.Error("Invalid maximum number of sessions should be rejected")
+	}
+}
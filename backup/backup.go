@@ -0,0 +1,215 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/pkg/errors"
+)
+
+const shareFileFormatVersion = 1
+
+//Content of a master secret share file
+type ShareFile struct {
+	FormatVersion int
+	//Version of the master secret in the storage it was backed up from
+	KeyVersion int
+	Threshold  int
+	Shares     int
+	Index      int
+	Share      string
+	CreatedAt  time.Time
+	//SHA-256 of the master secret to verify the restored secret
+	SecretChecksum string
+	//SHA-256 of the other fields to detect corrupted share files
+	Checksum string
+}
+
+//Splits the active master secret of the storage into k-of-n shares and writes each of them into its own file in dir.
+//Existing share files are never overwritten. Returns the file names
+func BackupMasterSecret(ctx context.Context, secretStorage storage.MasterSecretStorage, k int, n int, dir string) ([]string, error) {
+	masterSecrets, err := secretStorage.GetSecrets(ctx)
+	if storage.Kind(err) == storage.ErrNotFound {
+		return nil, errors.New("No master secret found in the storage")
+	}
+	if err != nil {
+		return nil, err
+	}
+	masterSecret := masterSecrets[len(masterSecrets)-1]
+	shares, err := Split(masterSecret.Secret[:], k, n)
+	if err != nil {
+		return nil, err
+	}
+	secretChecksum := sha256.Sum256(masterSecret.Secret[:])
+	createdAt := time.Now().UTC()
+	var fileNames []string
+	for _, share := range shares {
+		shareFile := ShareFile{
+			FormatVersion:  shareFileFormatVersion,
+			KeyVersion:     masterSecret.Version,
+			Threshold:      k,
+			Shares:         n,
+			Index:          int(share.Index),
+			Share:          base64.StdEncoding.EncodeToString(share.Data),
+			CreatedAt:      createdAt,
+			SecretChecksum: hex.EncodeToString(secretChecksum[:]),
+		}
+		fileName := filepath.Join(dir, fmt.Sprintf("master-secret-v%d-share-%d-of-%d.json", masterSecret.Version, share.Index, n))
+		if err := WriteShareFile(fileName, shareFile); err != nil {
+			return fileNames, err
+		}
+		fileNames = append(fileNames, fileName)
+	}
+	return fileNames, nil
+}
+
+//Rebuilds the master secret from the share files and stores it as the active master secret of the storage. Nothing
+//is written if the storage already has the secret as its active one
+func RestoreMasterSecret(ctx context.Context, secretStorage storage.MasterSecretStorage, fileNames []string) error {
+	var shareFiles []ShareFile
+	for _, fileName := range fileNames {
+		shareFile, err := ReadShareFile(fileName)
+		if err != nil {
+			return err
+		}
+		shareFiles = append(shareFiles, shareFile)
+	}
+	secret, err := CombineShareFiles(shareFiles)
+	if err != nil {
+		return err
+	}
+	latestVersion := 0
+	masterSecrets, err := secretStorage.GetSecrets(ctx)
+	switch storage.Kind(err) {
+	case nil:
+		activeSecret := masterSecrets[len(masterSecrets)-1]
+		if bytes.Equal(activeSecret.Secret[:], secret) {
+			return nil
+		}
+		latestVersion = activeSecret.Version
+	case storage.ErrNotFound:
+	default:
+		return err
+	}
+	return secretStorage.SetSecret(ctx, latestVersion+1, secret)
+}
+
+//Rebuilds the secret from the share files and verifies it against the secret checksum
+func CombineShareFiles(shareFiles []ShareFile) ([]byte, error) {
+	if len(shareFiles) == 0 {
+		return nil, errors.New("No share files given")
+	}
+	first := shareFiles[0]
+	var shares []Share
+	for _, shareFile := range shareFiles {
+		if shareFile.SecretChecksum != first.SecretChecksum || shareFile.Threshold != first.Threshold {
+			return nil, errors.New("Share files are from different backups")
+		}
+		data, err := base64.StdEncoding.DecodeString(shareFile.Share)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid share %d", shareFile.Index)
+		}
+		shares = append(shares, Share{Index: byte(shareFile.Index), Data: data})
+	}
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%d shares are needed but only %d given", first.Threshold, len(shares))
+	}
+	secret, err := Combine(shares)
+	if err != nil {
+		return nil, err
+	}
+	secretChecksum := sha256.Sum256(secret)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(secretChecksum[:])), []byte(first.SecretChecksum)) != 1 {
+		return nil, errors.New("Restored secret does not match the checksum of the backup")
+	}
+	return secret, nil
+}
+
+//Writes the share file with its checksum. Fails if the file exists
+func WriteShareFile(fileName string, shareFile ShareFile) error {
+	checksum, err := shareFileChecksum(shareFile)
+	if err != nil {
+		return err
+	}
+	shareFile.Checksum = checksum
+	content, err := json.MarshalIndent(shareFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//Reads the share file and verifies its checksum
+func ReadShareFile(fileName string) (ShareFile, error) {
+	shareFile := ShareFile{}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return shareFile, err
+	}
+	if err := json.Unmarshal(content, &shareFile); err != nil {
+		return shareFile, errors.Wrapf(err, "Invalid share file %s", fileName)
+	}
+	if shareFile.FormatVersion != shareFileFormatVersion {
+		return shareFile, fmt.Errorf("Unsupported share file version %d in %s", shareFile.FormatVersion, fileName)
+	}
+	checksum, err := shareFileChecksum(shareFile)
+	if err != nil {
+		return shareFile, err
+	}
+	if checksum != shareFile.Checksum {
+		return shareFile, fmt.Errorf("Checksum mismatch in share file %s", fileName)
+	}
+	return shareFile, nil
+}
+
+func shareFileChecksum(shareFile ShareFile) (string, error) {
+	shareFile.Checksum = ""
+	content, err := json.Marshal(shareFile)
+	if err != nil {
+		return "", err
+	}
+	checksum := sha256.Sum256(content)
+	return hex.EncodeToString(checksum[:]), nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ajanthan/apache-milagro-dta/storage"
+)
+
+func TestSplitAndCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	shares, err := Split(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	subsets := [][]int{{0, 1, 2}, {2, 3, 4}, {0, 2, 4}, {4, 1, 3}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var selected []Share
+		for _, i := range subset {
+			selected = append(selected, shares[i])
+		}
+		combined, err := Combine(selected)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !bytes.Equal(combined, secret) {
+			t.Errorf("Shares %v should restore the secret", subset)
+		}
+	}
+	combined, err := Combine(shares[:2])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if bytes.Equal(combined, secret) {
+		t.Error("Fewer shares than the threshold should not restore the secret")
+	}
+	if _, err := Combine([]Share{shares[0], shares[0]}); err == nil {
+		t.Error("Duplicate shares should fail")
+	}
+	if _, err := Split(secret, 1, 5); err == nil {
+		t.Error("Threshold of 1 should fail")
+	}
+	if _, err := Split(secret, 6, 5); err == nil {
+		t.Error("Threshold greater than the number of shares should fail")
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dta-backup")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	original := &storage.InMemorySecretStorage{}
+	masterSecret := bytes.Repeat([]byte{7}, 32)
+	original.SetSecret(ctx, 1, masterSecret)
+	fileNames, err := BackupMasterSecret(ctx, original, 2, 3, dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(fileNames) != 3 {
+		t.Fatal("3 share files should be written but got ", len(fileNames))
+	}
+	if _, err := BackupMasterSecret(ctx, original, 2, 3, dir); err == nil {
+		t.Error("Existing share files should not be overwritten")
+	}
+
+	restored := &storage.InMemorySecretStorage{}
+	if err := RestoreMasterSecret(ctx, restored, fileNames[:1]); err == nil {
+		t.Error("Restoring from fewer shares than the threshold should fail")
+	}
+	if err := RestoreMasterSecret(ctx, restored, []string{fileNames[2], fileNames[0]}); err != nil {
+		t.Fatal(err.Error())
+	}
+	secret, err := restored.GetSecret(ctx)
+	if err != nil || !bytes.Equal(secret.Secret[:], masterSecret) {
+		t.Error("Restored master secret does not match")
+	}
+	if err := RestoreMasterSecret(ctx, restored, fileNames); err != nil {
+		t.Fatal(err.Error())
+	}
+	if masterSecrets, _ := restored.GetSecrets(ctx); len(masterSecrets) != 1 {
+		t.Error("Restoring the active secret again should not add a version")
+	}
+}
+
+func TestCorruptedShareFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dta-backup")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	original := &storage.InMemorySecretStorage{}
+	original.SetSecret(ctx, 1, bytes.Repeat([]byte{9}, 32))
+	fileNames, err := BackupMasterSecret(ctx, original, 2, 2, dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	content, _ := ioutil.ReadFile(fileNames[0])
+	corrupted := bytes.Replace(content, []byte(`"Index": 1`), []byte(`"Index": 2`), 1)
+	corruptedFile := filepath.Join(dir, "corrupted.json")
+	ioutil.WriteFile(corruptedFile, corrupted, 0600)
+	if _, err := ReadShareFile(corruptedFile); err == nil {
+		t.Error("Corrupted share file should fail the checksum")
+	}
+}
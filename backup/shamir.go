@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package backup
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+//A Shamir share of a secret. Index is the x coordinate of the share and never 0
+type Share struct {
+	Index byte
+	Data  []byte
+}
+
+//Exponent and logarithm tables of GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1 and generator 3
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+		//Multiply by the generator 3 which is x*2 + x
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x ^= double
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+//Splits the secret into n shares so that any k of them rebuild it and fewer reveal nothing about it. Each byte of the
+//secret is the constant term of its own random polynomial of degree k-1 over GF(2^8)
+func Split(secret []byte, k int, n int) ([]Share, error) {
+	return split(secret, k, n, rand.Reader)
+}
+
+func split(secret []byte, k int, n int, random io.Reader) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("Secret is empty")
+	}
+	if k < 2 || k > n || n > 255 {
+		return nil, errors.New("Threshold should be between 2 and the number of shares, which should be at most 255")
+	}
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{Index: byte(i + 1), Data: make([]byte, len(secret))}
+	}
+	coefficients := make([]byte, k)
+	for position, secretByte := range secret {
+		coefficients[0] = secretByte
+		if _, err := io.ReadFull(random, coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			//Horner's method
+			var y byte
+			for c := k - 1; c >= 0; c-- {
+				y = gfMul(y, shares[i].Index) ^ coefficients[c]
+			}
+			shares[i].Data[position] = y
+		}
+	}
+	for i := range coefficients {
+		coefficients[i] = 0
+	}
+	return shares, nil
+}
+
+//Rebuilds the secret from shares using Lagrange interpolation at 0. Combining fewer shares than the threshold gives
+//a wrong secret without an error, so the result must be verified by the caller
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("At least two shares are needed")
+	}
+	size := len(shares[0].Data)
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if share.Index == 0 || seen[share.Index] {
+			return nil, errors.New("Share indexes should be unique and not 0")
+		}
+		if len(share.Data) != size || size == 0 {
+			return nil, errors.New("Shares should have the same length")
+		}
+		seen[share.Index] = true
+	}
+	secret := make([]byte, size)
+	for i, share := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				//0 - x_j divided by x_i - x_j, where subtraction is xor
+				basis = gfMul(basis, gfDiv(other.Index, other.Index^share.Index))
+			}
+		}
+		for position := range secret {
+			secret[position] ^= gfMul(basis, share.Data[position])
+		}
+	}
+	return secret, nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+//Client of the D-TA API generated from api/openapi.json. The operations are generated into operations.go by
+//cmd/openapi-client-gen, this file has the hand written parts
+package apiclient
+
+//go:generate go run ../../cmd/openapi-client-gen -spec ../../api/openapi.json -out operations.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/pkg/errors"
+)
+
+//Client of one D-TA. Requests are not signed, the signature of issuance requests is given in the parameters of the
+//operation. RequestEditors can add credentials such as the bearer token of the admin API to every request
+type Client struct {
+	//Base URL of the D-TA such as http://127.0.0.1:8088
+	BaseURL        string
+	HTTPClient     *http.Client
+	RequestEditors []func(r *http.Request) error
+}
+
+//Returned by the operations when the D-TA does not respond with 200. Response is the error envelope of the D-TA
+type Error struct {
+	StatusCode int
+	Response   api.ErrorResponse
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("D-TA returned %d %s: %s (request %s)", err.StatusCode, err.Response.Code, err.Response.Message, err.Response.RequestID)
+}
+
+//Creates a client for the D-TA at the base URL with the default http client
+func NewClient(baseURL string, requestEditors ...func(r *http.Request) error) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: http.DefaultClient, RequestEditors: requestEditors}
+}
+
+//Sets the bearer token of the admin API on every request
+func WithAdminToken(token string) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+//Sends the request with body as JSON and decodes the JSON response into v unless it is nil
+func (client *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, v interface{}) error {
+	requestURL := client.BaseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	var requestBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&requestBody).Encode(body); err != nil {
+			return errors.Wrapf(err, "Error in encoding the request to %s", path)
+		}
+	}
+	request, err := http.NewRequest(method, requestURL, &requestBody)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid D-TA URL %s", client.BaseURL)
+	}
+	request = request.WithContext(ctx)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for _, edit := range client.RequestEditors {
+		if err := edit(request); err != nil {
+			return err
+		}
+	}
+
+	response, err := client.HTTPClient.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "Error in calling %s%s", client.BaseURL, path)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		apiError := &Error{StatusCode: response.StatusCode}
+		json.NewDecoder(response.Body).Decode(&apiError.Response)
+		return apiError
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "Error in decoding the response of %s%s", client.BaseURL, path)
+	}
+	return nil
+}
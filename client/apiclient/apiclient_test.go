@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+)
+
+func TestClient_Operations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/clientSecret":
+			query := r.URL.Query()
+			if query.Get("app_id") != "appid0001" || query.Get("client_id") != "user@apache.org" || query.Get("key_version") != "2" || query["key_id"] != nil {
+				t.Error("Unexpected query ", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(api.ClientSecretResponse{ClientSecret: "c2VjcmV0", KeyVersion: 2, Message: "OK"})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/rpa/app 1":
+			if r.Header.Get("Authorization") != "Bearer admin-token" {
+				t.Error("Admin token should be sent but got ", r.Header.Get("Authorization"))
+			}
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Code: api.ErrorNotFound, Message: "RPA app 1 not found", RequestID: "request-1"})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/rpa":
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(api.RelyingPartyApplicationResponse{Application_ID: "appid0002"})
+		default:
+			t.Error("Unexpected request ", r.Method, " ", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL+"/", WithAdminToken("admin-token"))
+
+	response, err := client.GetClientSecret(context.Background(), GetClientSecretParams{AppID: "appid0001", ClientID: "user@apache.org", KeyVersion: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if response.ClientSecret != "c2VjcmV0" || response.KeyVersion != 2 {
+		t.Errorf("Unexpected response %+v", response)
+	}
+
+	_, err = client.GetRPA(context.Background(), "app 1")
+	apiError, ok := err.(*Error)
+	if !ok {
+		t.Fatal("Error response should be returned as *Error but got ", err)
+	}
+	if apiError.StatusCode != http.StatusNotFound || apiError.Response.Code != api.ErrorNotFound || apiError.Response.RequestID != "request-1" {
+		t.Errorf("Unexpected error %+v", apiError)
+	}
+
+	registered, err := client.RegisterRPA(context.Background(), api.RelyingPartyApplicationRequest{Application_ID: "appid0002"})
+	if err != nil {
+		t.Fatal("201 Created should be a success ", err.Error())
+	}
+	if registered.Application_ID != "appid0002" {
+		t.Errorf("Unexpected response %+v", registered)
+	}
+}
@@ -0,0 +1,393 @@
+// Code generated by openapi-client-gen from api/openapi.json. DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+)
+
+// Tells that the D-TA process is alive with GET /healthz
+func (client *Client) GetHealth(ctx context.Context) (*api.HealthResponse, error) {
+	var response api.HealthResponse
+	if err := client.do(ctx, "GET", "/healthz", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Metrics of the D-TA in the Prometheus text format with GET /metrics
+func (client *Client) GetMetrics(ctx context.Context) error {
+	return client.do(ctx, "GET", "/metrics", nil, nil, nil)
+}
+
+// OpenAPI document of the D-TA with GET /openapi.json
+func (client *Client) GetOpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var response json.RawMessage
+	if err := client.do(ctx, "GET", "/openapi.json", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Tells whether the D-TA can issue secrets, with the result of each check with GET /readyz
+func (client *Client) GetReadiness(ctx context.Context) (*api.ReadinessResponse, error) {
+	var response api.ReadinessResponse
+	if err := client.do(ctx, "GET", "/readyz", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// First pass of M-Pin authentication with POST /v1/authenticate/pass1
+func (client *Client) AuthenticatePass1(ctx context.Context, body api.AuthenticationPass1Request) (*api.AuthenticationPass1Response, error) {
+	var response api.AuthenticationPass1Response
+	if err := client.do(ctx, "POST", "/v1/authenticate/pass1", nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Second pass of M-Pin authentication with POST /v1/authenticate/pass2
+func (client *Client) AuthenticatePass2(ctx context.Context, body api.AuthenticationPass2Request) (*api.AuthenticationPass2Response, error) {
+	var response api.AuthenticationPass2Response
+	if err := client.do(ctx, "POST", "/v1/authenticate/pass2", nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Issues the M-Pin client secret share of a client ID with GET /v1/clientSecret
+func (client *Client) GetClientSecret(ctx context.Context, params GetClientSecretParams) (*api.ClientSecretResponse, error) {
+	var response api.ClientSecretResponse
+	if err := client.do(ctx, "GET", "/v1/clientSecret", params.query(), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Query parameters of GetClientSecret
+type GetClientSecretParams struct {
+	// ID of the relying party application. Required
+	AppID string
+	// M-Pin client ID. Required
+	ClientID string
+	// Base64 url encoded signature of the application. Not needed if a client certificate authenticates the application
+	Signature string
+	// ID of the application key which signed the request. Every valid key is tried by default
+	KeyID string
+	// Master secret version. The active version is used by default
+	KeyVersion int
+	// Unix seconds of the request. Required by hmac.sha256 and ed25519 request signing
+	Timestamp int
+	// Random nonce of the request. Required by hmac.sha256 and ed25519 request signing
+	Nonce string
+}
+
+func (params GetClientSecretParams) query() url.Values {
+	query := url.Values{}
+	if params.AppID != "" {
+		query.Set("app_id", params.AppID)
+	}
+	if params.ClientID != "" {
+		query.Set("client_id", params.ClientID)
+	}
+	if params.Signature != "" {
+		query.Set("signature", params.Signature)
+	}
+	if params.KeyID != "" {
+		query.Set("key_id", params.KeyID)
+	}
+	if params.KeyVersion != 0 {
+		query.Set("key_version", strconv.Itoa(params.KeyVersion))
+	}
+	if params.Timestamp != 0 {
+		query.Set("timestamp", strconv.Itoa(params.Timestamp))
+	}
+	if params.Nonce != "" {
+		query.Set("nonce", params.Nonce)
+	}
+	return query
+}
+
+// Publishes the keys verifying the signatures of issued shares with GET /v1/keys
+func (client *Client) GetKeys(ctx context.Context) (*api.KeysResponse, error) {
+	var response api.KeysResponse
+	if err := client.do(ctx, "GET", "/v1/keys", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Rotates the master secret with POST /v1/masterSecret/rotate
+func (client *Client) RotateMasterSecret(ctx context.Context) (*api.MasterSecretRotationResponse, error) {
+	var response api.MasterSecretRotationResponse
+	if err := client.do(ctx, "POST", "/v1/masterSecret/rotate", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Registers a relying party application with POST /v1/rpa
+func (client *Client) RegisterRPA(ctx context.Context, body api.RelyingPartyApplicationRequest) (*api.RelyingPartyApplicationResponse, error) {
+	var response api.RelyingPartyApplicationResponse
+	if err := client.do(ctx, "POST", "/v1/rpa", nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Reads a relying party application with GET /v1/rpa/{appid}
+func (client *Client) GetRPA(ctx context.Context, appid string) (*api.RelyingPartyApplicationResponse, error) {
+	var response api.RelyingPartyApplicationResponse
+	if err := client.do(ctx, "GET", "/v1/rpa/"+url.PathEscape(appid), nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Replaces the attributes of a relying party application with PUT /v1/rpa/{appid}
+func (client *Client) UpdateRPA(ctx context.Context, appid string, body api.RelyingPartyApplicationRequest) (*api.RelyingPartyApplicationResponse, error) {
+	var response api.RelyingPartyApplicationResponse
+	if err := client.do(ctx, "PUT", "/v1/rpa/"+url.PathEscape(appid), nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Changes the given attributes of a relying party application with PATCH /v1/rpa/{appid}
+func (client *Client) PatchRPA(ctx context.Context, appid string, body api.RelyingPartyApplicationPatch) (*api.RelyingPartyApplicationResponse, error) {
+	var response api.RelyingPartyApplicationResponse
+	if err := client.do(ctx, "PATCH", "/v1/rpa/"+url.PathEscape(appid), nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Removes a relying party application with DELETE /v1/rpa/{appid}
+func (client *Client) DeleteRPA(ctx context.Context, appid string) error {
+	return client.do(ctx, "DELETE", "/v1/rpa/"+url.PathEscape(appid), nil, nil, nil)
+}
+
+// Rolls a new key of a relying party application with POST /v1/rpa/{appid}/keys
+func (client *Client) RollRPAKey(ctx context.Context, appid string, body api.RPAKeyRequest) (*api.RelyingPartyApplicationResponse, error) {
+	var response api.RelyingPartyApplicationResponse
+	if err := client.do(ctx, "POST", "/v1/rpa/"+url.PathEscape(appid)+"/keys", nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Lists the revoked client identities of a relying party application with GET /v1/rpa/{appid}/revocations
+func (client *Client) ListRevocations(ctx context.Context, appid string) ([]api.RevocationResponse, error) {
+	var response []api.RevocationResponse
+	if err := client.do(ctx, "GET", "/v1/rpa/"+url.PathEscape(appid)+"/revocations", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Revokes a client identity with POST /v1/rpa/{appid}/revocations
+func (client *Client) Revoke(ctx context.Context, appid string, body api.RevocationRequest) (*api.RevocationResponse, error) {
+	var response api.RevocationResponse
+	if err := client.do(ctx, "POST", "/v1/rpa/"+url.PathEscape(appid)+"/revocations", nil, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Removes the revocation of a client identity with DELETE /v1/rpa/{appid}/revocations
+func (client *Client) Unrevoke(ctx context.Context, appid string, params UnrevokeParams) error {
+	return client.do(ctx, "DELETE", "/v1/rpa/"+url.PathEscape(appid)+"/revocations", params.query(), nil, nil)
+}
+
+// Query parameters of Unrevoke
+type UnrevokeParams struct {
+	// Revoked M-Pin client ID. Required
+	ClientID string
+}
+
+func (params UnrevokeParams) query() url.Values {
+	query := url.Values{}
+	if params.ClientID != "" {
+		query.Set("client_id", params.ClientID)
+	}
+	return query
+}
+
+// Lists the IDs of the relying party applications with GET /v1/rpas
+func (client *Client) ListRPAs(ctx context.Context) ([]api.RelyingPartyApplicationResponse, error) {
+	var response []api.RelyingPartyApplicationResponse
+	if err := client.do(ctx, "GET", "/v1/rpas", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Issues the M-Pin server secret of the D-TA with GET /v1/serverSecret
+func (client *Client) GetServerSecret(ctx context.Context, params GetServerSecretParams) (*api.ServerSecretResponse, error) {
+	var response api.ServerSecretResponse
+	if err := client.do(ctx, "GET", "/v1/serverSecret", params.query(), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Query parameters of GetServerSecret
+type GetServerSecretParams struct {
+	// ID of the relying party application. Required
+	AppID string
+	// Base64 url encoded signature of the application. Not needed if a client certificate authenticates the application
+	Signature string
+	// ID of the application key which signed the request. Every valid key is tried by default
+	KeyID string
+	// Master secret version. The active version is used by default
+	KeyVersion int
+	// Unix seconds of the request. Required by hmac.sha256 and ed25519 request signing
+	Timestamp int
+	// Random nonce of the request. Required by hmac.sha256 and ed25519 request signing
+	Nonce string
+}
+
+func (params GetServerSecretParams) query() url.Values {
+	query := url.Values{}
+	if params.AppID != "" {
+		query.Set("app_id", params.AppID)
+	}
+	if params.Signature != "" {
+		query.Set("signature", params.Signature)
+	}
+	if params.KeyID != "" {
+		query.Set("key_id", params.KeyID)
+	}
+	if params.KeyVersion != 0 {
+		query.Set("key_version", strconv.Itoa(params.KeyVersion))
+	}
+	if params.Timestamp != 0 {
+		query.Set("timestamp", strconv.Itoa(params.Timestamp))
+	}
+	if params.Nonce != "" {
+		query.Set("nonce", params.Nonce)
+	}
+	return query
+}
+
+// Issues the M-Pin time permit share of a client ID for today with GET /v1/timePermit
+func (client *Client) GetTimePermit(ctx context.Context, params GetTimePermitParams) (*api.TimePermitResponse, error) {
+	var response api.TimePermitResponse
+	if err := client.do(ctx, "GET", "/v1/timePermit", params.query(), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Query parameters of GetTimePermit
+type GetTimePermitParams struct {
+	// ID of the relying party application. Required
+	AppID string
+	// M-Pin client ID. Required
+	ClientID string
+	// Base64 url encoded signature of the application. Not needed if a client certificate authenticates the application
+	Signature string
+	// ID of the application key which signed the request. Every valid key is tried by default
+	KeyID string
+	// Master secret version. The active version is used by default
+	KeyVersion int
+	// Unix seconds of the request. Required by hmac.sha256 and ed25519 request signing
+	Timestamp int
+	// Random nonce of the request. Required by hmac.sha256 and ed25519 request signing
+	Nonce string
+}
+
+func (params GetTimePermitParams) query() url.Values {
+	query := url.Values{}
+	if params.AppID != "" {
+		query.Set("app_id", params.AppID)
+	}
+	if params.ClientID != "" {
+		query.Set("client_id", params.ClientID)
+	}
+	if params.Signature != "" {
+		query.Set("signature", params.Signature)
+	}
+	if params.KeyID != "" {
+		query.Set("key_id", params.KeyID)
+	}
+	if params.KeyVersion != 0 {
+		query.Set("key_version", strconv.Itoa(params.KeyVersion))
+	}
+	if params.Timestamp != 0 {
+		query.Set("timestamp", strconv.Itoa(params.Timestamp))
+	}
+	if params.Nonce != "" {
+		query.Set("nonce", params.Nonce)
+	}
+	return query
+}
+
+// Issues M-Pin time permit shares of a client ID for a range of dates with GET /v1/timePermits
+func (client *Client) GetTimePermits(ctx context.Context, params GetTimePermitsParams) (*api.TimePermitsResponse, error) {
+	var response api.TimePermitsResponse
+	if err := client.do(ctx, "GET", "/v1/timePermits", params.query(), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Query parameters of GetTimePermits
+type GetTimePermitsParams struct {
+	// ID of the relying party application. Required
+	AppID string
+	// M-Pin client ID. Required
+	ClientID string
+	// First date (UTC) of the range, inclusive. Required
+	From string
+	// Last date (UTC) of the range, inclusive. Required
+	To string
+	// Base64 url encoded signature of the application. Not needed if a client certificate authenticates the application
+	Signature string
+	// ID of the application key which signed the request. Every valid key is tried by default
+	KeyID string
+	// Master secret version. The active version is used by default
+	KeyVersion int
+	// Unix seconds of the request. Required by hmac.sha256 and ed25519 request signing
+	Timestamp int
+	// Random nonce of the request. Required by hmac.sha256 and ed25519 request signing
+	Nonce string
+}
+
+func (params GetTimePermitsParams) query() url.Values {
+	query := url.Values{}
+	if params.AppID != "" {
+		query.Set("app_id", params.AppID)
+	}
+	if params.ClientID != "" {
+		query.Set("client_id", params.ClientID)
+	}
+	if params.From != "" {
+		query.Set("from", params.From)
+	}
+	if params.To != "" {
+		query.Set("to", params.To)
+	}
+	if params.Signature != "" {
+		query.Set("signature", params.Signature)
+	}
+	if params.KeyID != "" {
+		query.Set("key_id", params.KeyID)
+	}
+	if params.KeyVersion != 0 {
+		query.Set("key_version", strconv.Itoa(params.KeyVersion))
+	}
+	if params.Timestamp != 0 {
+		query.Set("timestamp", strconv.Itoa(params.Timestamp))
+	}
+	if params.Nonce != "" {
+		query.Set("nonce", params.Nonce)
+	}
+	return query
+}
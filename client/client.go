@@ -0,0 +1,241 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta"
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
+	"github.com/pkg/errors"
+)
+
+//A D-TA server and the credentials of the relying party application registered in it
+type DTAEndpoint struct {
+	//Base URL of the D-TA such as http://127.0.0.1:8088
+	URL    string
+	AppID  string
+	AppKey []byte
+	//Optional ID of AppKey. It is sent with every request so that the D-TA verifies the signature with that key
+	AppKeyID string
+	//signature.HMACSHA256 if the D-TA verifies requests with it, or signature.Ed25519 if the application registered an
+	//Ed25519 public key. Then AppKey is the seed of its private key. The application ID is signed with AES by default
+	SignatureAlgorithm string
+	//Optional Ed25519 public key published in /keys of the D-TA. When set, every share must be signed with it
+	VerificationKey []byte
+}
+
+//Client which fetches M-Pin secret shares from several D-TAs and recombines them
+type MultiDTAClient struct {
+	Endpoints  []DTAEndpoint
+	HTTPClient *http.Client
+}
+
+//Creates a client for the given D-TAs with a default http client
+func NewMultiDTAClient(endpoints []DTAEndpoint) *MultiDTAClient {
+	return &MultiDTAClient{
+		Endpoints:  endpoints,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+//Fetches the server secret share from every D-TA and returns the recombined server secret
+func (client *MultiDTAClient) GetServerSecret() ([]byte, error) {
+	return client.GetServerSecretWithVersion(0)
+}
+
+//Fetches the server secret share of the given master secret version from every D-TA and returns the recombined server
+//secret. The active version of each D-TA is used if version is 0
+func (client *MultiDTAClient) GetServerSecretWithVersion(version int) ([]byte, error) {
+	var params url.Values
+	if version != 0 {
+		params = url.Values{"key_version": {strconv.Itoa(version)}}
+	}
+	var shares [][]byte
+	for _, endpoint := range client.Endpoints {
+		response := api.ServerSecretResponse{}
+		if err := client.get(endpoint, "/v1/serverSecret", params, &response); err != nil {
+			return nil, err
+		}
+		share, err := base64.URLEncoding.DecodeString(response.ServerSecret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error in decoding server secret from %s", endpoint.URL)
+		}
+		if err := verifyShare(endpoint, response.Signature, signature.IssuanceServerSecret, share, "", 0, response.KeyVersion); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return recombineG2(shares)
+}
+
+//Fetches the client secret share of clientID from every D-TA and returns the recombined client secret
+func (client *MultiDTAClient) GetClientSecret(clientID string) ([]byte, error) {
+	var shares [][]byte
+	for _, endpoint := range client.Endpoints {
+		response := api.ClientSecretResponse{}
+		if err := client.get(endpoint, "/v1/clientSecret", url.Values{"client_id": {clientID}}, &response); err != nil {
+			return nil, err
+		}
+		share, err := base64.URLEncoding.DecodeString(response.ClientSecret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error in decoding client secret from %s", endpoint.URL)
+		}
+		if err := verifyShare(endpoint, response.Signature, signature.IssuanceClientSecret, share, clientID, 0, response.KeyVersion); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return recombineG1(shares)
+}
+
+//Fetches the time permit share of clientID from every D-TA and returns the recombined time permit. The shares must be
+//for the same day and master secret version, which they are not if the requests span midnight or a rotation
+func (client *MultiDTAClient) GetTimePermit(clientID string) ([]byte, error) {
+	var shares [][]byte
+	var first api.TimePermitResponse
+	for i, endpoint := range client.Endpoints {
+		response := api.TimePermitResponse{}
+		if err := client.get(endpoint, "/v1/timePermit", url.Values{"client_id": {clientID}}, &response); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			first = response
+		} else if response.EpochDay != first.EpochDay || response.KeyVersion != first.KeyVersion {
+			return nil, fmt.Errorf("Time permit of %s is for day %d and key version %d but the first is for day %d and key version %d",
+				endpoint.URL, response.EpochDay, response.KeyVersion, first.EpochDay, first.KeyVersion)
+		}
+		share, err := base64.URLEncoding.DecodeString(response.TimePermit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error in decoding time permit from %s", endpoint.URL)
+		}
+		if err := verifyShare(endpoint, response.Signature, signature.IssuanceTimePermit, share, clientID, response.EpochDay, response.KeyVersion); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return recombineG1(shares)
+}
+
+//Signs and sends a GET request to the D-TA and decodes the JSON response into v
+func (client *MultiDTAClient) get(endpoint DTAEndpoint, path string, params url.Values, v interface{}) error {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("app_id", endpoint.AppID)
+	if endpoint.AppKeyID != "" {
+		query.Set("key_id", endpoint.AppKeyID)
+	}
+	switch endpoint.SignatureAlgorithm {
+	case signature.HMACSHA256, signature.Ed25519:
+		//The D-TA may be behind a base path of the URL
+		requestURL, err := url.Parse(endpoint.URL + path)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid D-TA URL %s", endpoint.URL)
+		}
+		requestSignature, err := signRequest(endpoint, requestURL.Path, query)
+		if err != nil {
+			return err
+		}
+		query.Set(signature.SignatureParam, base64.URLEncoding.EncodeToString(requestSignature))
+	default:
+		query.Set("signature", base64.URLEncoding.EncodeToString(signature.CreateSignature(endpoint.AppKey, endpoint.AppID)))
+	}
+
+	response, err := client.HTTPClient.Get(endpoint.URL + path + "?" + query.Encode())
+	if err != nil {
+		return errors.Wrapf(err, "Error in calling %s%s", endpoint.URL, path)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var errorResponse api.ErrorResponse
+		json.NewDecoder(response.Body).Decode(&errorResponse)
+		return fmt.Errorf("%s%s returned %d %s: %s (request %s)", endpoint.URL, path, response.StatusCode, errorResponse.Code, errorResponse.Message, errorResponse.RequestID)
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "Error in decoding response from %s%s", endpoint.URL, path)
+	}
+	return nil
+}
+
+//Signs a GET request to the path with the signature algorithm of the endpoint
+func signRequest(endpoint DTAEndpoint, path string, query url.Values) ([]byte, error) {
+	if endpoint.SignatureAlgorithm == signature.HMACSHA256 {
+		return signature.SignRequest(endpoint.AppKey, http.MethodGet, path, query, nil)
+	}
+	if len(endpoint.AppKey) != ed25519.SeedSize {
+		return nil, fmt.Errorf("App key of %s is not an Ed25519 seed", endpoint.URL)
+	}
+	return signature.SignRequestEd25519(ed25519.NewKeyFromSeed(endpoint.AppKey), http.MethodGet, path, query, nil)
+}
+
+//Checks that the share is signed by the D-TA if the endpoint has a verification key
+func verifyShare(endpoint DTAEndpoint, encodedSignature string, kind string, share []byte, clientID string, date int, keyVersion int) error {
+	if len(endpoint.VerificationKey) == 0 {
+		return nil
+	}
+	sig, err := base64.URLEncoding.DecodeString(encodedSignature)
+	if err != nil || !signature.VerifyIssuance(endpoint.VerificationKey, sig, kind, share, clientID, date, keyVersion) {
+		return fmt.Errorf("Invalid signature of the %s issued by %s", kind, endpoint.URL)
+	}
+	return nil
+}
+
+//Adds the G1 shares (client secrets or time permits) issued by each D-TA
+func recombineG1(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("No D-TA endpoints configured")
+	}
+	secret := shares[0]
+	for _, share := range shares[1:] {
+		combined := make([]byte, dta.G1S)
+		if rtn := amcl.MPIN_RECOMBINE_G1(secret, share, combined); rtn != 0 {
+			return nil, fmt.Errorf("Error in recombining G1 shares %d", rtn)
+		}
+		secret = combined
+	}
+	return secret, nil
+}
+
+//Adds the G2 shares (server secrets) issued by each D-TA
+func recombineG2(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("No D-TA endpoints configured")
+	}
+	secret := shares[0]
+	for _, share := range shares[1:] {
+		combined := make([]byte, dta.G2S)
+		if rtn := amcl.MPIN_RECOMBINE_G2(secret, share, combined); rtn != 0 {
+			return nil, fmt.Errorf("Error in recombining G2 shares %d", rtn)
+		}
+		secret = combined
+	}
+	return secret, nil
+}
@@ -0,0 +1,204 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajanthan/apache-milagro-dta/api"
+	"github.com/ajanthan/apache-milagro-dta/api/server"
+	"github.com/ajanthan/apache-milagro-dta/client"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+	"github.com/ajanthan/apache-milagro-dta/utils"
+)
+
+//Operator token of the admin API of the test D-TAs
+const adminToken = "admin-token"
+
+//Starts a D-TA with in memory storage. Each instance generates its own master secret
+func startDTA(t *testing.T, port int) *server.ApiServer {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetBindAddress("127.0.0.1")
+	conf.SetBindPort(port)
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	conf.SetAdminTokens([]config.AdminTokenConfig{{Name: "test", SHA256: server.HashAdminToken(adminToken), Role: server.RoleOperator}})
+
+	apiServer := &server.ApiServer{}
+	go func() {
+		apiServer.BootstrapWithConfig(conf)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := apiServer.WaitReady(ctx); err != nil {
+		t.Fatal("D-TA did not become ready on port ", port, " ", err.Error())
+	}
+	return apiServer
+}
+
+//Sends a request to the admin API with the operator token
+func adminRequest(method string, url string, body *bytes.Buffer) (*http.Response, error) {
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	return http.DefaultClient.Do(request)
+}
+
+//Registers the application in the D-TA and returns the issued application key
+func registerApp(t *testing.T, baseURL string, appID string) []byte {
+	app := storage.RelyingPartyApplication{Application_ID: appID}
+	buffer := new(bytes.Buffer)
+	if err := json.NewEncoder(buffer).Encode(&app); err != nil {
+		t.Fatal("Error encoding app ", err.Error())
+	}
+	if response, err := adminRequest(http.MethodPost, baseURL+"/v1/rpa", buffer); err != nil {
+		t.Fatal("Error in registering the app ", err.Error())
+	} else {
+		response.Body.Close()
+	}
+
+	registeredApp := api.RelyingPartyApplicationResponse{}
+	response, err := adminRequest(http.MethodGet, baseURL+"/v1/rpa/"+appID, new(bytes.Buffer))
+	if err != nil {
+		t.Fatal("Error in getting the app ", err.Error())
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(&registeredApp); err != nil {
+		t.Fatal("Error in decoding app ", err.Error())
+	}
+	appKey, err := base64.URLEncoding.DecodeString(registeredApp.Application_KEY)
+	if err != nil {
+		t.Fatal("Error in decoding app key ", err.Error())
+	}
+	return appKey
+}
+
+func TestMultiDTAClient_Recombine(t *testing.T) {
+	appID := "appid0001"
+	clientID := "test@apache.milagro.org"
+
+	var endpoints []client.DTAEndpoint
+	for port := 8091; port <= 8093; port++ {
+		apiServer := startDTA(t, port)
+		defer apiServer.StopServer()
+
+		baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+		endpoints = append(endpoints, client.DTAEndpoint{URL: baseURL, AppID: appID, AppKey: registerApp(t, baseURL, appID)})
+	}
+
+	dtaClient := client.NewMultiDTAClient(endpoints)
+	serverSecret, err := dtaClient.GetServerSecret()
+	if err != nil {
+		t.Fatal("Error in getting server secret ", err.Error())
+	}
+	clientSecret, err := dtaClient.GetClientSecret(clientID)
+	if err != nil {
+		t.Fatal("Error in getting client secret ", err.Error())
+	}
+	timePermit, err := dtaClient.GetTimePermit(clientID)
+	if err != nil {
+		t.Fatal("Error in getting time permit ", err.Error())
+	}
+
+	if err := utils.ValidateMpin(serverSecret, clientSecret, timePermit, clientID, 1234); err != nil {
+		t.Error("M-Pin authentication failed with recombined secrets ", err.Error())
+	}
+}
+
+func TestMultiDTAClient_NoEndpoints(t *testing.T) {
+	dtaClient := client.NewMultiDTAClient(nil)
+	if _, err := dtaClient.GetServerSecret(); err == nil {
+		t.Error("Expected an error when no D-TA is configured")
+	}
+}
+
+func TestMultiDTAClient_VerifySignatures(t *testing.T) {
+	appID := "appid0002"
+	clientID := "test@apache.milagro.org"
+	port := 8094
+	apiServer := startDTA(t, port)
+	defer apiServer.StopServer()
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	appKey := registerApp(t, baseURL, appID)
+
+	response, err := http.Get(baseURL + "/v1/keys")
+	if err != nil {
+		t.Fatal("Error in getting the keys ", err.Error())
+	}
+	defer response.Body.Close()
+	keys := api.KeysResponse{}
+	if err := json.NewDecoder(response.Body).Decode(&keys); err != nil || len(keys.Keys) != 1 {
+		t.Fatal("Expected one verification key ", err)
+	}
+	verificationKey, _ := base64.URLEncoding.DecodeString(keys.Keys[0].PublicKey)
+
+	endpoint := client.DTAEndpoint{URL: baseURL, AppID: appID, AppKey: appKey, VerificationKey: verificationKey}
+	if _, err := client.NewMultiDTAClient([]client.DTAEndpoint{endpoint}).GetClientSecret(clientID); err != nil {
+		t.Error("Client secret should be verified with the published key ", err.Error())
+	}
+	if _, err := client.NewMultiDTAClient([]client.DTAEndpoint{endpoint}).GetTimePermit(clientID); err != nil {
+		t.Error("Time permit should be verified with the published key ", err.Error())
+	}
+
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	endpoint.VerificationKey = otherKey
+	if _, err := client.NewMultiDTAClient([]client.DTAEndpoint{endpoint}).GetServerSecret(); err == nil {
+		t.Error("Server secret signed by another key should be rejected")
+	}
+}
+
+//Shares of different days or master secret versions do not recombine into a valid time permit
+func TestMultiDTAClient_TimePermitMismatch(t *testing.T) {
+	share := base64.URLEncoding.EncodeToString(make([]byte, 2*32+1))
+	newDTA := func(response api.TimePermitResponse) client.DTAEndpoint {
+		dta := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(response)
+		}))
+		t.Cleanup(dta.Close)
+		return client.DTAEndpoint{URL: dta.URL, AppID: "appid0001", AppKey: make([]byte, 16)}
+	}
+
+	for _, other := range []api.TimePermitResponse{
+		{TimePermit: share, EpochDay: 18001, KeyVersion: 1},
+		{TimePermit: share, EpochDay: 18000, KeyVersion: 2},
+	} {
+		endpoints := []client.DTAEndpoint{newDTA(api.TimePermitResponse{TimePermit: share, EpochDay: 18000, KeyVersion: 1}), newDTA(other)}
+		if _, err := client.NewMultiDTAClient(endpoints).GetTimePermit("test@apache.milagro.org"); err == nil || !strings.Contains(err.Error(), "the first is for day") {
+			t.Errorf("Time permit shares for day %d and key version %d should be rejected", other.EpochDay, other.KeyVersion)
+		}
+	}
+}
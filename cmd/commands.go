@@ -19,9 +19,117 @@
 
 package main
 
-import "github.com/ajanthan/apache-milagro-dta/api/server"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ajanthan/apache-milagro-dta/api/server"
+	"github.com/ajanthan/apache-milagro-dta/backup"
+	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/storage"
+)
+
+const usage = `Usage:
+  dta-server                                  Starts the D-TA server
+  dta-server backup -k K -n N [-out DIR]      Splits the master secret into N share files, any K of them restore it
+  dta-server restore SHARE_FILE...            Restores the master secret from share files into the configured storage
+  dta-server encrypt-secret                   Copies master.secret into the encrypted.file storage
+  dta-server admin-token -name NAME [-role R] Generates an admin API token and its server.admin.tokens entry
+`
 
 func main() {
-	dtaServer := server.ApiServer{}
-	dtaServer.Bootstrap()
+	if len(os.Args) < 2 {
+		dtaServer := server.ApiServer{}
+		dtaServer.Bootstrap()
+		return
+	}
+	switch os.Args[1] {
+	case "backup":
+		backupCommand(os.Args[2:])
+	case "restore":
+		restoreCommand(os.Args[2:])
+	case "encrypt-secret":
+		encryptSecretCommand()
+	case "admin-token":
+		adminTokenCommand(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func backupCommand(args []string) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	k := flags.Int("k", 0, "Number of shares needed to restore the master secret")
+	n := flags.Int("n", 0, "Number of shares")
+	out := flags.String("out", ".", "Directory of the share files")
+	flags.Parse(args)
+
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	fileNames, err := backup.BackupMasterSecret(context.Background(), conf.GetMasterSecretStorage(), *k, *n, *out)
+	if err != nil {
+		log.Fatal("Error while backing up the master secret ", err.Error())
+	}
+	for _, fileName := range fileNames {
+		fmt.Println(fileName)
+	}
+}
+
+func restoreCommand(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	if err := backup.RestoreMasterSecret(context.Background(), conf.GetMasterSecretStorage(), flags.Args()); err != nil {
+		log.Fatal("Error while restoring the master secret ", err.Error())
+	}
+	fmt.Println("Master secret restored")
+}
+
+//Migrates every version of the plain text master secret file into the encrypted file. The plain text file is kept so
+//that it can be removed securely once the D-TA runs with server.secret.storage set to encrypted.file
+func encryptSecretCommand() {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	masterSecrets, err := (&storage.PlainTextFileMasterSecretStorage{}).GetSecrets(context.Background())
+	if err != nil {
+		log.Fatal("No master secret found in the plain text file ", err.Error())
+	}
+	if err := conf.GetEncryptedMasterSecretStorage().Import(masterSecrets); err != nil {
+		log.Fatal("Error while encrypting the master secret ", err.Error())
+	}
+	fmt.Println(len(masterSecrets), "master secret versions encrypted. Set server.secret.storage to encrypted.file and remove master.secret securely")
+}
+
+//Prints a new random admin token and the entry of server.admin.tokens with its hash. The token itself is not stored
+//anywhere, so it must be copied when it is printed
+func adminTokenCommand(args []string) {
+	flags := flag.NewFlagSet("admin-token", flag.ExitOnError)
+	name := flags.String("name", "", "Name of the token in the logs")
+	role := flags.String("role", server.RoleViewer, "viewer or operator")
+	flags.Parse(args)
+	if *name == "" || (*role != server.RoleViewer && *role != server.RoleOperator) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		log.Fatal("Error while generating the token ", err.Error())
+	}
+	encodedToken := base64.RawURLEncoding.EncodeToString(token)
+	fmt.Println("Token:", encodedToken)
+	fmt.Println("Add to server.admin.tokens:")
+	fmt.Printf("  - name: %s\n    sha256: %s\n    role: %s\n", *name, server.HashAdminToken(encodedToken), *role)
 }
@@ -0,0 +1,340 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+//Generates the operations of client/apiclient from the OpenAPI document of the D-TA
+//	go run ./cmd/openapi-client-gen -spec api/openapi.json -out client/apiclient/operations.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+)
+
+//Parts of an OpenAPI 3 document which the generator uses
+type document struct {
+	Paths      map[string]map[string]json.RawMessage
+	Components struct {
+		Schemas    map[string]schema
+		Parameters map[string]parameter
+	}
+}
+
+type operation struct {
+	OperationID string
+	Summary     string
+	Parameters  []parameter
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema schema
+		}
+	}
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema schema
+		}
+	}
+}
+
+type parameter struct {
+	Ref         string `json:"$ref"`
+	Name        string
+	In          string
+	Required    bool
+	Description string
+	Schema      schema
+}
+
+type schema struct {
+	Ref    string `json:"$ref"`
+	Type   string
+	Items  *schema
+	GoType string `json:"x-go-type"`
+}
+
+//Order of the operations of a path in the generated code
+var methods = []string{"get", "post", "put", "patch", "delete"}
+
+func main() {
+	specFile := flag.String("spec", "api/openapi.json", "OpenAPI document")
+	outFile := flag.String("out", "client/apiclient/operations.go", "generated Go file")
+	packageName := flag.String("package", "apiclient", "package of the generated file")
+	flag.Parse()
+
+	spec, err := ioutil.ReadFile(*specFile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	source, err := generate(spec, *specFile, *packageName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := ioutil.WriteFile(*outFile, source, 0644); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+//Returns the formatted source of one client method per operation of the document, with a struct for the query
+//parameters of the operations which have any
+func generate(spec []byte, specFile string, packageName string) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("Invalid OpenAPI document %s: %v", specFile, err)
+	}
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var operations bytes.Buffer
+	imports := map[string]bool{"context": true}
+	for _, path := range paths {
+		item := doc.Paths[path]
+		var shared []parameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("Invalid parameters of %s: %v", path, err)
+			}
+		}
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op operation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("Invalid operation %s %s: %v", method, path, err)
+			}
+			if err := writeOperation(&operations, imports, doc, path, strings.ToUpper(method), op, shared); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var source bytes.Buffer
+	//go:generate runs the generator with paths relative to the package
+	for strings.HasPrefix(specFile, "../") {
+		specFile = strings.TrimPrefix(specFile, "../")
+	}
+	fmt.Fprintf(&source, "// Code generated by openapi-client-gen from %s. DO NOT EDIT.\n\n", specFile)
+	fmt.Fprintf(&source, "package %s\n\nimport (\n", packageName)
+	var standard, packages []string
+	for pkg := range imports {
+		if strings.Contains(pkg, ".") {
+			packages = append(packages, pkg)
+		} else {
+			standard = append(standard, pkg)
+		}
+	}
+	sort.Strings(standard)
+	sort.Strings(packages)
+	for _, pkg := range standard {
+		fmt.Fprintf(&source, "\t%q\n", pkg)
+	}
+	if len(packages) > 0 {
+		source.WriteString("\n")
+	}
+	for _, pkg := range packages {
+		fmt.Fprintf(&source, "\t%q\n", pkg)
+	}
+	source.WriteString(")\n")
+	source.Write(operations.Bytes())
+	return format.Source(source.Bytes())
+}
+
+func writeOperation(out *bytes.Buffer, imports map[string]bool, doc document, path string, method string, op operation, shared []parameter) error {
+	name := exported(op.OperationID)
+	var pathParams, queryParams []parameter
+	for _, param := range append(append([]parameter(nil), shared...), op.Parameters...) {
+		param, err := resolveParameter(doc, param)
+		if err != nil {
+			return err
+		}
+		switch param.In {
+		case "path":
+			pathParams = append(pathParams, param)
+		case "query":
+			queryParams = append(queryParams, param)
+		default:
+			return fmt.Errorf("Parameter %s of %s in %s is not supported", param.Name, op.OperationID, param.In)
+		}
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, param := range pathParams {
+		args = append(args, unexported(param.Name)+" string")
+	}
+	query := "nil"
+	if len(queryParams) > 0 {
+		args = append(args, "params "+name+"Params")
+		query = "params.query()"
+	}
+	body := "nil"
+	if op.RequestBody != nil {
+		bodyType, err := goType(doc, op.RequestBody.Content["application/json"].Schema, imports)
+		if err != nil {
+			return fmt.Errorf("Request body of %s: %v", op.OperationID, err)
+		}
+		args = append(args, "body "+bodyType)
+		body = "body"
+	}
+	urlPath, err := pathExpression(path, pathParams, imports)
+	if err != nil {
+		return fmt.Errorf("Path of %s: %v", op.OperationID, err)
+	}
+
+	fmt.Fprintf(out, "\n// %s with %s %s\n", op.Summary, method, path)
+	responseType := ""
+	if content, ok := op.Responses[successStatus(op)].Content["application/json"]; ok {
+		responseType, err = goType(doc, content.Schema, imports)
+		if err != nil {
+			return fmt.Errorf("Response of %s: %v", op.OperationID, err)
+		}
+	}
+	call := fmt.Sprintf("client.do(ctx, %q, %s, %s, %s", method, urlPath, query, body)
+	switch {
+	case responseType == "":
+		fmt.Fprintf(out, "func (client *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+		fmt.Fprintf(out, "return %s, nil)\n}\n", call)
+	case strings.HasPrefix(responseType, "[]") || responseType == "json.RawMessage":
+		fmt.Fprintf(out, "func (client *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), responseType)
+		fmt.Fprintf(out, "var response %s\nif err := %s, &response); err != nil {\nreturn nil, err\n}\nreturn response, nil\n}\n", responseType, call)
+	default:
+		fmt.Fprintf(out, "func (client *Client) %s(%s) (*%s, error) {\n", name, strings.Join(args, ", "), responseType)
+		fmt.Fprintf(out, "var response %s\nif err := %s, &response); err != nil {\nreturn nil, err\n}\nreturn &response, nil\n}\n", responseType, call)
+	}
+	if len(queryParams) > 0 {
+		writeParams(out, imports, name, queryParams)
+	}
+	return nil
+}
+
+//Writes the struct of the query parameters of an operation and its query method. Parameters with the zero value are
+//not sent
+func writeParams(out *bytes.Buffer, imports map[string]bool, name string, params []parameter) {
+	imports["net/url"] = true
+	fmt.Fprintf(out, "\n// Query parameters of %s\ntype %sParams struct {\n", name, name)
+	for _, param := range params {
+		description := param.Description
+		if param.Required {
+			description = strings.TrimSuffix(description, ".") + ". Required"
+		}
+		fieldType := "string"
+		if param.Schema.Type == "integer" {
+			fieldType = "int"
+		}
+		fmt.Fprintf(out, "// %s\n%s %s\n", description, exported(param.Name), fieldType)
+	}
+	fmt.Fprintf(out, "}\n\nfunc (params %sParams) query() url.Values {\nquery := url.Values{}\n", name)
+	for _, param := range params {
+		field := "params." + exported(param.Name)
+		if param.Schema.Type == "integer" {
+			imports["strconv"] = true
+			fmt.Fprintf(out, "if %s != 0 {\nquery.Set(%q, strconv.Itoa(%s))\n}\n", field, param.Name, field)
+			continue
+		}
+		fmt.Fprintf(out, "if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, param.Name, field)
+	}
+	out.WriteString("return query\n}\n")
+}
+
+//Returns the lowest 2xx status of the responses of an operation, whose body is the result of the client method
+func successStatus(op operation) string {
+	var statuses []string
+	for status := range op.Responses {
+		if strings.HasPrefix(status, "2") {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return ""
+	}
+	sort.Strings(statuses)
+	return statuses[0]
+}
+
+//Returns the Go expression of the path with the path parameters escaped
+func pathExpression(path string, params []parameter, imports map[string]bool) (string, error) {
+	expression := fmt.Sprintf("%q", path)
+	for _, param := range params {
+		placeholder := "{" + param.Name + "}"
+		if !strings.Contains(path, placeholder) {
+			return "", fmt.Errorf("%s has no parameter %s", path, param.Name)
+		}
+		imports["net/url"] = true
+		expression = strings.Replace(expression, placeholder, `"+url.PathEscape(`+unexported(param.Name)+`)+"`, 1)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(expression, `""+`), `+""`), nil
+}
+
+func resolveParameter(doc document, param parameter) (parameter, error) {
+	if param.Ref == "" {
+		return param, nil
+	}
+	resolved, ok := doc.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+	if !ok {
+		return parameter{}, fmt.Errorf("Unknown parameter %s", param.Ref)
+	}
+	return resolved, nil
+}
+
+//Returns the Go type of a schema. Schemas of the components name their type in the api package with x-go-type
+func goType(doc document, s schema, imports map[string]bool) (string, error) {
+	switch {
+	case s.Ref != "":
+		component, ok := doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok || component.GoType == "" {
+			return "", fmt.Errorf("Schema %s has no x-go-type", s.Ref)
+		}
+		imports["github.com/ajanthan/apache-milagro-dta/api"] = true
+		return component.GoType, nil
+	case s.Type == "array" && s.Items != nil:
+		itemType, err := goType(doc, *s.Items, imports)
+		return "[]" + itemType, err
+	case s.Type == "object":
+		imports["encoding/json"] = true
+		return "json.RawMessage", nil
+	}
+	return "", fmt.Errorf("Schema of type %q is not supported", s.Type)
+}
+
+//Converts names such as app_id and getServerSecret into AppID and GetServerSecret
+func exported(name string) string {
+	var words []string
+	for _, word := range strings.Split(name, "_") {
+		if word == "id" {
+			words = append(words, "ID")
+			continue
+		}
+		words = append(words, strings.ToUpper(word[:1])+word[1:])
+	}
+	return strings.Join(words, "")
+}
+
+func unexported(name string) string {
+	name = exported(name)
+	return strings.ToLower(name[:1]) + name[1:]
+}
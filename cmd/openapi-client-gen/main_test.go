@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+//The committed client must be generated from the committed OpenAPI document. Run go generate ./client/apiclient
+//after changing api/openapi.json
+func TestGenerate_UpToDate(t *testing.T) {
+	spec, err := ioutil.ReadFile("../../api/openapi.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	generated, err := generate(spec, "../../api/openapi.json", "apiclient")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	committed, err := ioutil.ReadFile("../../client/apiclient/operations.go")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(generated, committed) {
+		t.Error("client/apiclient/operations.go is not generated from api/openapi.json. Run go generate ./client/apiclient")
+	}
+}
+
+func TestExported(t *testing.T) {
+	for name, expected := range map[string]string{"app_id": "AppID", "getServerSecret": "GetServerSecret", "key_version": "KeyVersion", "from": "From"} {
+		if actual := exported(name); actual != expected {
+			t.Errorf("%s should be exported as %s but got %s", name, expected, actual)
+		}
+	}
+}
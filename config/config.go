@@ -20,21 +20,111 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"time"
 
+	"github.com/ajanthan/apache-milagro-dta/policy"
 	"github.com/ajanthan/apache-milagro-dta/signature"
 	"github.com/ajanthan/apache-milagro-dta/storage"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
+//D-TA from which the authentication server fetches its server secret share
+type DTAEndpointConfig struct {
+	URL   string
+	AppID string `mapstructure:"appId"`
+	//Base64 url encoded application key
+	AppKey string `mapstructure:"appKey"`
+	//Optional ID of the application key. When set, the D-TA verifies requests only with that key
+	AppKeyID string `mapstructure:"appKeyId"`
+	//hmac.sha256 if the D-TA verifies requests with it, or ed25519 if the application registered an Ed25519 public
+	//key. Then appKey is the base64 url encoded seed of its private key. The application ID is signed with AES by
+	//default
+	SignatureAlgorithm string `mapstructure:"signatureAlgorithm"`
+	//Optional base64 url encoded key from /keys of the D-TA. When set, responses which are not signed with it are
+	//rejected
+	VerificationKey string `mapstructure:"verificationKey"`
+}
+
+//Identity policies of a relying party application. Policies of AppID "*" apply to applications without their own
+//policies
+type IdentityPolicyConfig struct {
+	AppID string `mapstructure:"appId"`
+	//Each policy has its type and the parameters of that type
+	Policies []map[string]interface{}
+}
+
+//Client certificate which authenticates a relying party application over mutual TLS
+type TLSClientIdentityConfig struct {
+	//Subject common name or a DNS, email or URI subject alternative name of the certificate
+	Name  string
+	AppID string `mapstructure:"appId"`
+}
+
+//Bearer token of the admin API. Only the hash of the token is configured
+type AdminTokenConfig struct {
+	//Shown in the logs when the token is used
+	Name string
+	//Hex SHA-256 of the token
+	SHA256 string `mapstructure:"sha256"`
+	//viewer or operator
+	Role string
+}
+
+//Client certificate of the admin API. It must be issued by a CA in server.tls.clientCAFile
+type AdminCertificateConfig struct {
+	//Subject common name or a DNS, email or URI subject alternative name of the certificate
+	Name string
+	//viewer or operator
+	Role string
+}
+
 //Represents D-TA config file
 type Config struct {
 	bindAddress         string
 	bindPort            int
 	masterSecretStorage string
+	passphraseEnv       string
+	pkcs11Module        string
+	pkcs11TokenLabel    string
+	pkcs11KeyLabel      string
+	pkcs11PINEnv        string
+	gracePeriod         time.Duration
 	serverSeed          string
+	deterministicRNG    bool
+	devMode             bool
 	rpaStore            string
+	rpaSQLDriver        string
+	rpaSQLDataSource    string
+	rpaKeyGracePeriod   time.Duration
+	revocationStore     string
+	signingKeyStore     string
 	signatureVerifier   string
+	hmacMaxClockSkew    time.Duration
+	hmacNonceCacheSize  int
+	ed25519MaxClockSkew time.Duration
+	ed25519NonceCache   int
+	maxRequestBodySize  int64
+	maxTimePermitRange  int
+	authEnabled         bool
+	authSessionTimeout  time.Duration
+	authMaxSessions     int
+	authDTAs            []DTAEndpointConfig
+	identityPolicies    []IdentityPolicyConfig
+	tlsEnabled          bool
+	tlsCertFile         string
+	tlsKeyFile          string
+	tlsClientCAFile     string
+	tlsClientAuth       string
+	tlsClientIdentities []TLSClientIdentityConfig
+	adminAddress        string
+	adminTokens         []AdminTokenConfig
+	adminCertificates   []AdminCertificateConfig
+	grpcAddress         string
 }
 
 //Loads the dta-server.yaml from current directory
@@ -46,8 +136,29 @@ func (config *Config) ParseDTAConfigFile() {
 	viper.SetDefault("server.address", "0.0.0.0")
 	viper.SetDefault("server.port", 8088)
 	viper.SetDefault("server.secret.storage", "memory")
+	viper.SetDefault("server.secret.gracePeriod", "24h")
+	viper.SetDefault("server.secret.passphraseEnv", "DTA_MASTER_SECRET_PASSPHRASE")
+	viper.SetDefault("server.secret.pkcs11.keyLabel", "dta-master-secret")
+	viper.SetDefault("server.secret.pkcs11.pinEnv", "DTA_PKCS11_PIN")
+	viper.SetDefault("server.rpa.storage", "memory")
+	viper.SetDefault("server.rpa.keyGracePeriod", "24h")
+	viper.SetDefault("server.revocation.storage", "json.file")
+	viper.SetDefault("server.signingKey.storage", "plain.text.file")
 	viper.SetDefault("server.seed", "3b6c64666d6e766a6a666579346f38793772766264666f6f6665")
+	viper.SetDefault("server.deterministicRNG", false)
+	viper.SetDefault("server.devMode", false)
 	viper.SetDefault("server.signatureVerifier", "aes.signature.verifier")
+	viper.SetDefault("server.hmac.maxClockSkew", "5m")
+	viper.SetDefault("server.hmac.nonceCacheSize", 100000)
+	viper.SetDefault("server.ed25519.maxClockSkew", "5m")
+	viper.SetDefault("server.ed25519.nonceCacheSize", 100000)
+	viper.SetDefault("server.maxRequestBodySize", 1048576)
+	viper.SetDefault("server.timePermit.maxRange", 31)
+	viper.SetDefault("server.authentication.enabled", false)
+	viper.SetDefault("server.tls.enabled", false)
+	viper.SetDefault("server.tls.clientAuth", "optional")
+	viper.SetDefault("server.authentication.sessionTimeout", "1m")
+	viper.SetDefault("server.authentication.maxSessions", 10000)
 
 	err := viper.ReadInConfig()
 	if err != nil {
@@ -57,8 +168,78 @@ func (config *Config) ParseDTAConfigFile() {
 	config.bindAddress = viper.GetString("server.address")
 	config.bindPort = viper.GetInt("server.port")
 	config.masterSecretStorage = viper.GetString("server.secret.storage")
+	config.gracePeriod = viper.GetDuration("server.secret.gracePeriod")
+	config.passphraseEnv = viper.GetString("server.secret.passphraseEnv")
+	config.pkcs11Module = viper.GetString("server.secret.pkcs11.module")
+	config.pkcs11TokenLabel = viper.GetString("server.secret.pkcs11.tokenLabel")
+	config.pkcs11KeyLabel = viper.GetString("server.secret.pkcs11.keyLabel")
+	config.pkcs11PINEnv = viper.GetString("server.secret.pkcs11.pinEnv")
+	config.rpaStore = viper.GetString("server.rpa.storage")
+	config.rpaSQLDriver = viper.GetString("server.rpa.sql.driver")
+	config.rpaSQLDataSource = viper.GetString("server.rpa.sql.dataSource")
+	config.rpaKeyGracePeriod = viper.GetDuration("server.rpa.keyGracePeriod")
+	config.revocationStore = viper.GetString("server.revocation.storage")
+	config.signingKeyStore = viper.GetString("server.signingKey.storage")
 	config.serverSeed = viper.GetString("server.seed")
+	config.deterministicRNG = viper.GetBool("server.deterministicRNG")
+	config.devMode = viper.GetBool("server.devMode")
 	config.signatureVerifier = viper.GetString("server.signatureVerifier")
+	config.hmacMaxClockSkew = viper.GetDuration("server.hmac.maxClockSkew")
+	config.hmacNonceCacheSize = viper.GetInt("server.hmac.nonceCacheSize")
+	config.ed25519MaxClockSkew = viper.GetDuration("server.ed25519.maxClockSkew")
+	config.ed25519NonceCache = viper.GetInt("server.ed25519.nonceCacheSize")
+	config.maxRequestBodySize = viper.GetInt64("server.maxRequestBodySize")
+	config.maxTimePermitRange = viper.GetInt("server.timePermit.maxRange")
+	config.authEnabled = viper.GetBool("server.authentication.enabled")
+	config.authSessionTimeout = viper.GetDuration("server.authentication.sessionTimeout")
+	config.authMaxSessions = viper.GetInt("server.authentication.maxSessions")
+	config.authDTAs = nil
+	if err := viper.UnmarshalKey("server.authentication.dtas", &config.authDTAs); err != nil {
+		log.Println("Error while reading server.authentication.dtas ", err.Error())
+	}
+	config.identityPolicies = nil
+	if err := viper.UnmarshalKey("server.identityPolicies", &config.identityPolicies); err != nil {
+		log.Println("Error while reading server.identityPolicies ", err.Error())
+	}
+	config.tlsEnabled = viper.GetBool("server.tls.enabled")
+	config.tlsCertFile = viper.GetString("server.tls.certFile")
+	config.tlsKeyFile = viper.GetString("server.tls.keyFile")
+	config.tlsClientCAFile = viper.GetString("server.tls.clientCAFile")
+	config.tlsClientAuth = viper.GetString("server.tls.clientAuth")
+	config.tlsClientIdentities = nil
+	if err := viper.UnmarshalKey("server.tls.clientIdentities", &config.tlsClientIdentities); err != nil {
+		log.Println("Error while reading server.tls.clientIdentities ", err.Error())
+	}
+	config.adminAddress = viper.GetString("server.admin.address")
+	config.grpcAddress = viper.GetString("server.grpc.address")
+	config.adminTokens = nil
+	if err := viper.UnmarshalKey("server.admin.tokens", &config.adminTokens); err != nil {
+		log.Println("Error while reading server.admin.tokens ", err.Error())
+	}
+	config.adminCertificates = nil
+	if err := viper.UnmarshalKey("server.admin.clientCertificates", &config.adminCertificates); err != nil {
+		log.Println("Error while reading server.admin.clientCertificates ", err.Error())
+	}
+}
+
+//Overrides the interface where the server should listen to expose the api
+func (config *Config) SetBindAddress(bindAddress string) {
+	config.bindAddress = bindAddress
+}
+
+//Overrides the port where the server should bind to expose the api
+func (config *Config) SetBindPort(bindPort int) {
+	config.bindPort = bindPort
+}
+
+//Overrides the master secret storage implementation. Accepts the same values as server.secret.storage
+func (config *Config) SetMasterSecretStorage(masterSecretStorage string) {
+	config.masterSecretStorage = masterSecretStorage
+}
+
+//Overrides the hex value of seed mixed into the random number generator source
+func (config *Config) SetRandomSeed(seedHex string) {
+	config.serverSeed = seedHex
 }
 
 //Returns interface where the server should listen to expose the api
@@ -80,34 +261,300 @@ func (config *Config) GetMasterSecretStorage() storage.MasterSecretStorage {
 		secretStorage = storage.PlainTextFileMasterSecretStorage{}
 		break
 	case "memory":
-		secretStorage = storage.InMemorySecretStorage{}
+		secretStorage = &storage.InMemorySecretStorage{}
 		break
+	case "encrypted.file":
+		secretStorage = config.GetEncryptedMasterSecretStorage()
+	case "pkcs11":
+		secretStorage = &storage.PKCS11MasterSecretStorage{
+			Module:     config.pkcs11Module,
+			TokenLabel: config.pkcs11TokenLabel,
+			KeyLabel:   config.pkcs11KeyLabel,
+			PIN:        os.Getenv(config.pkcs11PINEnv),
+		}
 	default:
 		secretStorage = storage.PlainTextFileMasterSecretStorage{}
 	}
 	return secretStorage
 }
 
-//Returns hex value of seed used to create random number generator source
+//Returns the encrypted.file master secret storage. It is also used to migrate the plain text file
+func (config *Config) GetEncryptedMasterSecretStorage() *storage.EncryptedFileMasterSecretStorage {
+	return &storage.EncryptedFileMasterSecretStorage{Passphrase: config.readMasterSecretPassphrase}
+}
+
+//Reads the passphrase of the encrypted master secret file from the environment variable named in
+//server.secret.passphraseEnv or prompts for it when running in a terminal
+func (config *Config) readMasterSecretPassphrase() ([]byte, error) {
+	if passphrase := os.Getenv(config.passphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("%s is not set and there is no terminal to prompt for the master secret passphrase", config.passphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "Master secret passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+//Returns how long an old master secret version is still served after rotating the master secret
+func (config *Config) GetMasterSecretGracePeriod() time.Duration {
+	return config.gracePeriod
+}
+
+//Overrides how long an old master secret version is still served after rotating the master secret
+func (config *Config) SetMasterSecretGracePeriod(gracePeriod time.Duration) {
+	config.gracePeriod = gracePeriod
+}
+
+//Returns hex value of seed mixed into the random number generator source. It is the only source in deterministic mode
 func (config *Config) GetRandomSeed() string {
 	return config.serverSeed
 }
 
+//Returns the maximum number of days that can be requested from /timePermits at once
+func (config *Config) GetMaxTimePermitRange() int {
+	return config.maxTimePermitRange
+}
+
+//Overrides the maximum number of days that can be requested from /timePermits at once
+func (config *Config) SetMaxTimePermitRange(days int) {
+	config.maxTimePermitRange = days
+}
+
+//Returns true if the random number generator should be seeded only from the configured seed. Used in tests to
+//get reproducible master secrets
+func (config *Config) IsDeterministicRNG() bool {
+	return config.deterministicRNG
+}
+
+//Overrides whether the random number generator is seeded only from the configured seed
+func (config *Config) SetDeterministicRNG(deterministic bool) {
+	config.deterministicRNG = deterministic
+}
+
+//Returns true if the D-TA runs in dev mode which allows insecure settings such as deterministic random numbers
+func (config *Config) IsDevMode() bool {
+	return config.devMode
+}
+
+//Overrides whether the D-TA runs in dev mode
+func (config *Config) SetDevMode(devMode bool) {
+	config.devMode = devMode
+}
+
+//Returns true if the M-Pin authentication server endpoints should be exposed
+func (config *Config) IsAuthenticationEnabled() bool {
+	return config.authEnabled
+}
+
+//Overrides whether the M-Pin authentication server endpoints should be exposed
+func (config *Config) SetAuthenticationEnabled(enabled bool) {
+	config.authEnabled = enabled
+}
+
+//Returns how long an authentication session waits for the second pass
+func (config *Config) GetAuthenticationSessionTimeout() time.Duration {
+	return config.authSessionTimeout
+}
+
+//Returns how many authentication sessions may wait for the second pass at a time. Further first passes are refused
+func (config *Config) GetAuthenticationMaxSessions() int {
+	return config.authMaxSessions
+}
+
+//Overrides how many authentication sessions may wait for the second pass at a time
+func (config *Config) SetAuthenticationMaxSessions(maxSessions int) {
+	config.authMaxSessions = maxSessions
+}
+
+//Returns the D-TAs from which the authentication server gets its server secret. If none is configured the server
+//secret of this D-TA is used
+func (config *Config) GetAuthenticationDTAs() []DTAEndpointConfig {
+	return config.authDTAs
+}
+
+//Overrides the D-TAs from which the authentication server gets its server secret
+func (config *Config) SetAuthenticationDTAs(dtas []DTAEndpointConfig) {
+	config.authDTAs = dtas
+}
+
+//Returns true if the API is served over HTTPS
+func (config *Config) IsTLSEnabled() bool {
+	return config.tlsEnabled
+}
+
+//Returns the PEM files of the server certificate and its private key
+func (config *Config) GetTLSCertificateFiles() (string, string) {
+	return config.tlsCertFile, config.tlsKeyFile
+}
+
+//Returns the PEM bundle of the CAs which issue client certificates. Client certificates are not requested if it is
+//empty
+func (config *Config) GetTLSClientCAFile() string {
+	return config.tlsClientCAFile
+}
+
+//Returns none, optional or required
+func (config *Config) GetTLSClientAuth() string {
+	return config.tlsClientAuth
+}
+
+//Returns the application IDs by the names of the client certificates which authenticate them
+func (config *Config) GetTLSClientIdentities() map[string]string {
+	identities := make(map[string]string)
+	for _, identity := range config.tlsClientIdentities {
+		identities[identity.Name] = identity.AppID
+	}
+	return identities
+}
+
+//Overrides the TLS settings. TLS is enabled if certFile is not empty
+func (config *Config) SetTLS(certFile string, keyFile string, clientCAFile string, clientAuth string, clientIdentities []TLSClientIdentityConfig) {
+	config.tlsEnabled = certFile != ""
+	config.tlsCertFile = certFile
+	config.tlsKeyFile = keyFile
+	config.tlsClientCAFile = clientCAFile
+	config.tlsClientAuth = clientAuth
+	config.tlsClientIdentities = clientIdentities
+}
+
+//Returns the host:port of the separate listener of the admin API. The admin API is served with the issuance
+//endpoints if it is empty
+func (config *Config) GetAdminAddress() string {
+	return config.adminAddress
+}
+
+//Overrides the address of the admin API listener
+func (config *Config) SetAdminAddress(address string) {
+	config.adminAddress = address
+}
+
+//Returns the host:port of the gRPC listener. The gRPC services are disabled if it is empty
+func (config *Config) GetGRPCAddress() string {
+	return config.grpcAddress
+}
+
+//Overrides the address of the gRPC listener
+func (config *Config) SetGRPCAddress(address string) {
+	config.grpcAddress = address
+}
+
+//Returns the bearer tokens of the admin API
+func (config *Config) GetAdminTokens() []AdminTokenConfig {
+	return config.adminTokens
+}
+
+//Overrides the bearer tokens of the admin API
+func (config *Config) SetAdminTokens(tokens []AdminTokenConfig) {
+	config.adminTokens = tokens
+}
+
+//Returns the client certificates of the admin API
+func (config *Config) GetAdminCertificates() []AdminCertificateConfig {
+	return config.adminCertificates
+}
+
+//Overrides the client certificates of the admin API
+func (config *Config) SetAdminCertificates(certificates []AdminCertificateConfig) {
+	config.adminCertificates = certificates
+}
+
+//Returns the identity policies by application ID
+func (config *Config) GetIdentityPolicies() (map[string][]policy.IdentityPolicy, error) {
+	identityPolicies := make(map[string][]policy.IdentityPolicy)
+	for _, appPolicies := range config.identityPolicies {
+		for _, params := range appPolicies.Policies {
+			policyType, _ := params["type"].(string)
+			identityPolicy, err := policy.New(policyType, params)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid identity policy for %s: %s", appPolicies.AppID, err.Error())
+			}
+			identityPolicies[appPolicies.AppID] = append(identityPolicies[appPolicies.AppID], identityPolicy)
+		}
+	}
+	return identityPolicies, nil
+}
+
+//Overrides the identity policies
+func (config *Config) SetIdentityPolicies(identityPolicies []IdentityPolicyConfig) {
+	config.identityPolicies = identityPolicies
+}
+
 //Return RPA storage implementation
 func (config *Config) GetRPAStorage() storage.RPAStorage {
 	storage_type := config.rpaStore
 	var rpaStorage storage.RPAStorage
 	switch storage_type {
-	case "inmemorystore":
-		rpaStorage = storage.InMemoryRPAManager{}
+	case "inmemorystore", "memory":
+		rpaStorage = &storage.InMemoryRPAManager{}
+		break
+	case "sql":
+		rpaStorage = &storage.SQLRPAStorage{Driver: config.rpaSQLDriver, DataSource: config.rpaSQLDataSource}
 		break
 	default:
-		rpaStorage = storage.InMemoryRPAManager{}
+		rpaStorage = &storage.InMemoryRPAManager{}
+	}
+	if err := rpaStorage.Init(context.Background()); err != nil {
+		log.Fatal(err.Error())
 	}
-	rpaStorage.Init()
 	return rpaStorage
 }
 
+//Overrides the RPA storage implementation. Accepts the same values as server.rpa.storage
+func (config *Config) SetRPAStorage(rpaStorage string) {
+	config.rpaStore = rpaStorage
+}
+
+//Returns how long the previous keys of a relying party application still verify requests after a new key is rolled
+func (config *Config) GetRPAKeyGracePeriod() time.Duration {
+	return config.rpaKeyGracePeriod
+}
+
+//Overrides the grace period of rolled RPA keys
+func (config *Config) SetRPAKeyGracePeriod(gracePeriod time.Duration) {
+	config.rpaKeyGracePeriod = gracePeriod
+}
+
+//Returns the storage of revoked client identities. It is initialised by the DTA
+func (config *Config) GetRevocationStorage() storage.RevocationStorage {
+	var revocationStorage storage.RevocationStorage
+	switch config.revocationStore {
+	case "memory":
+		revocationStorage = &storage.InMemoryRevocationStorage{}
+	case "json.file":
+		revocationStorage = &storage.FileRevocationStorage{}
+	default:
+		revocationStorage = &storage.FileRevocationStorage{}
+	}
+	return revocationStorage
+}
+
+//Overrides the revocation storage implementation. Accepts the same values as server.revocation.storage
+func (config *Config) SetRevocationStorage(revocationStorage string) {
+	config.revocationStore = revocationStorage
+}
+
+//Returns the storage of the key signing issued secrets. It is initialised by the DTA
+func (config *Config) GetSigningKeyStorage() storage.SigningKeyStorage {
+	var signingKeyStorage storage.SigningKeyStorage
+	switch config.signingKeyStore {
+	case "memory":
+		signingKeyStorage = &storage.InMemorySigningKeyStorage{}
+	case "plain.text.file":
+		signingKeyStorage = &storage.PlainTextFileSigningKeyStorage{}
+	default:
+		signingKeyStorage = &storage.PlainTextFileSigningKeyStorage{}
+	}
+	return signingKeyStorage
+}
+
+//Overrides the signing key storage implementation. Accepts the same values as server.signingKey.storage
+func (config *Config) SetSigningKeyStorage(signingKeyStorage string) {
+	config.signingKeyStore = signingKeyStorage
+}
+
 //Returns  SignatureVerifier implementation used to  validate M-Pin requests
 func (config *Config) GetSignatureVerifier() signature.SignatureVerifier {
 	sigVerifierImpl := config.signatureVerifier
@@ -116,8 +563,21 @@ func (config *Config) GetSignatureVerifier() signature.SignatureVerifier {
 	case "aes.signature.verifier":
 		sigVerifier = signature.AESSignatureVerifier{}
 		break
+	case signature.HMACSHA256:
+		sigVerifier = signature.NewHMACSignatureVerifier(config.hmacMaxClockSkew, config.hmacNonceCacheSize, config.maxRequestBodySize)
+		break
 	default:
 		sigVerifier = signature.AESSignatureVerifier{}
 	}
 	return sigVerifier
 }
+
+//Returns the verifier of requests of relying party applications with Ed25519 keys
+func (config *Config) GetPublicKeySignatureVerifier() signature.SignatureVerifier {
+	return signature.NewEd25519SignatureVerifier(config.ed25519MaxClockSkew, config.ed25519NonceCache, config.maxRequestBodySize)
+}
+
+//Overrides the signature verifier. Accepts the same values as server.signatureVerifier
+func (config *Config) SetSignatureVerifier(signatureVerifier string) {
+	config.signatureVerifier = signatureVerifier
+}
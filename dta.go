@@ -20,11 +20,17 @@
 package dta
 
 import (
-	"encoding/base64"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/hex"
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/policy"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
 	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
 	"github.com/pkg/errors"
 	"log"
@@ -33,73 +39,380 @@ import (
 const (
 	G1S = 2*amcl.MPIN_EFS + 1
 	G2S = 4 * amcl.MPIN_EGS
+	//Number of bytes read from crypto/rand to seed the random number generator
+	rngEntropySize = 128
 )
 
+var (
+	ErrUnknownKeyVersion = errors.New("Unknown master secret version")
+	ErrExpiredKeyVersion = errors.New("Master secret version is no longer served")
+	ErrRevokedIdentity   = errors.New("Client identity is revoked")
+)
+
+//D-TA issuing M-Pin secrets. It is safe to use a DTA from several goroutines once Init returns
 type DTA struct {
-	materSecret [amcl.MPIN_EGS]byte
-	rng         *amcl.RAND
+	//amcl.RAND keeps internal state, so every use must hold rngLock
+	rng     *amcl.RAND
+	rngLock sync.Mutex
+
+	secretStorage storage.MasterSecretStorage
+	//How long a master secret version is served after a newer version is activated
+	gracePeriod time.Duration
+	//Guards masterSecrets. Issuance takes the read lock and rotation the write lock
+	lock          sync.RWMutex
+	masterSecrets []storage.MasterSecret
+
+	revocations storage.RevocationStorage
+	//Identity policies by application ID, guarded by lock
+	identityPolicies map[string][]policy.IdentityPolicy
+
+	//Long-term key signing issued secrets. It never changes after Init
+	signingKey ed25519.PrivateKey
 }
 
-//Initialize the random number generator from the seed configured in dta-server.yaml and load master secret from secret
-//store.If the master secret store does not have secret then it generate new one and store back
+//Initialize the random number generator and load master secrets from secret store.If the master secret store does
+//not have secret then it generate new one and store back
 func (dta *DTA) Init(conf config.Config) error {
+	rng, err := newRNG(conf)
+	if err != nil {
+		return err
+	}
+
+	dta.rng = rng
+	dta.revocations = conf.GetRevocationStorage()
+	if err := dta.revocations.Init(); err != nil {
+		return errors.Wrap(err, "Error in loading revocations")
+	}
+	if err := dta.loadSigningKey(conf.GetSigningKeyStorage()); err != nil {
+		return errors.Wrap(err, "Error in loading the signing key")
+	}
+	identityPolicies, err := conf.GetIdentityPolicies()
+	if err != nil {
+		return err
+	}
+	dta.identityPolicies = identityPolicies
+	dta.gracePeriod = conf.GetMasterSecretGracePeriod()
+	dta.secretStorage = conf.GetMasterSecretStorage()
+	masterSecrets, err := dta.secretStorage.GetSecrets(context.Background())
+
+	switch storage.Kind(err) {
+	case nil:
+		dta.masterSecrets = dta.withoutWrappedSecrets(masterSecrets)
+		log.Println("Using exisitng master secret version ", dta.ActiveKeyVersion())
+	case storage.ErrNotFound:
+		log.Println("Generating new master secret")
+		if _, err := dta.RotateMasterSecret(context.Background()); err != nil {
+			return err
+		}
+	default:
+		return errors.Wrap(err, "Error in loading master secrets")
+	}
+
+	return nil
+
+}
+
+//Loads the signing key from the storage or generates and stores a new one if none is stored. Any other error fails,
+//rather than replacing a key which clients already trust
+func (dta *DTA) loadSigningKey(signingKeyStorage storage.SigningKeyStorage) error {
+	if err := signingKeyStorage.Init(); err != nil {
+		return err
+	}
+	signingKey, err := signingKeyStorage.GetSigningKey()
+	if storage.Kind(err) == storage.ErrNotFound {
+		log.Println("Generating new signing key")
+		if _, signingKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return err
+		}
+		if err := signingKeyStorage.SetSigningKey(signingKey); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "Error in loading signing key")
+	}
+	dta.signingKey = signingKey
+	log.Println("Using signing key ", signature.SigningKeyID(dta.VerificationKey()))
+	return nil
+}
+
+//Returns the public key which verifies the signatures of issued secrets
+func (dta *DTA) VerificationKey() ed25519.PublicKey {
+	return dta.signingKey.Public().(ed25519.PublicKey)
+}
+
+//Signs the issued secret as described in signature.IssuanceMessage. Returns the signature and the ID of the
+//verification key
+func (dta *DTA) SignIssuance(kind string, secret []byte, clientID string, date int, keyVersion int) ([]byte, string) {
+	return signature.SignIssuance(dta.signingKey, kind, secret, clientID, date, keyVersion), signature.SigningKeyID(dta.VerificationKey())
+}
+
+//Returns true once Init seeded the random number generator
+func (dta *DTA) IsRNGSeeded() bool {
+	dta.rngLock.Lock()
+	defer dta.rngLock.Unlock()
+	return dta.rng != nil
+}
+
+//Creates the random number generator seeded from the OS entropy source. The seed configured in dta-server.yaml is
+//mixed in as additional entropy. In deterministic mode only the configured seed is used, so every start generates the
+//same master secret. That is only allowed in dev mode
+func newRNG(conf config.Config) (*amcl.RAND, error) {
 	seedHex := conf.GetRandomSeed()
-	seed, err := hex.DecodeString(seedHex)
+	configuredSeed, err := hex.DecodeString(seedHex)
 	if err != nil {
 		log.Println("Error while deocoding seedhex ", seedHex, err.Error())
-		return err
+		return nil, err
 	}
+
+	var seed []byte
+	if conf.IsDeterministicRNG() {
+		log.Println("************************************************************************")
+		log.Println("WARNING: Random number generator is seeded only from server.seed")
+		log.Println("WARNING: Master secrets are predictable. Never use this mode in production")
+		log.Println("************************************************************************")
+		if !conf.IsDevMode() {
+			return nil, errors.New("Deterministic random number generator is only allowed when server.devMode is set")
+		}
+		seed = configuredSeed
+	} else {
+		entropy := make([]byte, rngEntropySize)
+		if _, err := rand.Read(entropy); err != nil {
+			return nil, errors.Wrap(err, "Error in reading entropy from the OS")
+		}
+		seed = append(entropy, configuredSeed...)
+	}
+
 	rng := amcl.NewRAND()
 	rng.Seed(len(seed), seed)
+	return rng, nil
+}
 
-	dta.rng = rng
+//Generates a new master secret and makes it the active version. Previous versions are still served for the
+//configured grace period. Returns the new version. If another DTA sharing the storage rotated first, the storage
+//returns storage.ErrConflict and the master secrets are reloaded so that the next rotation can succeed
+func (dta *DTA) RotateMasterSecret(ctx context.Context) (int, error) {
+	var masterSecret [amcl.MPIN_EGS]byte
+	defer clearSecret(masterSecret[:])
+	dta.lock.Lock()
+	defer dta.lock.Unlock()
 
-	masterSecretStorage := conf.GetMasterSecretStorage()
-	masterSecret, ok := masterSecretStorage.GetSecret()
+	dta.randomGenerate(masterSecret[:])
+	version := len(dta.masterSecrets) + 1
+	if len(dta.masterSecrets) > 0 {
+		version = dta.masterSecrets[len(dta.masterSecrets)-1].Version + 1
+	}
+	storeErr := dta.secretStorage.SetSecret(ctx, version, masterSecret[:])
+	if storeErr != nil && storage.Kind(storeErr) != storage.ErrConflict {
+		return 0, errors.Wrap(storeErr, "Error in storing master secret")
+	}
+	masterSecrets, err := dta.secretStorage.GetSecrets(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error in loading master secrets")
+	}
+	dta.masterSecrets = dta.withoutWrappedSecrets(masterSecrets)
+	if storeErr != nil {
+		return 0, storeErr
+	}
+	log.Println("Activated master secret version ", version)
+	return version, nil
+}
 
-	if !ok {
-		log.Println("Generating new master secret")
-		amcl.MPIN_RANDOM_GENERATE(dta.rng, dta.materSecret[:])
-		masterSecretStorage.SetSecret(dta.materSecret[:])
-	} else {
-		dta.materSecret = masterSecret
-		log.Println("Using exisitng master secret ", base64.StdEncoding.EncodeToString(dta.materSecret[:]))
+//Clears the secrets if the storage unwraps them for every issuance so that they are not kept in memory
+func (dta *DTA) withoutWrappedSecrets(masterSecrets []storage.MasterSecret) []storage.MasterSecret {
+	if _, ok := dta.secretStorage.(storage.MasterSecretUnwrapper); ok {
+		for i := range masterSecrets {
+			clearSecret(masterSecrets[i].Secret[:])
+		}
+	}
+	return masterSecrets
+}
 
+func clearSecret(secret []byte) {
+	for i := range secret {
+		secret[i] = 0
 	}
+}
 
-	return nil
+//Fills secret with a random number modulo the curve order
+func (dta *DTA) randomGenerate(secret []byte) {
+	dta.rngLock.Lock()
+	defer dta.rngLock.Unlock()
+	amcl.MPIN_RANDOM_GENERATE(dta.rng, secret)
+}
 
+//Returns the version of the master secret used when no version is requested
+func (dta *DTA) ActiveKeyVersion() int {
+	dta.lock.RLock()
+	defer dta.lock.RUnlock()
+	return len(dta.masterSecrets)
 }
 
-//Issues a server secret or error if there is error while generating it
+//Returns the master secret of the given version if it is the active one or it is still in the grace period
+func (dta *DTA) masterSecret(version int) ([amcl.MPIN_EGS]byte, error) {
+	dta.lock.RLock()
+	defer dta.lock.RUnlock()
+	if version < 1 || version > len(dta.masterSecrets) {
+		return [amcl.MPIN_EGS]byte{}, ErrUnknownKeyVersion
+	}
+	if version < len(dta.masterSecrets) {
+		//The next version is stored at index version
+		supersededAt := dta.masterSecrets[version].ActivatedAt
+		if time.Since(supersededAt) > dta.gracePeriod {
+			return [amcl.MPIN_EGS]byte{}, ErrExpiredKeyVersion
+		}
+	}
+	if unwrapper, ok := dta.secretStorage.(storage.MasterSecretUnwrapper); ok {
+		return unwrapper.UnwrapSecret(version)
+	}
+	return dta.masterSecrets[version-1].Secret, nil
+}
+
+//Issues a server secret using the active master secret or error if there is error while generating it
 func (dta *DTA) IssueServerSecret() ([]byte, error) {
+	return dta.IssueServerSecretWithVersion(dta.ActiveKeyVersion())
+}
+
+//Issues a server secret using the given version of the master secret or error if there is error while generating it
+func (dta *DTA) IssueServerSecretWithVersion(version int) ([]byte, error) {
 	var serverSecret [G2S]byte
-	fmt.Println("Using master secret ", base64.StdEncoding.EncodeToString(dta.materSecret[:]))
-	rtn := amcl.MPIN_GET_SERVER_SECRET(dta.materSecret[:], serverSecret[:])
+	materSecret, err := dta.masterSecret(version)
+	if err != nil {
+		return serverSecret[:], err
+	}
+	defer clearSecret(materSecret[:])
+	rtn := amcl.MPIN_GET_SERVER_SECRET(materSecret[:], serverSecret[:])
 	if rtn != 0 {
 		return serverSecret[:], errors.New("Error in generating server secret")
 	}
-	fmt.Println("Returning  server secret ", base64.StdEncoding.EncodeToString(serverSecret[:]))
 	return serverSecret[:], nil
 }
 
-//Issues a client secret for given hashed client id or error if there is error while generating it
+//Issues a client secret for given hashed client id using the active master secret or error if there is error while
+//generating it
 func (dta *DTA) IssueClientSecret(clientID []byte) ([]byte, error) {
+	return dta.IssueClientSecretWithVersion(clientID, dta.ActiveKeyVersion())
+}
+
+//Issues a client secret for given hashed client id using the given version of the master secret or error if there is
+//error while generating it
+func (dta *DTA) IssueClientSecretWithVersion(clientID []byte, version int) ([]byte, error) {
 	var clientSecret [G1S]byte
+	materSecret, err := dta.masterSecret(version)
+	if err != nil {
+		return clientSecret[:], err
+	}
+	defer clearSecret(materSecret[:])
 
-	rtn := amcl.MPIN_GET_CLIENT_SECRET(dta.materSecret[:], clientID, clientSecret[:])
+	rtn := amcl.MPIN_GET_CLIENT_SECRET(materSecret[:], clientID, clientSecret[:])
 	if rtn != 0 {
 		return clientSecret[:], errors.New("Error in generating client secret")
 	}
-	fmt.Println("Client Secret size ", len(clientSecret))
 	return clientSecret[:], nil
 }
 
-//Issues a time permit for given hashed client id or error if there is error while generating it
+//Issues a client secret for the client ID of the relying party application using the given version of the master
+//secret. Returns ErrRevokedIdentity if the client ID is revoked for the application or *policy.PolicyViolation if an
+//identity policy of the application rejects it
+func (dta *DTA) IssueClientSecretForApp(appID string, clientID string, version int) ([]byte, error) {
+	if dta.IsRevoked(appID, clientID) {
+		return nil, ErrRevokedIdentity
+	}
+	if err := policy.Check(dta.getIdentityPolicies(appID), clientID); err != nil {
+		return nil, err
+	}
+	return dta.IssueClientSecretWithVersion(amcl.MPIN_HASH_ID([]byte(clientID)), version)
+}
+
+//Issues time permits for the client ID of the relying party application for every epoch day from first to last
+//(inclusive) using the given version of the master secret. Returns ErrRevokedIdentity if the client ID is revoked
+//for the application
+func (dta *DTA) IssueTimePermitsForApp(appID string, clientID string, first int, last int, version int) ([][]byte, error) {
+	if dta.IsRevoked(appID, clientID) {
+		return nil, ErrRevokedIdentity
+	}
+	hashedClientID := amcl.MPIN_HASH_ID([]byte(clientID))
+	var timePermits [][]byte
+	for date := first; date <= last; date++ {
+		timePermit, err := dta.IssueTimePermitWithVersion(hashedClientID, date, version)
+		if err != nil {
+			return nil, err
+		}
+		timePermits = append(timePermits, timePermit)
+	}
+	return timePermits, nil
+}
+
+//Replaces the identity policies of the relying party application. Use "*" for applications without their own
+//policies
+func (dta *DTA) SetIdentityPolicies(appID string, policies []policy.IdentityPolicy) {
+	dta.lock.Lock()
+	defer dta.lock.Unlock()
+	if dta.identityPolicies == nil {
+		dta.identityPolicies = make(map[string][]policy.IdentityPolicy)
+	}
+	dta.identityPolicies[appID] = policies
+}
+
+func (dta *DTA) getIdentityPolicies(appID string) []policy.IdentityPolicy {
+	dta.lock.RLock()
+	defer dta.lock.RUnlock()
+	if policies, ok := dta.identityPolicies[appID]; ok {
+		return policies
+	}
+	return dta.identityPolicies["*"]
+}
+
+//Returns true if the client ID must not get secrets or time permits from the relying party application
+func (dta *DTA) IsRevoked(appID string, clientID string) bool {
+	_, revoked := dta.revocations.GetRevocation(appID, clientID)
+	return revoked
+}
+
+//Revokes the client ID for the relying party application
+func (dta *DTA) Revoke(appID string, clientID string, reason string) (storage.Revocation, error) {
+	revocation := storage.Revocation{AppID: appID, ClientID: clientID, Reason: reason, RevokedAt: time.Now().UTC()}
+	if err := dta.revocations.Revoke(revocation); err != nil {
+		return revocation, errors.Wrap(err, "Error in storing revocation")
+	}
+	log.Println("Revoked ", clientID, " for ", appID, ": ", reason)
+	return revocation, nil
+}
+
+//Removes the revocation of the client ID. Returns false if it was not revoked
+func (dta *DTA) Unrevoke(appID string, clientID string) (bool, error) {
+	removed, err := dta.revocations.Unrevoke(appID, clientID)
+	if err != nil {
+		return false, errors.Wrap(err, "Error in removing revocation")
+	}
+	return removed, nil
+}
+
+//Returns the revoked client IDs of the relying party application
+func (dta *DTA) GetRevocations(appID string) []storage.Revocation {
+	return dta.revocations.GetRevocations(appID)
+}
+
+//Issues a time permit of today for given hashed client id or error if there is error while generating it
 func (dta *DTA) IssueTimePermit(hashed_client_id []byte) ([]byte, error) {
+	return dta.IssueTimePermitForDate(hashed_client_id, amcl.MPIN_today())
+}
+
+//Issues a time permit for given hashed client id which is valid on the given epoch day (days since 1970-01-01 UTC)
+//or error if there is error while generating it
+func (dta *DTA) IssueTimePermitForDate(hashed_client_id []byte, date int) ([]byte, error) {
+	return dta.IssueTimePermitWithVersion(hashed_client_id, date, dta.ActiveKeyVersion())
+}
+
+//Issues a time permit for given hashed client id and epoch day using the given version of the master secret or error
+//if there is error while generating it
+func (dta *DTA) IssueTimePermitWithVersion(hashed_client_id []byte, date int, version int) ([]byte, error) {
 	var timePermit [G1S]byte
-	date := amcl.MPIN_today()
-	rtn := amcl.MPIN_GET_CLIENT_PERMIT(date, dta.materSecret[:], hashed_client_id, timePermit[:])
+	materSecret, err := dta.masterSecret(version)
+	if err != nil {
+		return timePermit[:], err
+	}
+	defer clearSecret(materSecret[:])
+	rtn := amcl.MPIN_GET_CLIENT_PERMIT(date, materSecret[:], hashed_client_id, timePermit[:])
 	if rtn != 0 {
 		return timePermit[:], errors.New("Error in generating time permit")
 	}
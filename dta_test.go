@@ -20,10 +20,20 @@
 package dta
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
 	"github.com/ajanthan/apache-milagro-dta/config"
+	"github.com/ajanthan/apache-milagro-dta/policy"
+	"github.com/ajanthan/apache-milagro-dta/signature"
+	"github.com/ajanthan/apache-milagro-dta/storage"
 	"github.com/ajanthan/apache-milagro-dta/utils"
 	"github.com/miracl/amcl-go"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDTA_Basic(t *testing.T) {
@@ -56,5 +66,427 @@ func TestDTA_Basic(t *testing.T) {
 	} else {
 		timePermit = rtn
 	}
-	utils.ValidateMpin(serverSecret, clientSecret, timePermit, clientID, 9876)
+	if err := utils.ValidateMpin(serverSecret, clientSecret, timePermit, clientID, 9876); err != nil {
+		t.Error("M-Pin authentication failed ", err.Error())
+	}
+}
+
+func TestDTA_TimePermitForDate(t *testing.T) {
+	dta := DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	dta.Init(conf)
+	hashedClientID := amcl.MPIN_HASH_ID([]byte("apacheuser@apache.org"))
+
+	today := amcl.MPIN_today()
+	permit, err := dta.IssueTimePermit(hashedClientID)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	permitToday, err := dta.IssueTimePermitForDate(hashedClientID, today)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(permit, permitToday) {
+		t.Error("Time permit for today should match the permit issued for the current date")
+	}
+	permitTomorrow, err := dta.IssueTimePermitForDate(hashedClientID, today+1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if bytes.Equal(permitToday, permitTomorrow) {
+		t.Error("Time permits for different dates should not match")
+	}
+}
+
+func TestDTA_RotateMasterSecret(t *testing.T) {
+	dta := DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	conf.SetMasterSecretGracePeriod(time.Hour)
+	if err := dta.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if dta.ActiveKeyVersion() != 1 {
+		t.Fatal("Expected version 1 for a new master secret but got ", dta.ActiveKeyVersion())
+	}
+	serverSecretV1, err := dta.IssueServerSecret()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	version, err := dta.RotateMasterSecret(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if version != 2 || dta.ActiveKeyVersion() != 2 {
+		t.Fatal("Expected version 2 after rotation but got ", version)
+	}
+	serverSecretV2, err := dta.IssueServerSecret()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if bytes.Equal(serverSecretV1, serverSecretV2) {
+		t.Error("Server secret should change after rotating the master secret")
+	}
+
+	//Old version is still served during the grace period
+	oldServerSecret, err := dta.IssueServerSecretWithVersion(1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(serverSecretV1, oldServerSecret) {
+		t.Error("Server secret of version 1 should not change after rotation")
+	}
+	if _, err := dta.IssueServerSecretWithVersion(3); err != ErrUnknownKeyVersion {
+		t.Error("Expected unknown key version error but got ", err)
+	}
+
+	//Old version expires after the grace period
+	dta.gracePeriod = 0
+	if _, err := dta.IssueClientSecretWithVersion(amcl.MPIN_HASH_ID([]byte("apacheuser@apache.org")), 1); err != ErrExpiredKeyVersion {
+		t.Error("Expected expired key version error but got ", err)
+	}
+}
+
+func TestDTA_DeterministicRNG(t *testing.T) {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	conf.SetDeterministicRNG(true)
+
+	if err := (&DTA{}).Init(conf); err == nil {
+		t.Fatal("Deterministic random number generator should be refused outside dev mode")
+	}
+
+	conf.SetDevMode(true)
+	dta1 := DTA{}
+	if err := dta1.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	dta2 := DTA{}
+	if err := dta2.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	serverSecret1, _ := dta1.IssueServerSecret()
+	serverSecret2, _ := dta2.IssueServerSecret()
+	if !bytes.Equal(serverSecret1, serverSecret2) {
+		t.Error("Same seed should generate the same master secret in deterministic mode")
+	}
+}
+
+func TestDTA_RNGSeededFromOS(t *testing.T) {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+
+	dta1 := DTA{}
+	if err := dta1.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	dta2 := DTA{}
+	if err := dta2.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	serverSecret1, _ := dta1.IssueServerSecret()
+	serverSecret2, _ := dta2.IssueServerSecret()
+	if bytes.Equal(serverSecret1, serverSecret2) {
+		t.Error("Same configured seed should not generate the same master secret")
+	}
+}
+
+//Issues client secrets and time permits from many goroutines while the master secret is rotated. Run with -race
+func TestDTA_ConcurrentIssuance(t *testing.T) {
+	identities := 1000
+	if testing.Short() {
+		identities = 50
+	}
+	dta := DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	conf.SetMasterSecretGracePeriod(time.Hour)
+	if err := dta.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, identities)
+	for i := 0; i < identities; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%(identities/5) == 0 {
+				if _, err := dta.RotateMasterSecret(context.Background()); err != nil {
+					errs <- err
+					return
+				}
+			}
+			clientID := fmt.Sprintf("user%d@apache.org", i)
+			hashedClientID := amcl.MPIN_HASH_ID([]byte(clientID))
+			version := dta.ActiveKeyVersion()
+			serverSecret, err := dta.IssueServerSecretWithVersion(version)
+			if err != nil {
+				errs <- err
+				return
+			}
+			clientSecret, err := dta.IssueClientSecretWithVersion(hashedClientID, version)
+			if err != nil {
+				errs <- err
+				return
+			}
+			timePermit, err := dta.IssueTimePermitWithVersion(hashedClientID, amcl.MPIN_today(), version)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := utils.ValidateMpin(serverSecret, clientSecret, timePermit, clientID, 1111); err != nil {
+				errs <- fmt.Errorf("%s: %s", clientID, err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err.Error())
+	}
+}
+
+//Reports issuance throughput per core. Compare with -cpu 1,2,4
+func benchmarkIssuance(b *testing.B, issue func(dta *DTA, hashedClientID []byte) error) {
+	dta := DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	if err := dta.Init(conf); err != nil {
+		b.Fatal(err.Error())
+	}
+	hashedClientID := amcl.MPIN_HASH_ID([]byte("apacheuser@apache.org"))
+
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := issue(&dta, hashedClientID); err != nil {
+				b.Error(err.Error())
+			}
+		}
+	})
+	elapsed := time.Since(start).Seconds()
+	b.ReportMetric(float64(b.N)/elapsed/float64(runtime.GOMAXPROCS(0)), "issuances/s/core")
+}
+
+func BenchmarkDTA_IssueServerSecret(b *testing.B) {
+	benchmarkIssuance(b, func(dta *DTA, hashedClientID []byte) error {
+		_, err := dta.IssueServerSecret()
+		return err
+	})
+}
+
+func BenchmarkDTA_IssueClientSecret(b *testing.B) {
+	benchmarkIssuance(b, func(dta *DTA, hashedClientID []byte) error {
+		_, err := dta.IssueClientSecret(hashedClientID)
+		return err
+	})
+}
+
+func BenchmarkDTA_IssueTimePermit(b *testing.B) {
+	benchmarkIssuance(b, func(dta *DTA, hashedClientID []byte) error {
+		_, err := dta.IssueTimePermit(hashedClientID)
+		return err
+	})
+}
+
+func TestDTA_RevokedIdentity(t *testing.T) {
+	dta := DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	conf.SetRevocationStorage("memory")
+	if err := dta.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	clientID := "apacheuser@apache.org"
+	version := dta.ActiveKeyVersion()
+	today := amcl.MPIN_today()
+
+	if _, err := dta.Revoke("app1", clientID, "compromised"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := dta.IssueClientSecretForApp("app1", clientID, version); err != ErrRevokedIdentity {
+		t.Error("Client secret should be refused for a revoked identity but got ", err)
+	}
+	if _, err := dta.IssueTimePermitsForApp("app1", clientID, today, today, version); err != ErrRevokedIdentity {
+		t.Error("Time permit should be refused for a revoked identity but got ", err)
+	}
+	if _, err := dta.IssueClientSecretForApp("app2", clientID, version); err != nil {
+		t.Error("Revocation should only apply to the application which revoked the identity ", err)
+	}
+
+	if removed, err := dta.Unrevoke("app1", clientID); !removed || err != nil {
+		t.Fatal("Revocation should be removed ", err)
+	}
+	if _, err := dta.IssueTimePermitsForApp("app1", clientID, today, today+1, version); err != nil {
+		t.Error("Time permits should be issued after removing the revocation ", err)
+	}
+}
+
+func TestDTA_IdentityPolicies(t *testing.T) {
+	dta := DTA{}
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	conf.SetRevocationStorage("memory")
+	conf.SetIdentityPolicies([]config.IdentityPolicyConfig{
+		{AppID: "*", Policies: []map[string]interface{}{{"type": "email"}}},
+		{AppID: "app1", Policies: []map[string]interface{}{
+			{"type": "email"},
+			{"type": "domain", "allow": []interface{}{"apache.org"}},
+		}},
+	})
+	if err := dta.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	version := dta.ActiveKeyVersion()
+
+	if _, err := dta.IssueClientSecretForApp("app1", "apacheuser@apache.org", version); err != nil {
+		t.Error("Client secret should be issued for an allowed identity ", err)
+	}
+	_, err := dta.IssueClientSecretForApp("app1", "apacheuser@example.com", version)
+	if violation, ok := err.(*policy.PolicyViolation); !ok || violation.Policy != "domain" {
+		t.Error("Domain policy should reject the identity but got ", err)
+	}
+	if _, err := dta.IssueClientSecretForApp("app2", "apacheuser@example.com", version); err != nil {
+		t.Error("Default policies should apply to applications without their own ", err)
+	}
+	if _, err := dta.IssueClientSecretForApp("app2", "apacheuser", version); err == nil {
+		t.Error("Default email policy should reject an identity which is not an email address")
+	}
+
+	dta.SetIdentityPolicies("app2", nil)
+	if _, err := dta.IssueClientSecretForApp("app2", "apacheuser", version); err != nil {
+		t.Error("Client secret should be issued when the application has no policies ", err)
+	}
+}
+
+func TestDTA_SignIssuance(t *testing.T) {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetMasterSecretStorage("memory")
+	conf.SetSigningKeyStorage("memory")
+	dta := DTA{}
+	if err := dta.Init(conf); err != nil {
+		t.Fatal(err.Error())
+	}
+	clientID := "apacheuser@apache.org"
+	secret, err := dta.IssueClientSecretForApp("app1", clientID, dta.ActiveKeyVersion())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sig, keyID := dta.SignIssuance(signature.IssuanceClientSecret, secret, clientID, 0, dta.ActiveKeyVersion())
+	if keyID != signature.SigningKeyID(dta.VerificationKey()) {
+		t.Error("Signature should name the key from VerificationKey")
+	}
+	if !signature.VerifyIssuance(dta.VerificationKey(), sig, signature.IssuanceClientSecret, secret, clientID, 0, dta.ActiveKeyVersion()) {
+		t.Error("Client secret signature should be verified with the verification key")
+	}
+}
+
+//Signing key storage which cannot be read
+type unreadableSigningKeyStorage struct {
+	storage.InMemorySigningKeyStorage
+	replaced bool
+}
+
+func (signingKeyStorage *unreadableSigningKeyStorage) GetSigningKey() (ed25519.PrivateKey, error) {
+	return nil, fmt.Errorf("Permission denied")
+}
+
+func (signingKeyStorage *unreadableSigningKeyStorage) SetSigningKey(key ed25519.PrivateKey) error {
+	signingKeyStorage.replaced = true
+	return nil
+}
+
+func TestDTA_UnreadableSigningKey(t *testing.T) {
+	signingKeyStorage := &unreadableSigningKeyStorage{}
+	dta := DTA{}
+	if err := dta.loadSigningKey(signingKeyStorage); err == nil {
+		t.Error("Signing key which cannot be read should fail the start up")
+	}
+	if signingKeyStorage.replaced {
+		t.Error("Signing key which cannot be read should not be replaced")
+	}
+}
+
+//Master secret storage which counts the unwrapped versions like the PKCS#11 storage
+type unwrappingSecretStorage struct {
+	storage.InMemorySecretStorage
+	unwraps int
+}
+
+func (secretStorage *unwrappingSecretStorage) UnwrapSecret(version int) ([amcl.MPIN_EGS]byte, error) {
+	secretStorage.unwraps++
+	masterSecrets, _ := secretStorage.GetSecrets(context.Background())
+	return masterSecrets[version-1].Secret, nil
+}
+
+func TestDTA_UnwrapsSecretForEveryIssuance(t *testing.T) {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	conf.SetSigningKeyStorage("memory")
+	dta := DTA{}
+	dta.rng, _ = newRNG(conf)
+	secretStorage := &unwrappingSecretStorage{}
+	dta.secretStorage = secretStorage
+	dta.gracePeriod = time.Hour
+	if _, err := dta.RotateMasterSecret(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if dta.masterSecrets[0].Secret != ([amcl.MPIN_EGS]byte{}) {
+		t.Error("Wrapped master secrets should not be kept in memory")
+	}
+	if _, err := dta.IssueServerSecret(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := dta.IssueTimePermit(amcl.MPIN_HASH_ID([]byte("apacheuser@apache.org"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	if secretStorage.unwraps != 2 {
+		t.Error("Master secret should be unwrapped for every issuance but got ", secretStorage.unwraps)
+	}
+}
+
+func TestDTA_ConcurrentRotationConflict(t *testing.T) {
+	conf := config.Config{}
+	conf.ParseDTAConfigFile()
+	secretStorage := &storage.InMemorySecretStorage{}
+	newDTA := func() *DTA {
+		dta := &DTA{}
+		dta.rng, _ = newRNG(conf)
+		dta.secretStorage = secretStorage
+		dta.gracePeriod = time.Hour
+		return dta
+	}
+	first, second := newDTA(), newDTA()
+	ctx := context.Background()
+	if _, err := first.RotateMasterSecret(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := second.RotateMasterSecret(ctx); storage.Kind(err) != storage.ErrConflict {
+		t.Fatal("Rotating from a stale version should be a conflict but got ", err)
+	}
+	if second.ActiveKeyVersion() != 1 {
+		t.Error("Master secrets should be reloaded after a conflict but got version ", second.ActiveKeyVersion())
+	}
+	if version, err := second.RotateMasterSecret(ctx); err != nil || version != 2 {
+		t.Error("Rotation after reloading should succeed ", version, err)
+	}
 }
@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register("email", newEmailPolicy)
+	Register("domain", newDomainPolicy)
+	Register("regex", newRegexPolicy)
+	Register("maxLength", newMaxLengthPolicy)
+}
+
+//Allows only identities which are plain email addresses such as user@apache.org
+type EmailPolicy struct {
+}
+
+func newEmailPolicy(params map[string]interface{}) (IdentityPolicy, error) {
+	return EmailPolicy{}, nil
+}
+
+func (policy EmailPolicy) Name() string {
+	return "email"
+}
+
+func (policy EmailPolicy) Check(clientID string) error {
+	address, err := mail.ParseAddress(clientID)
+	if err != nil || address.Address != clientID || address.Name != "" {
+		return &PolicyViolation{Policy: policy.Name(), Reason: "not an email address"}
+	}
+	return nil
+}
+
+//Checks the domain of email identities. If Allow is not empty the domain must be one of them and it must never be
+//one of Deny. Domains are compared case insensitively
+type DomainPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+func newDomainPolicy(params map[string]interface{}) (IdentityPolicy, error) {
+	allow, err := stringListParam(params, "allow")
+	if err != nil {
+		return nil, err
+	}
+	deny, err := stringListParam(params, "deny")
+	if err != nil {
+		return nil, err
+	}
+	return DomainPolicy{Allow: allow, Deny: deny}, nil
+}
+
+func (policy DomainPolicy) Name() string {
+	return "domain"
+}
+
+func (policy DomainPolicy) Check(clientID string) error {
+	at := strings.LastIndex(clientID, "@")
+	if at < 0 {
+		return &PolicyViolation{Policy: policy.Name(), Reason: "identity has no domain"}
+	}
+	domain := strings.ToLower(clientID[at+1:])
+	for _, denied := range policy.Deny {
+		if domain == strings.ToLower(denied) {
+			return &PolicyViolation{Policy: policy.Name(), Reason: "domain " + domain + " is denied"}
+		}
+	}
+	if len(policy.Allow) == 0 {
+		return nil
+	}
+	for _, allowed := range policy.Allow {
+		if domain == strings.ToLower(allowed) {
+			return nil
+		}
+	}
+	return &PolicyViolation{Policy: policy.Name(), Reason: "domain " + domain + " is not allowed"}
+}
+
+//Allows only identities matching the regular expression. The pattern should be anchored to match whole identities
+type RegexPolicy struct {
+	Pattern *regexp.Regexp
+}
+
+func newRegexPolicy(params map[string]interface{}) (IdentityPolicy, error) {
+	pattern, ok := params["pattern"].(string)
+	if !ok || pattern == "" {
+		return nil, fmt.Errorf("regex policy needs a pattern")
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regex policy pattern %s: %s", pattern, err.Error())
+	}
+	return RegexPolicy{Pattern: compiled}, nil
+}
+
+func (policy RegexPolicy) Name() string {
+	return "regex"
+}
+
+func (policy RegexPolicy) Check(clientID string) error {
+	if !policy.Pattern.MatchString(clientID) {
+		return &PolicyViolation{Policy: policy.Name(), Reason: "identity does not match " + policy.Pattern.String()}
+	}
+	return nil
+}
+
+//Allows only identities up to Max bytes
+type MaxLengthPolicy struct {
+	Max int
+}
+
+func newMaxLengthPolicy(params map[string]interface{}) (IdentityPolicy, error) {
+	var max int
+	switch value := params["max"].(type) {
+	case int:
+		max = value
+	case int64:
+		max = int(value)
+	case float64:
+		max = int(value)
+	}
+	if max <= 0 {
+		return nil, fmt.Errorf("maxLength policy needs a positive max")
+	}
+	return MaxLengthPolicy{Max: max}, nil
+}
+
+func (policy MaxLengthPolicy) Name() string {
+	return "maxLength"
+}
+
+func (policy MaxLengthPolicy) Check(clientID string) error {
+	if len(clientID) > policy.Max {
+		return &PolicyViolation{Policy: policy.Name(), Reason: fmt.Sprintf("identity is longer than %d", policy.Max)}
+	}
+	return nil
+}
+
+func stringListParam(params map[string]interface{}, name string) ([]string, error) {
+	value, ok := params[name]
+	if !ok {
+		return nil, nil
+	}
+	if strs, ok := value.([]string); ok {
+		return strs, nil
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s should be a list", name)
+	}
+	var strs []string
+	for _, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s should be a list of strings", name)
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
@@ -0,0 +1,93 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+//Decides whether a client secret may be issued for an identity
+type IdentityPolicy interface {
+	//Name used in policy violations
+	Name() string
+	//Returns a *PolicyViolation if the identity is not allowed
+	Check(clientID string) error
+}
+
+//Error returned when an identity is rejected by a policy
+type PolicyViolation struct {
+	Policy string
+	Reason string
+}
+
+func (violation *PolicyViolation) Error() string {
+	return fmt.Sprintf("Identity rejected by policy %s: %s", violation.Policy, violation.Reason)
+}
+
+//Creates a policy from its parameters in dta-server.yaml
+type PolicyFactory func(params map[string]interface{}) (IdentityPolicy, error)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = map[string]PolicyFactory{}
+)
+
+//Registers a policy type so that it can be used in dta-server.yaml. Custom policies must be registered before the
+//configuration is loaded
+func Register(policyType string, factory PolicyFactory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+	factories[policyType] = factory
+}
+
+//Creates a policy of a registered type
+func New(policyType string, params map[string]interface{}) (IdentityPolicy, error) {
+	factoriesLock.RLock()
+	factory, ok := factories[policyType]
+	factoriesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown identity policy type %s", policyType)
+	}
+	return factory(params)
+}
+
+//Returns the registered policy types
+func Types() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+	var policyTypes []string
+	for policyType := range factories {
+		policyTypes = append(policyTypes, policyType)
+	}
+	sort.Strings(policyTypes)
+	return policyTypes
+}
+
+//Checks the identity against every policy and returns the first violation
+func Check(policies []IdentityPolicy, clientID string) error {
+	for _, policy := range policies {
+		if err := policy.Check(clientID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
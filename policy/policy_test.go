@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package policy
+
+import (
+	"testing"
+)
+
+func TestBuiltinPolicies(t *testing.T) {
+	cases := []struct {
+		policyType string
+		params     map[string]interface{}
+		clientID   string
+		allowed    bool
+	}{
+		{"email", nil, "apacheuser@apache.org", true},
+		{"email", nil, "apacheuser", false},
+		{"email", nil, "Apache User <apacheuser@apache.org>", false},
+		{"domain", map[string]interface{}{"allow": []interface{}{"apache.org"}}, "apacheuser@APACHE.org", true},
+		{"domain", map[string]interface{}{"allow": []interface{}{"apache.org"}}, "apacheuser@example.com", false},
+		{"domain", map[string]interface{}{"deny": []string{"example.com"}}, "apacheuser@example.com", false},
+		{"domain", map[string]interface{}{"deny": []string{"example.com"}}, "apacheuser@apache.org", true},
+		{"regex", map[string]interface{}{"pattern": "^[a-z]+@apache\\.org$"}, "apacheuser@apache.org", true},
+		{"regex", map[string]interface{}{"pattern": "^[a-z]+@apache\\.org$"}, "apache.user@apache.org", false},
+		{"maxLength", map[string]interface{}{"max": 10}, "apacheuser", true},
+		{"maxLength", map[string]interface{}{"max": float64(10)}, "apacheuser1", false},
+	}
+	for _, c := range cases {
+		policy, err := New(c.policyType, c.params)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = policy.Check(c.clientID)
+		if c.allowed && err != nil {
+			t.Errorf("%s should allow %s but got %s", c.policyType, c.clientID, err.Error())
+		}
+		if !c.allowed {
+			if violation, ok := err.(*PolicyViolation); !ok || violation.Policy != c.policyType {
+				t.Errorf("%s should reject %s but got %v", c.policyType, c.clientID, err)
+			}
+		}
+	}
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	if _, err := New("unknown", nil); err == nil {
+		t.Error("Unknown policy type should fail")
+	}
+	if _, err := New("regex", map[string]interface{}{"pattern": "("}); err == nil {
+		t.Error("Invalid pattern should fail")
+	}
+	if _, err := New("maxLength", map[string]interface{}{}); err == nil {
+		t.Error("maxLength without max should fail")
+	}
+	if _, err := New("domain", map[string]interface{}{"allow": "apache.org"}); err == nil {
+		t.Error("allow which is not a list should fail")
+	}
+}
+
+type denyAllPolicy struct {
+}
+
+func (policy denyAllPolicy) Name() string {
+	return "denyAll"
+}
+
+func (policy denyAllPolicy) Check(clientID string) error {
+	return &PolicyViolation{Policy: policy.Name(), Reason: "no identities are allowed"}
+}
+
+func TestRegisterCustomPolicy(t *testing.T) {
+	Register("denyAll", func(params map[string]interface{}) (IdentityPolicy, error) {
+		return denyAllPolicy{}, nil
+	})
+	policy, err := New("denyAll", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	email, _ := New("email", nil)
+	if err := Check([]IdentityPolicy{email, policy}, "apacheuser@apache.org"); err == nil {
+		t.Error("Custom policy should reject the identity")
+	}
+	if err := Check(nil, "anything"); err != nil {
+		t.Error("No policies should allow every identity")
+	}
+}
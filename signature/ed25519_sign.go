@@ -0,0 +1,37 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"net/url"
+)
+
+//Name of the Ed25519 request signing scheme of relying party applications which registered a public key
+const Ed25519 = "ed25519"
+
+//Adds the timestamp and a new nonce to the query and returns the Ed25519 signature of the canonical request. The
+//signature must be added to the query as the signature parameter
+func SignRequestEd25519(privateKey ed25519.PrivateKey, method string, path string, query url.Values, body []byte) ([]byte, error) {
+	if err := addTimestampAndNonce(query); err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(privateKey, []byte(CanonicalRequest(Ed25519, method, path, query, body))), nil
+}
@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"time"
+)
+
+//Verifies requests signed with SignRequestEd25519 by the public key of the application. Like HMACSignatureVerifier it
+//accepts requests only within MaxClockSkew of their timestamp and only once
+type Ed25519SignatureVerifier struct {
+	MaxClockSkew time.Duration
+	//Requests with a larger body are refused
+	MaxBodySize int64
+	nonces      *nonceCache
+}
+
+//Creates a verifier which remembers at most nonceCacheSize nonces and reads at most maxBodySize bytes of a request
+func NewEd25519SignatureVerifier(maxClockSkew time.Duration, nonceCacheSize int, maxBodySize int64) *Ed25519SignatureVerifier {
+	return &Ed25519SignatureVerifier{
+		MaxClockSkew: maxClockSkew,
+		MaxBodySize:  maxBodySize,
+		nonces:       newNonceCache(nonceCacheSize),
+	}
+}
+
+//Signatures of the application ID alone are never accepted. Requests are verified with VerifyRequest
+func (verifier *Ed25519SignatureVerifier) VerifySignature(signature []byte, key []byte, aphID string) bool {
+	return false
+}
+
+//Verifies the signature of the canonical request with the public key, the timestamp and that the nonce was not used
+//before. The body is read and replaced so that the handler can still read it
+func (verifier *Ed25519SignatureVerifier) VerifyRequest(request *http.Request, signature []byte, key []byte) error {
+	if len(key) != ed25519.PublicKeySize {
+		return ErrInvalidRequestSignature
+	}
+	body, err := readBody(request, verifier.MaxBodySize)
+	if err != nil {
+		return err
+	}
+	query := request.URL.Query()
+	canonicalRequest := CanonicalRequest(Ed25519, request.Method, request.URL.Path, query, body)
+	if !ed25519.Verify(ed25519.PublicKey(key), []byte(canonicalRequest), signature) {
+		return ErrInvalidRequestSignature
+	}
+	return checkFreshness(query, verifier.MaxClockSkew, verifier.nonces)
+}
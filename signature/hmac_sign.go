@@ -0,0 +1,44 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"net/url"
+)
+
+//Name of the HMAC-SHA256 request signing scheme in server.signatureVerifier
+const HMACSHA256 = "hmac.sha256"
+
+//Adds the timestamp and a new nonce to the query and returns the HMAC-SHA256 of the canonical request. The signature
+//must be added to the query as the signature parameter
+func SignRequest(key []byte, method string, path string, query url.Values, body []byte) ([]byte, error) {
+	if err := addTimestampAndNonce(query); err != nil {
+		return nil, err
+	}
+	return requestHMAC(key, CanonicalRequest(HMACSHA256, method, path, query, body)), nil
+}
+
+func requestHMAC(key []byte, canonicalRequest string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(canonicalRequest))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"time"
+)
+
+//Verifies requests signed with SignRequest. Requests are accepted only within MaxClockSkew of their timestamp and
+//only once. The nonces of accepted requests are remembered until their timestamp is stale
+type HMACSignatureVerifier struct {
+	MaxClockSkew time.Duration
+	//Requests with a larger body are refused
+	MaxBodySize int64
+	nonces      *nonceCache
+}
+
+//Creates a verifier which remembers at most nonceCacheSize nonces and reads at most maxBodySize bytes of a request
+func NewHMACSignatureVerifier(maxClockSkew time.Duration, nonceCacheSize int, maxBodySize int64) *HMACSignatureVerifier {
+	return &HMACSignatureVerifier{
+		MaxClockSkew: maxClockSkew,
+		MaxBodySize:  maxBodySize,
+		nonces:       newNonceCache(nonceCacheSize),
+	}
+}
+
+//Signatures of the application ID alone are never accepted. Requests are verified with VerifyRequest
+func (verifier *HMACSignatureVerifier) VerifySignature(signature []byte, key []byte, aphID string) bool {
+	return false
+}
+
+//Verifies the signature of the canonical request, the timestamp and that the nonce was not used before. The body is
+//read and replaced so that the handler can still read it
+func (verifier *HMACSignatureVerifier) VerifyRequest(request *http.Request, signature []byte, key []byte) error {
+	body, err := readBody(request, verifier.MaxBodySize)
+	if err != nil {
+		return err
+	}
+	query := request.URL.Query()
+	if !hmac.Equal(signature, requestHMAC(key, CanonicalRequest(HMACSHA256, request.Method, request.URL.Path, query, body))) {
+		return ErrInvalidRequestSignature
+	}
+	return checkFreshness(query, verifier.MaxClockSkew, verifier.nonces)
+}
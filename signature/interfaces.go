@@ -19,7 +19,15 @@
 
 package signature
 
+import "net/http"
+
 //SignatureVerifier implementation used to  validate M-Pin requests
 type SignatureVerifier interface {
 	VerifySignature(signature []byte, key []byte, aphID string) bool
 }
+
+//Implemented by verifiers which sign the whole request rather than the application ID. The API server verifies
+//requests with VerifyRequest if the configured verifier implements it
+type RequestSignatureVerifier interface {
+	VerifyRequest(request *http.Request, signature []byte, key []byte) error
+}
@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+//Kinds of issued secrets which are signed by the D-TA
+const (
+	IssuanceServerSecret = "serverSecret"
+	IssuanceClientSecret = "clientSecret"
+	IssuanceTimePermit   = "timePermit"
+	//Algorithm of the D-TA signing key as published in /keys
+	IssuanceSigningAlgorithm = "Ed25519"
+	issuanceMessagePrefix    = "milagro-dta-issuance-v1"
+)
+
+//Returns the message signed by the D-TA when issuing a secret. clientID is empty for server secrets and date (days
+//since 1970-01-01) is 0 for server and client secrets. Every variable length field is length prefixed so that
+//different fields never produce the same message
+func IssuanceMessage(kind string, secret []byte, clientID string, date int, keyVersion int) []byte {
+	var message []byte
+	for _, field := range [][]byte{[]byte(issuanceMessagePrefix), []byte(kind), []byte(clientID), secret} {
+		message = appendUint32(message, uint32(len(field)))
+		message = append(message, field...)
+	}
+	message = appendUint32(message, uint32(date))
+	return appendUint32(message, uint32(keyVersion))
+}
+
+//Signs the issued secret
+func SignIssuance(key ed25519.PrivateKey, kind string, secret []byte, clientID string, date int, keyVersion int) []byte {
+	return ed25519.Sign(key, IssuanceMessage(kind, secret, clientID, date, keyVersion))
+}
+
+//Verifies the signature of an issued secret with the verification key published by the D-TA
+func VerifyIssuance(publicKey ed25519.PublicKey, signature []byte, kind string, secret []byte, clientID string, date int, keyVersion int) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(publicKey, IssuanceMessage(kind, secret, clientID, date, keyVersion), signature)
+}
+
+//Returns the ID of the verification key which is the hex of the first 8 bytes of its SHA-256
+func SigningKeyID(publicKey ed25519.PublicKey) string {
+	digest := sha256.Sum256(publicKey)
+	return hex.EncodeToString(digest[:8])
+}
+
+func appendUint32(message []byte, value uint32) []byte {
+	var encoded [4]byte
+	binary.BigEndian.PutUint32(encoded[:], value)
+	return append(message, encoded[:]...)
+}
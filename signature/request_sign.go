@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Query parameters of signed requests. The signature parameter holds the base64 url encoded signature
+const (
+	TimestampParam = "timestamp"
+	NonceParam     = "nonce"
+	SignatureParam = "signature"
+)
+
+//Returns the canonical form of the request which is signed with the scheme, such as HMACSHA256. Each line is
+//	<scheme>
+//	<method>
+//	<path>
+//	<query sorted by name and value, without the signature>
+//	<hex SHA-256 of the body>
+//	<timestamp in unix seconds>
+//	<nonce>
+func CanonicalRequest(scheme string, method string, path string, query url.Values, body []byte) string {
+	var params []string
+	for name, values := range query {
+		if name == SignatureParam {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(params)
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		scheme,
+		strings.ToUpper(method),
+		path,
+		strings.Join(params, "&"),
+		hex.EncodeToString(bodyHash[:]),
+		query.Get(TimestampParam),
+		query.Get(NonceParam),
+	}, "\n")
+}
+
+//Adds the current timestamp and a new nonce to the query of a request which is signed
+func addTimestampAndNonce(query url.Values) error {
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+	query.Set(TimestampParam, strconv.FormatInt(time.Now().Unix(), 10))
+	query.Set(NonceParam, nonce)
+	return nil
+}
+
+//Returns a random 128 bit nonce in hex
+func NewNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package signature
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidRequestSignature = errors.New("Invalid request signature")
+	ErrStaleRequest            = errors.New("Request timestamp is outside the allowed clock skew")
+	ErrReplayedRequest         = errors.New("Request nonce was already used")
+	ErrTooManyRequests         = errors.New("Too many signed requests within the allowed clock skew")
+	ErrRequestTooLarge         = errors.New("Request body is too large")
+)
+
+//Reads at most maxBodySize bytes of the body of the request and replaces it so that the handler can still read it
+func readBody(request *http.Request, maxBodySize int64) ([]byte, error) {
+	if request.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, request.Body, maxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, ErrRequestTooLarge
+		}
+		return nil, err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+//Checks that the timestamp of a signed request is within maxClockSkew of now and that its nonce was not used before
+func checkFreshness(query url.Values, maxClockSkew time.Duration, nonces *nonceCache) error {
+	timestamp, err := strconv.ParseInt(query.Get(TimestampParam), 10, 64)
+	if err != nil {
+		return ErrStaleRequest
+	}
+	now := time.Now()
+	requestTime := time.Unix(timestamp, 0)
+	if requestTime.Before(now.Add(-maxClockSkew)) || requestTime.After(now.Add(maxClockSkew)) {
+		return ErrStaleRequest
+	}
+	nonce := query.Get(NonceParam)
+	if nonce == "" {
+		return ErrReplayedRequest
+	}
+	return nonces.add(nonce, requestTime, now.Add(-maxClockSkew))
+}
+
+//Bounded set of the nonces of requests which are not stale yet. A nonce is kept until the timestamp of its request is
+//outside the allowed clock skew, then the request is refused as stale anyway
+type nonceCache struct {
+	lock    sync.Mutex
+	size    int
+	entries nonceHeap
+	seen    map[string]bool
+}
+
+type nonceEntry struct {
+	nonce     string
+	timestamp time.Time
+}
+
+//Nonces ordered by the timestamp of their request, oldest first
+type nonceHeap []nonceEntry
+
+func (h nonceHeap) Len() int            { return len(h) }
+func (h nonceHeap) Less(i, j int) bool  { return h[i].timestamp.Before(h[j].timestamp) }
+func (h nonceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nonceHeap) Push(x interface{}) { *h = append(*h, x.(nonceEntry)) }
+func (h *nonceHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func newNonceCache(size int) *nonceCache {
+	return &nonceCache{size: size, seen: make(map[string]bool)}
+}
+
+//Adds the nonce of a request sent at timestamp. Nonces of requests sent before staleBefore are no longer needed and
+//are dropped. Returns ErrReplayedRequest if the nonce was used, or ErrTooManyRequests if the cache is full of nonces
+//which are not stale
+func (cache *nonceCache) add(nonce string, timestamp time.Time, staleBefore time.Time) error {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for cache.entries.Len() > 0 && cache.entries[0].timestamp.Before(staleBefore) {
+		delete(cache.seen, heap.Pop(&cache.entries).(nonceEntry).nonce)
+	}
+	if cache.seen[nonce] {
+		return ErrReplayedRequest
+	}
+	if cache.entries.Len() >= cache.size {
+		return ErrTooManyRequests
+	}
+	heap.Push(&cache.entries, nonceEntry{nonce: nonce, timestamp: timestamp})
+	cache.seen[nonce] = true
+	return nil
+}
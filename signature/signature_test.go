@@ -20,8 +20,17 @@
 package signature
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestSignatureBasic(t *testing.T) {
@@ -39,3 +48,145 @@ func TestSignatureBasic(t *testing.T) {
 	}
 
 }
+
+func TestIssuanceSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	secret := []byte("time permit")
+	sig := SignIssuance(privateKey, IssuanceTimePermit, secret, "user@apache.org", 18000, 2)
+	if !VerifyIssuance(publicKey, sig, IssuanceTimePermit, secret, "user@apache.org", 18000, 2) {
+		t.Fatal("Issuance signature should be verified")
+	}
+	if VerifyIssuance(publicKey, sig, IssuanceTimePermit, secret, "user@apache.org", 18001, 2) {
+		t.Error("Signature of another date should not be verified")
+	}
+	if VerifyIssuance(publicKey, sig, IssuanceTimePermit, secret, "user@apache.org", 18000, 1) {
+		t.Error("Signature of another key version should not be verified")
+	}
+	if VerifyIssuance(publicKey, sig, IssuanceClientSecret, secret, "user@apache.org", 18000, 2) {
+		t.Error("Signature of another kind of secret should not be verified")
+	}
+	if bytes.Equal(IssuanceMessage(IssuanceClientSecret, []byte("b"), "a", 0, 1), IssuanceMessage(IssuanceClientSecret, []byte(""), "ab", 0, 1)) {
+		t.Error("Moving bytes between fields should change the message")
+	}
+	if len(SigningKeyID(publicKey)) != 16 {
+		t.Error("Signing key ID should be 8 bytes in hex")
+	}
+}
+
+func TestHMACRequestSignature(t *testing.T) {
+	key := []byte("FeXwEJUZsU0fgmpdqf2FiQ==")
+	verifier := NewHMACSignatureVerifier(time.Minute, 10, 1024)
+	body := []byte(`{"application_id":"appID0001"}`)
+
+	query := url.Values{"app_id": {"appID0001"}}
+	signature, err := SignRequest(key, http.MethodPost, "/rpa", query, body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	request := httptest.NewRequest(http.MethodPost, "/rpa?"+query.Encode(), bytes.NewReader(body))
+	if err := verifier.VerifyRequest(request, signature, key); err != nil {
+		t.Fatal("Failed to verify signed request: ", err)
+	}
+	if readBody, _ := ioutil.ReadAll(request.Body); !bytes.Equal(readBody, body) {
+		t.Error("Request body should be readable after verification")
+	}
+	request = httptest.NewRequest(http.MethodPost, "/rpa?"+query.Encode(), bytes.NewReader(body))
+	if err := verifier.VerifyRequest(request, signature, key); err != ErrReplayedRequest {
+		t.Error("Replayed request should be refused, got ", err)
+	}
+
+	tampered := []*http.Request{
+		httptest.NewRequest(http.MethodPut, "/rpa?"+query.Encode(), bytes.NewReader(body)),
+		httptest.NewRequest(http.MethodPost, "/rpa/appID0001?"+query.Encode(), bytes.NewReader(body)),
+		httptest.NewRequest(http.MethodPost, "/rpa?"+query.Encode()+"&client_id=other", bytes.NewReader(body)),
+		httptest.NewRequest(http.MethodPost, "/rpa?"+query.Encode(), bytes.NewReader([]byte("{}"))),
+	}
+	for _, request := range tampered {
+		if err := verifier.VerifyRequest(request, signature, key); err != ErrInvalidRequestSignature {
+			t.Errorf("Tampered request %v %v should be refused, got %v", request.Method, request.URL, err)
+		}
+	}
+}
+
+func TestHMACRequestSignature_Stale(t *testing.T) {
+	key := []byte("FeXwEJUZsU0fgmpdqf2FiQ==")
+	verifier := NewHMACSignatureVerifier(time.Minute, 10, 1024)
+
+	query := url.Values{
+		TimestampParam: {strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)},
+		NonceParam:     {"nonce"},
+	}
+	signature := requestHMAC(key, CanonicalRequest(HMACSHA256, http.MethodGet, "/serverSecret", query, nil))
+	request := httptest.NewRequest(http.MethodGet, "/serverSecret?"+query.Encode(), nil)
+	if err := verifier.VerifyRequest(request, signature, key); err != ErrStaleRequest {
+		t.Error("Stale request should be refused, got ", err)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	now := time.Now()
+	cache := newNonceCache(2)
+	if cache.add("a", now, now.Add(-time.Minute)) != nil || cache.add("b", now, now.Add(-time.Minute)) != nil {
+		t.Fatal("New nonces of the same second should be accepted")
+	}
+	if err := cache.add("a", now, now.Add(-time.Minute)); err != ErrReplayedRequest {
+		t.Error("Used nonce should be refused but got ", err)
+	}
+	if err := cache.add("c", now, now.Add(-time.Minute)); err != ErrTooManyRequests {
+		t.Error("New nonce should be refused while the cache is full of nonces which are not stale but got ", err)
+	}
+	if err := cache.add("c", now, now.Add(time.Millisecond)); err != nil || cache.entries.Len() != 1 {
+		t.Error("Stale nonces should be dropped to make room ", err)
+	}
+	if err := cache.add("a", now.Add(time.Second), now.Add(time.Millisecond)); err != nil {
+		t.Error("Nonce of a stale request can be used again once it is dropped ", err)
+	}
+}
+
+func TestReadBody_TooLarge(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "/rpa", bytes.NewReader(make([]byte, 11)))
+	if _, err := readBody(request, 10); err != ErrRequestTooLarge {
+		t.Error("Body larger than the limit should be refused but got ", err)
+	}
+	request = httptest.NewRequest(http.MethodPost, "/rpa", bytes.NewReader(make([]byte, 10)))
+	if body, err := readBody(request, 10); err != nil || len(body) != 10 {
+		t.Error("Body within the limit should be read ", err)
+	}
+}
+
+func TestEd25519RequestSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	verifier := NewEd25519SignatureVerifier(time.Minute, 10, 1024)
+
+	query := url.Values{"app_id": {"appID0001"}}
+	signature, err := SignRequestEd25519(privateKey, http.MethodGet, "/serverSecret", query, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if verifier.VerifySignature(signature, publicKey, "appID0001") {
+		t.Error("Signature of the application ID alone should not be accepted")
+	}
+	otherPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	request := httptest.NewRequest(http.MethodGet, "/serverSecret?"+query.Encode(), nil)
+	if err := verifier.VerifyRequest(request, signature, otherPublicKey); err != ErrInvalidRequestSignature {
+		t.Error("Signature should not verify with another public key, got ", err)
+	}
+	request = httptest.NewRequest(http.MethodGet, "/clientSecret?"+query.Encode(), nil)
+	if err := verifier.VerifyRequest(request, signature, publicKey); err != ErrInvalidRequestSignature {
+		t.Error("Signature should not verify another path, got ", err)
+	}
+	request = httptest.NewRequest(http.MethodGet, "/serverSecret?"+query.Encode(), nil)
+	if err := verifier.VerifyRequest(request, signature, publicKey); err != nil {
+		t.Fatal("Failed to verify signed request: ", err)
+	}
+	request = httptest.NewRequest(http.MethodGet, "/serverSecret?"+query.Encode(), nil)
+	if err := verifier.VerifyRequest(request, signature, publicKey); err != ErrReplayedRequest {
+		t.Error("Replayed request should be refused, got ", err)
+	}
+}
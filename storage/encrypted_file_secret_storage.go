@@ -0,0 +1,242 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+//Layout of $DTA_HOME/master.secret.enc
+//	offset  size  field
+//	0       4     magic "MSEC"
+//	4       2     format version (big endian)
+//	6       1     KDF (1 = scrypt)
+//	7       1     log2 of scrypt N
+//	8       4     scrypt r (big endian)
+//	12      4     scrypt p (big endian)
+//	16      16    salt
+//	32      12    AES-GCM nonce
+//	44            AES-256-GCM encrypted master secret records, authenticated together with the header
+//The records are the same as in the plain text file
+const (
+	encryptedSecretFileName = "master.secret.enc"
+	encryptedSecretMagic    = "MSEC"
+	encryptedSecretFormat   = 1
+	kdfScrypt               = 1
+	encryptedSecretSaltSize = 16
+	encryptedSecretNonce    = 12
+	encryptedSecretHeader   = 32 + encryptedSecretNonce
+	//scrypt parameters of new files. Existing files keep the parameters in their header
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+)
+
+//Returned when the passphrase does not decrypt the master secret file or the file is modified
+var ErrMasterSecretDecryption = errors.New("Master secret file cannot be decrypted. Wrong passphrase or corrupted file")
+
+//Master secret storage which keeps the secrets in $DTA_HOME/master.secret.enc encrypted with AES-256-GCM. The key
+//encryption key is derived from a passphrase with scrypt
+type EncryptedFileMasterSecretStorage struct {
+	//Returns the passphrase. It is called once, when the file is first read or written
+	Passphrase func() ([]byte, error)
+
+	lock       sync.Mutex
+	passphrase []byte
+	//The derived key is cached for the salt and KDF parameters of the file
+	kdfParams []byte
+	key       []byte
+}
+
+func (secretStorage *EncryptedFileMasterSecretStorage) GetSecret(ctx context.Context) (MasterSecret, error) {
+	masterSecrets, err := secretStorage.GetSecrets(ctx)
+	if err != nil {
+		return MasterSecret{}, err
+	}
+	return masterSecrets[len(masterSecrets)-1], nil
+}
+
+//Returns ErrMasterSecretDecryption if the passphrase is wrong
+func (secretStorage *EncryptedFileMasterSecretStorage) GetSecrets(ctx context.Context) ([]MasterSecret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, unavailable(err)
+	}
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	masterSecrets, err := secretStorage.load()
+	if os.IsNotExist(err) || (err == nil && len(masterSecrets) == 0) {
+		return nil, notFound("No master secret in " + encryptedSecretFileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return masterSecrets, nil
+}
+
+//Decrypts the existing versions, appends the secret as the new active version and writes the file again. It fails
+//rather than replacing the file when the existing file cannot be decrypted
+func (secretStorage *EncryptedFileMasterSecretStorage) SetSecret(ctx context.Context, version int, secret []byte) error {
+	if err := ctx.Err(); err != nil {
+		return unavailable(err)
+	}
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	fileLock, err := os.OpenFile(dtaHomeFile(encryptedSecretFileName+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return unavailable(err)
+	}
+	defer fileLock.Close()
+	if err := lockFile(fileLock); err != nil {
+		return unavailable(err)
+	}
+	masterSecrets, err := secretStorage.load()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if version != len(masterSecrets)+1 {
+		return conflict(fmt.Sprintf("Master secret version %d is not the next version", version))
+	}
+	masterSecret := MasterSecret{Version: version, ActivatedAt: time.Now()}
+	copy(masterSecret.Secret[:], secret)
+	return unavailable(secretStorage.save(append(masterSecrets, masterSecret)))
+}
+
+//Writes the master secrets of another storage, such as the plain text file, into a new encrypted file keeping their
+//versions and activation times. Fails if the encrypted file exists
+func (secretStorage *EncryptedFileMasterSecretStorage) Import(masterSecrets []MasterSecret) error {
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	if _, err := os.Stat(dtaHomeFile(encryptedSecretFileName)); err == nil {
+		return fmt.Errorf("%s already exists", encryptedSecretFileName)
+	}
+	if len(masterSecrets) == 0 {
+		return errors.New("No master secrets to import")
+	}
+	return secretStorage.save(masterSecrets)
+}
+
+func (secretStorage *EncryptedFileMasterSecretStorage) load() ([]MasterSecret, error) {
+	content, err := ioutil.ReadFile(dtaHomeFile(encryptedSecretFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, unavailable(err)
+	}
+	if len(content) < encryptedSecretHeader || string(content[:4]) != encryptedSecretMagic {
+		return nil, errors.New("Invalid master secret file header")
+	}
+	if format := binary.BigEndian.Uint16(content[4:6]); format != encryptedSecretFormat {
+		return nil, fmt.Errorf("Unsupported master secret file format %d", format)
+	}
+	aead, err := secretStorage.cipher(content[6:32])
+	if err != nil {
+		return nil, err
+	}
+	records, err := aead.Open(nil, content[32:encryptedSecretHeader], content[encryptedSecretHeader:], content[:encryptedSecretHeader])
+	if err != nil {
+		return nil, ErrMasterSecretDecryption
+	}
+	defer zeroBytes(records)
+	return decodeMasterSecretRecords(records)
+}
+
+//Encrypts the secrets with a fresh salt and nonce and replaces the file through a temporary file
+func (secretStorage *EncryptedFileMasterSecretStorage) save(masterSecrets []MasterSecret) error {
+	header := make([]byte, encryptedSecretHeader)
+	copy(header, encryptedSecretMagic)
+	binary.BigEndian.PutUint16(header[4:6], encryptedSecretFormat)
+	header[6] = kdfScrypt
+	header[7] = scryptLogN
+	binary.BigEndian.PutUint32(header[8:12], scryptR)
+	binary.BigEndian.PutUint32(header[12:16], scryptP)
+	if _, err := rand.Read(header[16:]); err != nil {
+		return err
+	}
+	aead, err := secretStorage.cipher(header[6:32])
+	if err != nil {
+		return err
+	}
+	var records []byte
+	for _, masterSecret := range masterSecrets {
+		records = append(records, encodeMasterSecretRecord(masterSecret.Secret[:], masterSecret.ActivatedAt)...)
+	}
+	content := aead.Seal(header, header[32:], records, header)
+	zeroBytes(records)
+
+	fileLocation := dtaHomeFile(encryptedSecretFileName)
+	tmpFile := fileLocation + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, fileLocation)
+}
+
+//Returns AES-256-GCM with the key derived from the passphrase for the KDF parameters and salt of the header
+func (secretStorage *EncryptedFileMasterSecretStorage) cipher(kdfParams []byte) (cipher.AEAD, error) {
+	if !bytes.Equal(kdfParams, secretStorage.kdfParams) {
+		if kdfParams[0] != kdfScrypt {
+			return nil, fmt.Errorf("Unsupported key derivation function %d", kdfParams[0])
+		}
+		if secretStorage.passphrase == nil {
+			if secretStorage.Passphrase == nil {
+				return nil, errors.New("No passphrase for the master secret file")
+			}
+			passphrase, err := secretStorage.Passphrase()
+			if err != nil {
+				return nil, err
+			}
+			if len(passphrase) == 0 {
+				return nil, errors.New("Empty passphrase for the master secret file")
+			}
+			secretStorage.passphrase = passphrase
+		}
+		logN := kdfParams[1]
+		r := int(binary.BigEndian.Uint32(kdfParams[2:6]))
+		p := int(binary.BigEndian.Uint32(kdfParams[6:10]))
+		if logN == 0 || logN > 30 {
+			return nil, fmt.Errorf("Invalid scrypt parameter N 2^%d", logN)
+		}
+		key, err := scrypt.Key(secretStorage.passphrase, kdfParams[10:], 1<<logN, r, p, 32)
+		if err != nil {
+			return nil, err
+		}
+		secretStorage.kdfParams = append([]byte(nil), kdfParams...)
+		secretStorage.key = key
+	}
+	block, err := aes.NewCipher(secretStorage.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, encryptedSecretNonce)
+}
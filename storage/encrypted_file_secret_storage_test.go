@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func staticPassphrase(passphrase string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return []byte(passphrase), nil
+	}
+}
+
+func TestEncryptedFileMasterSecretStorage_Basic(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+	fileLocation := dtaHomeFile(encryptedSecretFileName)
+	os.Remove(fileLocation)
+	defer os.Remove(fileLocation)
+
+	secret1 := []byte("dhkgfkdfhs49638543gfdkf38t1fgroe")
+	secret2 := []byte("kdfh3495hdg83h5gjd73h4jdk83hdy72")
+	ctx := context.Background()
+	masterSecretStorage := &EncryptedFileMasterSecretStorage{Passphrase: staticPassphrase("correct horse")}
+	if _, err := masterSecretStorage.GetSecret(ctx); Kind(err) != ErrNotFound {
+		t.Fatal("Secret should not exist")
+	}
+	if err := masterSecretStorage.SetSecret(ctx, 1, secret1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := masterSecretStorage.SetSecret(ctx, 2, secret2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := masterSecretStorage.SetSecret(ctx, 2, secret1); Kind(err) != ErrConflict {
+		t.Error("Storing an existing version should be a conflict but got ", err)
+	}
+
+	content, _ := ioutil.ReadFile(fileLocation)
+	if bytes.Contains(content, secret1) || bytes.Contains(content, secret2) {
+		t.Fatal("Master secrets should not be stored in plain text")
+	}
+
+	reloaded := &EncryptedFileMasterSecretStorage{Passphrase: staticPassphrase("correct horse")}
+	masterSecrets, err := reloaded.GetSecrets(ctx)
+	if err != nil || len(masterSecrets) != 2 {
+		t.Fatal("Expected 2 master secret versions but got ", len(masterSecrets))
+	}
+	if !bytes.Equal(masterSecrets[0].Secret[:], secret1) || !bytes.Equal(masterSecrets[1].Secret[:], secret2) {
+		t.Error("Master secrets should survive reloading")
+	}
+
+	wrongPassphrase := &EncryptedFileMasterSecretStorage{Passphrase: staticPassphrase("wrong")}
+	if _, err := wrongPassphrase.GetSecrets(ctx); err == nil {
+		t.Error("Wrong passphrase should not decrypt the master secrets")
+	}
+	if err := wrongPassphrase.SetSecret(ctx, 3, secret1); err != ErrMasterSecretDecryption {
+		t.Error("Wrong passphrase should never replace the master secret file but got ", err)
+	}
+
+	content[len(content)-1] ^= 1
+	ioutil.WriteFile(fileLocation, content, 0600)
+	if _, err := reloaded.GetSecrets(ctx); err == nil {
+		t.Error("Modified file should not be decrypted")
+	}
+}
+
+func TestEncryptedFileMasterSecretStorage_Import(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+	fileLocation := dtaHomeFile(encryptedSecretFileName)
+	os.Remove(fileLocation)
+	defer os.Remove(fileLocation)
+
+	ctx := context.Background()
+	plainTextStorage := &InMemorySecretStorage{}
+	plainTextStorage.SetSecret(ctx, 1, []byte("dhkgfkdfhs49638543gfdkf38t1fgroe"))
+	plainTextStorage.SetSecret(ctx, 2, []byte("kdfh3495hdg83h5gjd73h4jdk83hdy72"))
+	plainTextSecrets, _ := plainTextStorage.GetSecrets(ctx)
+
+	masterSecretStorage := &EncryptedFileMasterSecretStorage{Passphrase: staticPassphrase("correct horse")}
+	if err := masterSecretStorage.Import(plainTextSecrets); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := masterSecretStorage.Import(plainTextSecrets); err == nil {
+		t.Error("Import should not replace an existing encrypted file")
+	}
+	masterSecrets, err := masterSecretStorage.GetSecrets(ctx)
+	if err != nil || len(masterSecrets) != 2 {
+		t.Fatal("Expected 2 master secret versions but got ", len(masterSecrets))
+	}
+	for i := range masterSecrets {
+		if masterSecrets[i].Secret != plainTextSecrets[i].Secret || masterSecrets[i].ActivatedAt.Unix() != plainTextSecrets[i].ActivatedAt.Unix() {
+			t.Error("Imported master secret should keep its version and activation time ", masterSecrets[i].Version)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+)
+
+//Kinds of storage errors. Use Kind to get the kind of an error returned by a storage
+var (
+	ErrNotFound      = errors.New("Not found")
+	ErrAlreadyExists = errors.New("Already exists")
+	//The stored record changed since it was read
+	ErrConflict = errors.New("Concurrent modification")
+	//The backend failed, such as an I/O or database error
+	ErrUnavailable = errors.New("Storage unavailable")
+	//The input was refused before it was stored, such as an invalid public key
+	ErrInvalid = errors.New("Invalid input")
+)
+
+//Error of a storage with its kind and the underlying error
+type storageError struct {
+	kind error
+	err  error
+}
+
+func (storageErr *storageError) Error() string {
+	return storageErr.kind.Error() + ": " + storageErr.err.Error()
+}
+
+//Makes errors.Cause return the kind
+func (storageErr *storageError) Cause() error {
+	return storageErr.kind
+}
+
+func (storageErr *storageError) Unwrap() error {
+	return storageErr.kind
+}
+
+//Returns ErrNotFound, ErrAlreadyExists, ErrConflict, ErrUnavailable or ErrInvalid for an error returned by a storage.
+//Other errors are returned unchanged
+func Kind(err error) error {
+	return errors.Cause(err)
+}
+
+func unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	if kind := Kind(err); kind == ErrNotFound || kind == ErrAlreadyExists || kind == ErrConflict || kind == ErrUnavailable || kind == ErrInvalid {
+		return err
+	}
+	return &storageError{kind: ErrUnavailable, err: err}
+}
+
+func notFound(message string) error {
+	return &storageError{kind: ErrNotFound, err: errors.New(message)}
+}
+
+func alreadyExists(message string) error {
+	return &storageError{kind: ErrAlreadyExists, err: errors.New(message)}
+}
+
+func conflict(message string) error {
+	return &storageError{kind: ErrConflict, err: errors.New(message)}
+}
+
+func invalid(message string) error {
+	return &storageError{kind: ErrInvalid, err: errors.New(message)}
+}
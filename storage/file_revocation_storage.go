@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const revocationFileName = "revocations.json"
+
+//Revocation storage which keeps the revocations in memory and writes all of them to $DTA_HOME/revocations.json
+//on every change so that they survive restarts
+type FileRevocationStorage struct {
+	InMemoryRevocationStorage
+	fileLocation string
+}
+
+//Loads the revocations from the file if it exists
+func (revocationStorage *FileRevocationStorage) Init() error {
+	if err := revocationStorage.InMemoryRevocationStorage.Init(); err != nil {
+		return err
+	}
+	revocationStorage.fileLocation = dtaHomeFile(revocationFileName)
+	content, err := ioutil.ReadFile(revocationStorage.fileLocation)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var revocations []Revocation
+	if err := json.Unmarshal(content, &revocations); err != nil {
+		return err
+	}
+	revocationStorage.lock.Lock()
+	defer revocationStorage.lock.Unlock()
+	for _, revocation := range revocations {
+		revocationStorage.put(revocation)
+	}
+	return nil
+}
+
+func (revocationStorage *FileRevocationStorage) Revoke(revocation Revocation) error {
+	revocationStorage.lock.Lock()
+	defer revocationStorage.lock.Unlock()
+	previous, existed := revocationStorage.revocations[revocation.AppID][revocation.ClientID]
+	revocationStorage.put(revocation)
+	if err := revocationStorage.save(); err != nil {
+		//Keep memory consistent with the file
+		revocationStorage.remove(revocation.AppID, revocation.ClientID)
+		if existed {
+			revocationStorage.put(previous)
+		}
+		return err
+	}
+	return nil
+}
+
+func (revocationStorage *FileRevocationStorage) Unrevoke(appID string, clientID string) (bool, error) {
+	revocationStorage.lock.Lock()
+	defer revocationStorage.lock.Unlock()
+	previous, existed := revocationStorage.revocations[appID][clientID]
+	if !existed {
+		return false, nil
+	}
+	revocationStorage.remove(appID, clientID)
+	if err := revocationStorage.save(); err != nil {
+		revocationStorage.put(previous)
+		return false, err
+	}
+	return true, nil
+}
+
+//Writes all revocations to a temporary file and renames it so that a crash never leaves a partial file. Must be
+//called holding lock
+func (revocationStorage *FileRevocationStorage) save() error {
+	var revocations []Revocation
+	for _, appRevocations := range revocationStorage.revocations {
+		for _, revocation := range appRevocations {
+			revocations = append(revocations, revocation)
+		}
+	}
+	content, err := json.MarshalIndent(revocations, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := revocationStorage.fileLocation + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, revocationStorage.fileLocation)
+}
@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileRevocationStorage_Persistence(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+	defer os.Remove("/tmp/" + revocationFileName)
+
+	revocationStorage := &FileRevocationStorage{}
+	if err := revocationStorage.Init(); err != nil {
+		t.Fatal(err.Error())
+	}
+	revokedAt := time.Now().UTC().Truncate(time.Second)
+	revocationStorage.Revoke(Revocation{AppID: "app1", ClientID: "alice@apache.org", Reason: "lost device", RevokedAt: revokedAt})
+	revocationStorage.Revoke(Revocation{AppID: "app1", ClientID: "bob@apache.org", Reason: "left"})
+	revocationStorage.Revoke(Revocation{AppID: "app2", ClientID: "alice@apache.org", Reason: "compromised"})
+	if removed, err := revocationStorage.Unrevoke("app1", "bob@apache.org"); !removed || err != nil {
+		t.Fatal("Revocation should be removed ", err)
+	}
+	if removed, _ := revocationStorage.Unrevoke("app1", "bob@apache.org"); removed {
+		t.Error("Removing a missing revocation should return false")
+	}
+
+	reloaded := &FileRevocationStorage{}
+	if err := reloaded.Init(); err != nil {
+		t.Fatal(err.Error())
+	}
+	revocation, ok := reloaded.GetRevocation("app1", "alice@apache.org")
+	if !ok {
+		t.Fatal("Revocation should survive reloading")
+	}
+	if revocation.Reason != "lost device" || !revocation.RevokedAt.Equal(revokedAt) {
+		t.Error("Revocation details should survive reloading ", revocation)
+	}
+	if _, ok := reloaded.GetRevocation("app1", "bob@apache.org"); ok {
+		t.Error("Removed revocation should not be loaded")
+	}
+	if len(reloaded.GetRevocations("app1")) != 1 || len(reloaded.GetRevocations("app2")) != 1 {
+		t.Error("Revocations should be kept per application")
+	}
+}
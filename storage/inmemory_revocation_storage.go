@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+//In memory revocation storage. Revocations are lost when the D-TA restarts
+type InMemoryRevocationStorage struct {
+	lock sync.RWMutex
+	//Revocations by application ID and client ID
+	revocations map[string]map[string]Revocation
+}
+
+func (revocationStorage *InMemoryRevocationStorage) Init() error {
+	revocationStorage.lock.Lock()
+	defer revocationStorage.lock.Unlock()
+	revocationStorage.revocations = make(map[string]map[string]Revocation)
+	return nil
+}
+
+func (revocationStorage *InMemoryRevocationStorage) Revoke(revocation Revocation) error {
+	revocationStorage.lock.Lock()
+	defer revocationStorage.lock.Unlock()
+	revocationStorage.put(revocation)
+	return nil
+}
+
+func (revocationStorage *InMemoryRevocationStorage) Unrevoke(appID string, clientID string) (bool, error) {
+	revocationStorage.lock.Lock()
+	defer revocationStorage.lock.Unlock()
+	return revocationStorage.remove(appID, clientID), nil
+}
+
+func (revocationStorage *InMemoryRevocationStorage) GetRevocation(appID string, clientID string) (Revocation, bool) {
+	revocationStorage.lock.RLock()
+	defer revocationStorage.lock.RUnlock()
+	revocation, ok := revocationStorage.revocations[appID][clientID]
+	return revocation, ok
+}
+
+//Returns the revocations of the application ordered by client ID
+func (revocationStorage *InMemoryRevocationStorage) GetRevocations(appID string) []Revocation {
+	revocationStorage.lock.RLock()
+	defer revocationStorage.lock.RUnlock()
+	var revocations []Revocation
+	for _, revocation := range revocationStorage.revocations[appID] {
+		revocations = append(revocations, revocation)
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].ClientID < revocations[j].ClientID
+	})
+	return revocations
+}
+
+//Must be called holding lock
+func (revocationStorage *InMemoryRevocationStorage) put(revocation Revocation) {
+	appRevocations, ok := revocationStorage.revocations[revocation.AppID]
+	if !ok {
+		appRevocations = make(map[string]Revocation)
+		revocationStorage.revocations[revocation.AppID] = appRevocations
+	}
+	appRevocations[revocation.ClientID] = revocation
+}
+
+//Must be called holding lock
+func (revocationStorage *InMemoryRevocationStorage) remove(appID string, clientID string) bool {
+	if _, ok := revocationStorage.revocations[appID][clientID]; !ok {
+		return false
+	}
+	delete(revocationStorage.revocations[appID], clientID)
+	if len(revocationStorage.revocations[appID]) == 0 {
+		delete(revocationStorage.revocations, appID)
+	}
+	return true
+}
@@ -20,14 +20,17 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"log"
-
-	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
+	"sync"
+	"time"
 )
 
 //In memory master secret storage used in demo setups
 type InMemorySecretStorage struct {
-	masterSecret []byte
+	lock          sync.RWMutex
+	masterSecrets []MasterSecret
 }
 
 func (inMemorySecretStorage *InMemorySecretStorage) Init() error {
@@ -35,17 +38,34 @@ func (inMemorySecretStorage *InMemorySecretStorage) Init() error {
 	return nil
 }
 
-func (inMemorySecretStorage InMemorySecretStorage) GetSecret() ([amcl.MPIN_EGS]byte, bool) {
-	var rmasterSecret [amcl.MPIN_EGS]byte
-	if len(inMemorySecretStorage.masterSecret) > 0 {
-		copy(rmasterSecret[:], inMemorySecretStorage.masterSecret)
-		return rmasterSecret, true
+func (inMemorySecretStorage *InMemorySecretStorage) GetSecret(ctx context.Context) (MasterSecret, error) {
+	inMemorySecretStorage.lock.RLock()
+	defer inMemorySecretStorage.lock.RUnlock()
+	if len(inMemorySecretStorage.masterSecrets) == 0 {
+		return MasterSecret{}, notFound("No master secret")
 	}
-
-	return rmasterSecret, false
+	return inMemorySecretStorage.masterSecrets[len(inMemorySecretStorage.masterSecrets)-1], nil
 }
 
-func (inMemorySecretStorage InMemorySecretStorage) SetSecret(secret []byte) error {
-	inMemorySecretStorage.masterSecret = secret
+func (inMemorySecretStorage *InMemorySecretStorage) SetSecret(ctx context.Context, version int, secret []byte) error {
+	inMemorySecretStorage.lock.Lock()
+	defer inMemorySecretStorage.lock.Unlock()
+	if version != len(inMemorySecretStorage.masterSecrets)+1 {
+		return conflict(fmt.Sprintf("Master secret version %d is not the next version", version))
+	}
+	masterSecret := MasterSecret{Version: version, ActivatedAt: time.Now()}
+	copy(masterSecret.Secret[:], secret)
+	inMemorySecretStorage.masterSecrets = append(inMemorySecretStorage.masterSecrets, masterSecret)
 	return nil
 }
+
+func (inMemorySecretStorage *InMemorySecretStorage) GetSecrets(ctx context.Context) ([]MasterSecret, error) {
+	inMemorySecretStorage.lock.RLock()
+	defer inMemorySecretStorage.lock.RUnlock()
+	if len(inMemorySecretStorage.masterSecrets) == 0 {
+		return nil, notFound("No master secret")
+	}
+	masterSecrets := make([]MasterSecret, len(inMemorySecretStorage.masterSecrets))
+	copy(masterSecrets, inMemorySecretStorage.masterSecrets)
+	return masterSecrets, nil
+}
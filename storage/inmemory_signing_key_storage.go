@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"crypto/ed25519"
+	"sync"
+)
+
+//Signing key storage used in demo setups. A new key is generated on every start
+type InMemorySigningKeyStorage struct {
+	lock       sync.RWMutex
+	signingKey ed25519.PrivateKey
+}
+
+func (signingKeyStorage *InMemorySigningKeyStorage) Init() error {
+	return nil
+}
+
+func (signingKeyStorage *InMemorySigningKeyStorage) GetSigningKey() (ed25519.PrivateKey, error) {
+	signingKeyStorage.lock.RLock()
+	defer signingKeyStorage.lock.RUnlock()
+	if signingKeyStorage.signingKey == nil {
+		return nil, notFound("No signing key")
+	}
+	return signingKeyStorage.signingKey, nil
+}
+
+func (signingKeyStorage *InMemorySigningKeyStorage) SetSigningKey(key ed25519.PrivateKey) error {
+	signingKeyStorage.lock.Lock()
+	defer signingKeyStorage.lock.Unlock()
+	signingKeyStorage.signingKey = key
+	return nil
+}
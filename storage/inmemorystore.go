@@ -20,48 +20,128 @@ package storage
  */
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 )
 
-var RPAMap map[string]RelyingPartyApplication
-
 //In memory RPA storage for demo purpose
 type InMemoryRPAManager struct {
+	lock   sync.RWMutex
+	rpaMap map[string]RelyingPartyApplication
 }
 
-func (rpaManager InMemoryRPAManager) Init() {
-	RPAMap = make(map[string]RelyingPartyApplication)
+func (rpaManager *InMemoryRPAManager) Init(ctx context.Context) error {
+	rpaManager.lock.Lock()
+	defer rpaManager.lock.Unlock()
+	rpaManager.rpaMap = make(map[string]RelyingPartyApplication)
+	return nil
 }
 
-func (rpaManager InMemoryRPAManager) RegisterRPA(relyingPartyApplication RelyingPartyApplication) {
-	c := 16
-	b := make([]byte, c)
-	_, err := rand.Read(b)
+func (rpaManager *InMemoryRPAManager) RegisterRPA(ctx context.Context, relyingPartyApplication RelyingPartyApplication) (RelyingPartyApplication, error) {
+	createdAt := time.Now().UTC()
+	key, err := firstRPAKey(&relyingPartyApplication, createdAt)
 	if err != nil {
-		fmt.Println("error:", err)
+		return RelyingPartyApplication{}, unavailable(err)
+	}
+	relyingPartyApplication.Application_KEY = key.Key
+	relyingPartyApplication.Keys = []RPAKey{key}
+	relyingPartyApplication.CreatedAt = createdAt
+	relyingPartyApplication.UpdatedAt = relyingPartyApplication.CreatedAt
+	relyingPartyApplication.Revision = 1
+	log.Println("Generating appkey for ", relyingPartyApplication.Application_ID)
+	rpaManager.lock.Lock()
+	defer rpaManager.lock.Unlock()
+	if _, ok := rpaManager.rpaMap[relyingPartyApplication.Application_ID]; ok {
+		return RelyingPartyApplication{}, alreadyExists("RPA " + relyingPartyApplication.Application_ID)
+	}
+	rpaManager.rpaMap[relyingPartyApplication.Application_ID] = relyingPartyApplication
+	return relyingPartyApplication, nil
+}
 
+func (rpaManager *InMemoryRPAManager) UpdateRPA(ctx context.Context, relyingPartyApplication RelyingPartyApplication) (RelyingPartyApplication, error) {
+	rpaManager.lock.Lock()
+	defer rpaManager.lock.Unlock()
+	stored, ok := rpaManager.rpaMap[relyingPartyApplication.Application_ID]
+	if !ok {
+		return RelyingPartyApplication{}, notFound("RPA " + relyingPartyApplication.Application_ID)
+	}
+	if stored.Revision != relyingPartyApplication.Revision {
+		return RelyingPartyApplication{}, conflict(fmt.Sprintf("RPA %s is at revision %d", stored.Application_ID, stored.Revision))
 	}
-	relyingPartyApplication.Application_KEY = b
-	fmt.Println("Generating appkey for ", relyingPartyApplication.Application_ID)
-	RPAMap[relyingPartyApplication.Application_ID] = relyingPartyApplication
+	stored.update(relyingPartyApplication)
+	stored.UpdatedAt = time.Now().UTC()
+	stored.Revision++
+	rpaManager.rpaMap[stored.Application_ID] = stored
+	return stored.withCurrentKey(stored.UpdatedAt), nil
 }
-func (rpaManager InMemoryRPAManager) GetAllRPAs() []RelyingPartyApplication {
+
+func (rpaManager *InMemoryRPAManager) GetAllRPAs(ctx context.Context) ([]RelyingPartyApplication, error) {
 	var apps []RelyingPartyApplication
+	rpaManager.lock.RLock()
+	defer rpaManager.lock.RUnlock()
+	now := time.Now().UTC()
+	for _, app := range rpaManager.rpaMap {
+		apps = append(apps, app.withCurrentKey(now))
+	}
+	return apps, nil
+}
 
-	fmt.Println("Going to return ", len(RPAMap), " apps ")
-	for key, app := range RPAMap {
+func (rpaManager *InMemoryRPAManager) Ping(ctx context.Context) error {
+	return nil
+}
 
-		apps = append(apps, app)
-		fmt.Println("Adding app..", app, ":", key)
+func (rpaManager *InMemoryRPAManager) CountRPAs(ctx context.Context) (int, error) {
+	rpaManager.lock.RLock()
+	defer rpaManager.lock.RUnlock()
+	return len(rpaManager.rpaMap), nil
+}
 
+func (rpaManager *InMemoryRPAManager) GetRPA(ctx context.Context, rpaID string) (RelyingPartyApplication, error) {
+	rpaManager.lock.RLock()
+	defer rpaManager.lock.RUnlock()
+	app, ok := rpaManager.rpaMap[rpaID]
+	if !ok {
+		return RelyingPartyApplication{}, notFound("RPA " + rpaID)
 	}
-	return apps
+	return app.withCurrentKey(time.Now().UTC()), nil
+}
+
+//Generates a random 16 byte application key
+func generateAppKey() ([]byte, error) {
+	appKey := make([]byte, 16)
+	_, err := rand.Read(appKey)
+	return appKey, err
 }
-func (rpaManager InMemoryRPAManager) GetRPA(rpaID string) RelyingPartyApplication {
-	return RPAMap[rpaID]
+
+func (rpaManager *InMemoryRPAManager) RotateRPAKey(ctx context.Context, rpaID string, publicKey []byte, notBefore time.Time, gracePeriod time.Duration) (RelyingPartyApplication, error) {
+	rpaManager.lock.Lock()
+	defer rpaManager.lock.Unlock()
+	stored, ok := rpaManager.rpaMap[rpaID]
+	if !ok {
+		return RelyingPartyApplication{}, notFound("RPA " + rpaID)
+	}
+	now := time.Now().UTC()
+	keys, err := rotateRPAKeys(stored.Keys, stored.KeyType, publicKey, notBefore, gracePeriod, now)
+	if err != nil {
+		return RelyingPartyApplication{}, unavailable(err)
+	}
+	stored.Keys = keys
+	stored.UpdatedAt = now
+	stored.Revision++
+	rpaManager.rpaMap[rpaID] = stored
+	return stored.withCurrentKey(now), nil
 }
 
-func (rpaManager InMemoryRPAManager) DeleteRPA(rpaID string) {
-	delete(RPAMap, rpaID)
+func (rpaManager *InMemoryRPAManager) DeleteRPA(ctx context.Context, rpaID string) error {
+	rpaManager.lock.Lock()
+	defer rpaManager.lock.Unlock()
+	if _, ok := rpaManager.rpaMap[rpaID]; !ok {
+		return notFound("RPA " + rpaID)
+	}
+	delete(rpaManager.rpaMap, rpaID)
+	return nil
 }
@@ -20,25 +20,163 @@
 package storage
 
 import (
+	"context"
+	"crypto/ed25519"
+	"time"
+
 	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
 )
 
-//Interface for Master secret storage
+//Interface for Master secret storage. The storage keeps every version of the master secret and the latest one is
+//the active secret. Errors are of the kinds in errors.go
 type MasterSecretStorage interface {
-	GetSecret() ([amcl.MPIN_EGS]byte, bool)
-	SetSecret(secret []byte) error
+	//Returns the active master secret or ErrNotFound if there is no secret yet
+	GetSecret(ctx context.Context) (MasterSecret, error)
+	//Stores the secret as the given version and makes it the active one. The version must be one more than the
+	//latest stored version, otherwise ErrConflict is returned. This keeps two D-TAs sharing a storage from rotating
+	//the master secret at the same time
+	SetSecret(ctx context.Context, version int, secret []byte) error
+	//Returns all the versions of the master secret, oldest first, or ErrNotFound if there is no secret yet
+	GetSecrets(ctx context.Context) ([]MasterSecret, error)
+}
+
+//Implemented by master secret storages which keep the secrets wrapped outside of the process, such as PKCS#11. The
+//DTA does not keep the secrets of such storages in memory and unwraps the version for every issuance instead
+type MasterSecretUnwrapper interface {
+	UnwrapSecret(version int) ([amcl.MPIN_EGS]byte, error)
 }
 
-//RPA storage interface to store  relying party application ID and KEY
+//A version of the master secret. Versions start from 1
+type MasterSecret struct {
+	Version     int
+	Secret      [amcl.MPIN_EGS]byte
+	ActivatedAt time.Time
+}
+
+//Storage of the long-term key which signs issued secrets so that clients can verify which D-TA issued them.
+//GetSigningKey returns an error of kind ErrNotFound only if no key was stored yet
+type SigningKeyStorage interface {
+	Init() error
+	GetSigningKey() (ed25519.PrivateKey, error)
+	SetSigningKey(key ed25519.PrivateKey) error
+}
+
+//RPA storage interface to store  relying party application ID and KEY. Errors are of the kinds in errors.go
 type RPAStorage interface {
-	RegisterRPA(relyingPartyApplication RelyingPartyApplication)
-	GetAllRPAs() []RelyingPartyApplication
-	GetRPA(rpaID string) RelyingPartyApplication
-	Init()
-	DeleteRPA(appID string)
+	Init(ctx context.Context) error
+	//Checks that the storage is reachable without reading any application. Returns ErrUnavailable if it is not
+	Ping(ctx context.Context) error
+	//Generates the application key, or uses Application_KEY as the public key of applications with Ed25519 keys, and
+	//stores the application with revision 1. Returns ErrAlreadyExists if the application is registered and ErrInvalid
+	//if the key type or public key is invalid
+	RegisterRPA(ctx context.Context, relyingPartyApplication RelyingPartyApplication) (RelyingPartyApplication, error)
+	//Replaces the display name, owner contact, capabilities, certificate fingerprints and metadata of the application if its Revision is the
+	//stored revision and returns it with the next revision. Returns ErrConflict if the application changed since it
+	//was read
+	UpdateRPA(ctx context.Context, relyingPartyApplication RelyingPartyApplication) (RelyingPartyApplication, error)
+	GetAllRPAs(ctx context.Context) ([]RelyingPartyApplication, error)
+	//Returns the number of registered applications without reading them
+	CountRPAs(ctx context.Context) (int, error)
+	//Returns ErrNotFound if the application is not registered
+	GetRPA(ctx context.Context, rpaID string) (RelyingPartyApplication, error)
+	//Returns ErrNotFound if the application is not registered
+	DeleteRPA(ctx context.Context, appID string) error
+	//Adds a new key to the application which is valid from notBefore, or from now if it is earlier, and is its
+	//Application_KEY from then on. The new key is generated, or is publicKey for applications with Ed25519 keys. The previous keys
+	//still verify requests during the grace period. Returns ErrNotFound if the application is not registered and
+	//ErrInvalid if the public key is invalid
+	RotateRPAKey(ctx context.Context, appID string, publicKey []byte, notBefore time.Time, gracePeriod time.Duration) (RelyingPartyApplication, error)
+}
+
+//Storage of client identities which must not get client secrets or time permits from a relying party application
+type RevocationStorage interface {
+	Init() error
+	//Revokes the client ID for the application. Revoking again updates the reason and time
+	Revoke(revocation Revocation) error
+	//Removes the revocation. Returns false if the client ID was not revoked
+	Unrevoke(appID string, clientID string) (bool, error)
+	GetRevocation(appID string, clientID string) (Revocation, bool)
+	GetRevocations(appID string) []Revocation
+}
+
+type Revocation struct {
+	AppID     string
+	ClientID  string
+	Reason    string
+	RevokedAt time.Time
+}
+
+//Overwrites secret material which is no longer needed
+func zeroBytes(secret []byte) {
+	for i := range secret {
+		secret[i] = 0
+	}
+}
+
+//Operations which can be allowed for a relying party application
+const (
+	OperationServerSecret = "serverSecret"
+	OperationClientSecret = "clientSecret"
+	OperationTimePermit   = "timePermit"
+)
+
+//Returns the operations which can be allowed for a relying party application
+func Operations() []string {
+	return []string{OperationServerSecret, OperationClientSecret, OperationTimePermit}
 }
 
 type RelyingPartyApplication struct {
-	Application_ID  string
+	Application_ID string
+	//The newest key which is valid when the application is read, which the application should sign its requests with
 	Application_KEY []byte
+	//Every key which is not expired, oldest first
+	Keys []RPAKey
+	//KeyTypeShared or KeyTypeEd25519. It can not be changed after the application is registered
+	KeyType      string
+	DisplayName  string
+	OwnerContact string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	//Disabled applications get no secrets or time permits
+	Disabled bool
+	//Operations the application may request. Every operation is allowed if it is empty
+	AllowedOperations []string
+	//Maximum number of days of one time permits request. The server maximum is used if it is 0
+	MaxPermitRange int
+	//Domains of the client identities of the application. Every domain is allowed if it is empty
+	AllowedIdentityDomains []string
+	//Hex SHA-256 fingerprints of the client certificates which authenticate the application over mutual TLS
+	CertificateFingerprints []string
+	//Free form details of the application given when it is registered
+	Metadata map[string]string
+	//Incremented on every change for optimistic concurrency
+	Revision int64
+}
+
+//Returns true if the application is enabled and may request the operation
+func (rpa RelyingPartyApplication) Allows(operation string) bool {
+	if rpa.Disabled {
+		return false
+	}
+	if len(rpa.AllowedOperations) == 0 {
+		return true
+	}
+	for _, allowed := range rpa.AllowedOperations {
+		if allowed == operation {
+			return true
+		}
+	}
+	return false
+}
+
+//Copies the attributes which UpdateRPA replaces
+func (rpa *RelyingPartyApplication) update(update RelyingPartyApplication) {
+	rpa.DisplayName = update.DisplayName
+	rpa.OwnerContact = update.OwnerContact
+	rpa.Disabled = update.Disabled
+	rpa.AllowedOperations = update.AllowedOperations
+	rpa.MaxPermitRange = update.MaxPermitRange
+	rpa.AllowedIdentityDomains = update.AllowedIdentityDomains
+	rpa.CertificateFingerprints = update.CertificateFingerprints
+	rpa.Metadata = update.Metadata
 }
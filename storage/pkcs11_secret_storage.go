@@ -0,0 +1,375 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+const (
+	pkcs11Application = "milagro-dta"
+	pkcs11IVSize      = 12
+	pkcs11TagBits     = 128
+)
+
+//Master secret storage backed by a PKCS#11 token. An AES-256 key labelled KeyLabel is generated inside the token
+//and never leaves it. Each master secret version is stored on the token as a data object labelled KeyLabel/v<version>
+//which holds the version encrypted with that key using AES-GCM. The DTA unwraps a version through UnwrapSecret for
+//every issuance instead of keeping it in memory. Build with the pkcs11 tag
+type PKCS11MasterSecretStorage struct {
+	//Path of the PKCS#11 library such as /usr/lib/softhsm/libsofthsm2.so
+	Module     string
+	TokenLabel string
+	KeyLabel   string
+	PIN        string
+
+	//A PKCS#11 session runs one operation at a time, so every use must hold lock
+	lock    sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+}
+
+//Opens a session on the token and finds or generates the key encryption key. It is called by the other methods
+func (secretStorage *PKCS11MasterSecretStorage) Init() error {
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	return secretStorage.init()
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) init() error {
+	if secretStorage.ctx != nil {
+		return nil
+	}
+	ctx := pkcs11.New(secretStorage.Module)
+	if ctx == nil {
+		return fmt.Errorf("Cannot load PKCS#11 module %s", secretStorage.Module)
+	}
+	if err := ctx.Initialize(); err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		ctx.Destroy()
+		return errors.Wrap(err, "Error in initialising the PKCS#11 module")
+	}
+	session, err := openTokenSession(ctx, secretStorage.TokenLabel, secretStorage.PIN)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return err
+	}
+	secretStorage.ctx = ctx
+	secretStorage.session = session
+	key, err := secretStorage.findOrGenerateKey()
+	if err != nil {
+		secretStorage.close()
+		return err
+	}
+	secretStorage.key = key
+	return nil
+}
+
+//Closes the session and unloads the module
+func (secretStorage *PKCS11MasterSecretStorage) Close() {
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	secretStorage.close()
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) close() {
+	if secretStorage.ctx == nil {
+		return
+	}
+	secretStorage.ctx.Logout(secretStorage.session)
+	secretStorage.ctx.CloseSession(secretStorage.session)
+	secretStorage.ctx.Finalize()
+	secretStorage.ctx.Destroy()
+	secretStorage.ctx = nil
+}
+
+func openTokenSession(ctx *pkcs11.Ctx, tokenLabel string, pin string) (pkcs11.SessionHandle, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error in listing PKCS#11 slots")
+	}
+	for _, slot := range slots {
+		tokenInfo, err := ctx.GetTokenInfo(slot)
+		if err != nil || tokenInfo.Label != tokenLabel {
+			continue
+		}
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return 0, errors.Wrap(err, "Error in opening PKCS#11 session")
+		}
+		if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+			ctx.CloseSession(session)
+			return 0, errors.Wrap(err, "Error in logging in to the PKCS#11 token")
+		}
+		return session, nil
+	}
+	return 0, fmt.Errorf("PKCS#11 token %s not found", tokenLabel)
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) findOrGenerateKey() (pkcs11.ObjectHandle, error) {
+	keys, err := secretStorage.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, secretStorage.KeyLabel),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) > 0 {
+		return keys[0], nil
+	}
+	log.Println("Generating PKCS#11 key ", secretStorage.KeyLabel)
+	key, err := secretStorage.ctx.GenerateKey(secretStorage.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, secretStorage.KeyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		})
+	if err != nil {
+		return 0, errors.Wrap(err, "Error in generating the PKCS#11 key")
+	}
+	return key, nil
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) findObjects(template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := secretStorage.ctx.FindObjectsInit(secretStorage.session, template); err != nil {
+		return nil, err
+	}
+	defer secretStorage.ctx.FindObjectsFinal(secretStorage.session)
+	var handles []pkcs11.ObjectHandle
+	for {
+		found, _, err := secretStorage.ctx.FindObjects(secretStorage.session, 16)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return handles, nil
+		}
+		handles = append(handles, found...)
+	}
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) versionLabel(version int) string {
+	return secretStorage.KeyLabel + "/v" + strconv.Itoa(version)
+}
+
+//A wrapped master secret version stored on the token
+type pkcs11WrappedSecret struct {
+	version int
+	label   string
+	value   []byte
+}
+
+//Returns the data objects of the master secret versions ordered by version
+func (secretStorage *PKCS11MasterSecretStorage) wrappedSecrets() ([]pkcs11WrappedSecret, error) {
+	objects, err := secretStorage.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_APPLICATION, pkcs11Application),
+	})
+	if err != nil {
+		return nil, err
+	}
+	prefix := secretStorage.KeyLabel + "/v"
+	var wrappedSecrets []pkcs11WrappedSecret
+	for _, object := range objects {
+		attributes, err := secretStorage.ctx.GetAttributeValue(secretStorage.session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		label := string(attributes[0].Value)
+		if !strings.HasPrefix(label, prefix) {
+			continue
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(label, prefix))
+		if err != nil {
+			continue
+		}
+		wrappedSecrets = append(wrappedSecrets, pkcs11WrappedSecret{version: version, label: label, value: attributes[1].Value})
+	}
+	sort.Slice(wrappedSecrets, func(i, j int) bool {
+		return wrappedSecrets[i].version < wrappedSecrets[j].version
+	})
+	for i, wrappedSecret := range wrappedSecrets {
+		if wrappedSecret.version != i+1 {
+			return nil, fmt.Errorf("Master secret version %d is missing on the PKCS#11 token", i+1)
+		}
+	}
+	return wrappedSecrets, nil
+}
+
+//Decrypts the wrapped version inside the token. The label is authenticated so that a version cannot be swapped
+func (secretStorage *PKCS11MasterSecretStorage) unwrap(wrappedSecret pkcs11WrappedSecret) (MasterSecret, error) {
+	masterSecret := MasterSecret{Version: wrappedSecret.version}
+	if len(wrappedSecret.value) <= pkcs11IVSize {
+		return masterSecret, fmt.Errorf("Invalid wrapped master secret %s", wrappedSecret.label)
+	}
+	params := pkcs11.NewGCMParams(wrappedSecret.value[:pkcs11IVSize], []byte(wrappedSecret.label), pkcs11TagBits)
+	defer params.Free()
+	if err := secretStorage.ctx.DecryptInit(secretStorage.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, secretStorage.key); err != nil {
+		return masterSecret, err
+	}
+	record, err := secretStorage.ctx.Decrypt(secretStorage.session, wrappedSecret.value[pkcs11IVSize:])
+	if err != nil {
+		return masterSecret, errors.Wrapf(err, "Error in unwrapping master secret %s", wrappedSecret.label)
+	}
+	defer zeroBytes(record)
+	decoded, err := decodeMasterSecretRecords(record)
+	if err != nil || len(decoded) != 1 {
+		return masterSecret, fmt.Errorf("Invalid wrapped master secret %s", wrappedSecret.label)
+	}
+	masterSecret.Secret = decoded[0].Secret
+	masterSecret.ActivatedAt = decoded[0].ActivatedAt
+	return masterSecret, nil
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) GetSecret(ctx context.Context) (MasterSecret, error) {
+	masterSecrets, err := secretStorage.GetSecrets(ctx)
+	if err != nil {
+		return MasterSecret{}, err
+	}
+	return masterSecrets[len(masterSecrets)-1], nil
+}
+
+//Returns every version including the unwrapped secrets. It is used to load the versions and for backups
+func (secretStorage *PKCS11MasterSecretStorage) GetSecrets(ctx context.Context) ([]MasterSecret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, unavailable(err)
+	}
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	if err := secretStorage.init(); err != nil {
+		return nil, unavailable(err)
+	}
+	wrappedSecrets, err := secretStorage.wrappedSecrets()
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	if len(wrappedSecrets) == 0 {
+		return nil, notFound("No master secret on the PKCS#11 token")
+	}
+	var masterSecrets []MasterSecret
+	for _, wrappedSecret := range wrappedSecrets {
+		masterSecret, err := secretStorage.unwrap(wrappedSecret)
+		if err != nil {
+			return nil, unavailable(err)
+		}
+		masterSecrets = append(masterSecrets, masterSecret)
+	}
+	return masterSecrets, nil
+}
+
+//Encrypts the secret inside the token and stores it as the given version. The token has no transactions, so
+//processes sharing a token must not rotate at the same time
+func (secretStorage *PKCS11MasterSecretStorage) SetSecret(ctx context.Context, version int, secret []byte) error {
+	if err := ctx.Err(); err != nil {
+		return unavailable(err)
+	}
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	if err := secretStorage.init(); err != nil {
+		return unavailable(err)
+	}
+	wrappedSecrets, err := secretStorage.wrappedSecrets()
+	if err != nil {
+		return unavailable(err)
+	}
+	if version != len(wrappedSecrets)+1 {
+		return conflict(fmt.Sprintf("Master secret version %d is not the next version", version))
+	}
+	label := secretStorage.versionLabel(version)
+	iv, err := secretStorage.ctx.GenerateRandom(secretStorage.session, pkcs11IVSize)
+	if err != nil {
+		return unavailable(err)
+	}
+	params := pkcs11.NewGCMParams(iv, []byte(label), pkcs11TagBits)
+	defer params.Free()
+	if err := secretStorage.ctx.EncryptInit(secretStorage.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, secretStorage.key); err != nil {
+		return unavailable(err)
+	}
+	record := encodeMasterSecretRecord(secret, time.Now())
+	defer zeroBytes(record)
+	wrapped, err := secretStorage.ctx.Encrypt(secretStorage.session, record)
+	if err != nil {
+		return unavailable(errors.Wrap(err, "Error in wrapping the master secret"))
+	}
+	_, err = secretStorage.ctx.CreateObject(secretStorage.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_APPLICATION, pkcs11Application),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, append(iv, wrapped...)),
+	})
+	return unavailable(err)
+}
+
+//Unwraps a single version for one issuance. The caller should clear the returned secret after use
+func (secretStorage *PKCS11MasterSecretStorage) UnwrapSecret(version int) ([amcl.MPIN_EGS]byte, error) {
+	secretStorage.lock.Lock()
+	defer secretStorage.lock.Unlock()
+	if err := secretStorage.init(); err != nil {
+		return [amcl.MPIN_EGS]byte{}, err
+	}
+	label := secretStorage.versionLabel(version)
+	objects, err := secretStorage.findObjects([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_DATA),
+		pkcs11.NewAttribute(pkcs11.CKA_APPLICATION, pkcs11Application),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return [amcl.MPIN_EGS]byte{}, err
+	}
+	if len(objects) != 1 {
+		return [amcl.MPIN_EGS]byte{}, fmt.Errorf("Master secret %s not found on the PKCS#11 token", label)
+	}
+	attributes, err := secretStorage.ctx.GetAttributeValue(secretStorage.session, objects[0], []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return [amcl.MPIN_EGS]byte{}, err
+	}
+	masterSecret, err := secretStorage.unwrap(pkcs11WrappedSecret{version: version, label: label, value: attributes[0].Value})
+	return masterSecret.Secret, err
+}
@@ -0,0 +1,63 @@
+//go:build !pkcs11
+// +build !pkcs11
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
+)
+
+var errPKCS11Disabled = errors.New("PKCS#11 master secret storage needs a build with the pkcs11 tag")
+
+//Placeholder of the PKCS#11 master secret storage when the D-TA is built without the pkcs11 tag. Every method fails
+type PKCS11MasterSecretStorage struct {
+	Module     string
+	TokenLabel string
+	KeyLabel   string
+	PIN        string
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) Init() error {
+	return errPKCS11Disabled
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) Close() {
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) GetSecret(ctx context.Context) (MasterSecret, error) {
+	return MasterSecret{}, unavailable(errPKCS11Disabled)
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) GetSecrets(ctx context.Context) ([]MasterSecret, error) {
+	return nil, unavailable(errPKCS11Disabled)
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) SetSecret(ctx context.Context, version int, secret []byte) error {
+	return unavailable(errPKCS11Disabled)
+}
+
+func (secretStorage *PKCS11MasterSecretStorage) UnwrapSecret(version int) ([amcl.MPIN_EGS]byte, error) {
+	return [amcl.MPIN_EGS]byte{}, errPKCS11Disabled
+}
@@ -0,0 +1,84 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//Runs against a SoftHSM2 token such as the one created by
+//	softhsm2-util --init-token --free --label dta-test --so-pin 1234 --pin 1234
+//	DTA_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so DTA_PKCS11_TOKEN=dta-test DTA_PKCS11_PIN=1234 go test -tags pkcs11 ./storage
+func newTestPKCS11Storage(t *testing.T, keyLabel string) *PKCS11MasterSecretStorage {
+	module := os.Getenv("DTA_PKCS11_MODULE")
+	token := os.Getenv("DTA_PKCS11_TOKEN")
+	if module == "" || token == "" {
+		t.Skip("DTA_PKCS11_MODULE and DTA_PKCS11_TOKEN are not set")
+	}
+	secretStorage := &PKCS11MasterSecretStorage{Module: module, TokenLabel: token, KeyLabel: keyLabel, PIN: os.Getenv("DTA_PKCS11_PIN")}
+	if err := secretStorage.Init(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return secretStorage
+}
+
+func TestPKCS11MasterSecretStorage_Versions(t *testing.T) {
+	keyLabel := "dta-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	secretStorage := newTestPKCS11Storage(t, keyLabel)
+	defer secretStorage.Close()
+
+	ctx := context.Background()
+	if _, err := secretStorage.GetSecrets(ctx); Kind(err) != ErrNotFound {
+		t.Fatal("Secret should not exist for a new key label")
+	}
+	secret1 := []byte("dhkgfkdfhs49638543gfdkf38t1fgroe")
+	secret2 := []byte("kdfh3495hdg83h5gjd73h4jdk83hdy72")
+	if err := secretStorage.SetSecret(ctx, 1, secret1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := secretStorage.SetSecret(ctx, 2, secret2); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reloaded := newTestPKCS11Storage(t, keyLabel)
+	defer reloaded.Close()
+	masterSecrets, err := reloaded.GetSecrets(ctx)
+	if err != nil || len(masterSecrets) != 2 {
+		t.Fatal("Expected 2 master secret versions but got ", len(masterSecrets))
+	}
+	if !bytes.Equal(masterSecrets[0].Secret[:], secret1) || !bytes.Equal(masterSecrets[1].Secret[:], secret2) {
+		t.Error("Master secrets should survive reopening the token")
+	}
+	unwrapped, err := reloaded.UnwrapSecret(1)
+	if err != nil || !bytes.Equal(unwrapped[:], secret1) {
+		t.Error("Version 1 should be unwrapped ", err)
+	}
+	if _, err := reloaded.UnwrapSecret(3); err == nil {
+		t.Error("Unknown version should not be unwrapped")
+	}
+}
@@ -20,17 +20,24 @@
 package storage
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/apache/incubator-milagro-crypto/go/src/github.com/miracl/amcl-go"
 )
 
 //Plain text based master secret storage
 const (
-	dtaHome        = "DTA_HOME"
-	secretFileName = "master.secret"
+	dtaHome                = "DTA_HOME"
+	secretFileName         = "master.secret"
+	masterSecretRecordSize = amcl.MPIN_EGS + 8
 )
 
 type PlainTextFileMasterSecretStorage struct {
@@ -38,15 +45,19 @@ type PlainTextFileMasterSecretStorage struct {
 	secretFile   *os.File
 }
 
-func (plainTextFileMasterSecretStorage *PlainTextFileMasterSecretStorage) Init() error {
-	var file *os.File
-	secretFileLocation := os.Getenv(dtaHome)
-	if secretFileLocation == "" {
+//Returns the location of the file in $DTA_HOME or in the current directory if DTA_HOME is not set
+func dtaHomeFile(fileName string) string {
+	home := os.Getenv(dtaHome)
+	if home == "" {
 		log.Printf("%s is not set.Using the current directory", dtaHome)
-		secretFileLocation = secretFileName
-	} else {
-		secretFileLocation = secretFileLocation + string(filepath.Separator) + secretFileName
+		return fileName
 	}
+	return home + string(filepath.Separator) + fileName
+}
+
+func (plainTextFileMasterSecretStorage *PlainTextFileMasterSecretStorage) Init() error {
+	var file *os.File
+	secretFileLocation := dtaHomeFile(secretFileName)
 	if _, err := os.Stat(secretFileLocation); os.IsNotExist(err) {
 		if file, err = os.Create(secretFileLocation); err != nil {
 			return err
@@ -60,32 +71,100 @@ func (plainTextFileMasterSecretStorage *PlainTextFileMasterSecretStorage) Init()
 	return nil
 }
 
-func (plainTextFileMasterSecretStorage PlainTextFileMasterSecretStorage) GetSecret() ([amcl.MPIN_EGS]byte, bool) {
-	var rmasterSecret [amcl.MPIN_EGS]byte
-	masterSecret := make([]byte, amcl.MPIN_EGS)
+func (plainTextFileMasterSecretStorage PlainTextFileMasterSecretStorage) GetSecret(ctx context.Context) (MasterSecret, error) {
+	masterSecrets, err := plainTextFileMasterSecretStorage.GetSecrets(ctx)
+	if err != nil {
+		return MasterSecret{}, err
+	}
+	return masterSecrets[len(masterSecrets)-1], nil
+}
+
+//Appends the secret to the file as a new version. The file is locked while the latest version is checked so that
+//two processes never append the same version
+func (plainTextFileMasterSecretStorage PlainTextFileMasterSecretStorage) SetSecret(ctx context.Context, version int, secret []byte) error {
+	if err := ctx.Err(); err != nil {
+		return unavailable(err)
+	}
 	if err := plainTextFileMasterSecretStorage.Init(); err != nil {
-		return rmasterSecret, false
+		return unavailable(err)
 	}
-	n, err2 := plainTextFileMasterSecretStorage.secretFile.Read(masterSecret)
 	defer plainTextFileMasterSecretStorage.secretFile.Close()
-	if err2 != nil {
-		return rmasterSecret, false
+	if err := lockFile(plainTextFileMasterSecretStorage.secretFile); err != nil {
+		return unavailable(err)
+	}
+	masterSecrets, err := plainTextFileMasterSecretStorage.read()
+	if err != nil {
+		return err
 	}
-	if n == 0 {
-		return rmasterSecret, false
+	if version != len(masterSecrets)+1 {
+		return conflict(fmt.Sprintf("Master secret version %d is not the next version", version))
 	}
-	copy(rmasterSecret[:], masterSecret)
-	return rmasterSecret, true
+	if _, err := plainTextFileMasterSecretStorage.secretFile.Write(encodeMasterSecretRecord(secret, time.Now())); err != nil {
+		return unavailable(err)
+	}
+	return unavailable(plainTextFileMasterSecretStorage.secretFile.Sync())
 }
 
-func (plainTextFileMasterSecretStorage PlainTextFileMasterSecretStorage) SetSecret(secret []byte) error {
-	if err := plainTextFileMasterSecretStorage.Init(); err != nil {
-		return err
+func (plainTextFileMasterSecretStorage PlainTextFileMasterSecretStorage) GetSecrets(ctx context.Context) ([]MasterSecret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, unavailable(err)
 	}
-	if _, err := plainTextFileMasterSecretStorage.secretFile.Write(secret); err != nil {
-		return err
+	if err := plainTextFileMasterSecretStorage.Init(); err != nil {
+		return nil, unavailable(err)
 	}
-	plainTextFileMasterSecretStorage.secretFile.Sync()
 	defer plainTextFileMasterSecretStorage.secretFile.Close()
-	return nil
+	masterSecrets, err := plainTextFileMasterSecretStorage.read()
+	if err != nil {
+		return nil, err
+	}
+	if len(masterSecrets) == 0 {
+		return nil, notFound("No master secret in " + secretFileName)
+	}
+	return masterSecrets, nil
+}
+
+//Reads the master secrets from the open file
+func (plainTextFileMasterSecretStorage PlainTextFileMasterSecretStorage) read() ([]MasterSecret, error) {
+	if _, err := plainTextFileMasterSecretStorage.secretFile.Seek(0, io.SeekStart); err != nil {
+		return nil, unavailable(err)
+	}
+	content, err := ioutil.ReadAll(plainTextFileMasterSecretStorage.secretFile)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	masterSecrets, err := decodeMasterSecretRecords(content)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	return masterSecrets, nil
+}
+
+//Each version of the master secret is stored as the secret followed by the activation time in unix seconds (big
+//endian). Files written before versioning contain only one secret without the activation time.
+func encodeMasterSecretRecord(secret []byte, activatedAt time.Time) []byte {
+	record := make([]byte, masterSecretRecordSize)
+	copy(record, secret)
+	binary.BigEndian.PutUint64(record[amcl.MPIN_EGS:], uint64(activatedAt.Unix()))
+	return record
+}
+
+func decodeMasterSecretRecords(content []byte) ([]MasterSecret, error) {
+	var masterSecrets []MasterSecret
+	if len(content)%masterSecretRecordSize == amcl.MPIN_EGS {
+		legacySecret := MasterSecret{Version: 1}
+		copy(legacySecret.Secret[:], content[:amcl.MPIN_EGS])
+		masterSecrets = append(masterSecrets, legacySecret)
+		content = content[amcl.MPIN_EGS:]
+	}
+	if len(content)%masterSecretRecordSize != 0 {
+		return nil, fmt.Errorf("Invalid master secret file size %d", len(content))
+	}
+	for offset := 0; offset < len(content); offset += masterSecretRecordSize {
+		masterSecret := MasterSecret{Version: len(masterSecrets) + 1}
+		copy(masterSecret.Secret[:], content[offset:offset+amcl.MPIN_EGS])
+		activatedAt := binary.BigEndian.Uint64(content[offset+amcl.MPIN_EGS : offset+masterSecretRecordSize])
+		masterSecret.ActivatedAt = time.Unix(int64(activatedAt), 0)
+		masterSecrets = append(masterSecrets, masterSecret)
+	}
+	return masterSecrets, nil
 }
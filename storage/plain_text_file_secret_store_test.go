@@ -21,8 +21,10 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestPlainTextFileMasterSecretStorage_Basic(t *testing.T) {
@@ -36,24 +38,99 @@ func TestPlainTextFileMasterSecretStorage_Basic(t *testing.T) {
 		os.Remove(masterSecretStorage.secretFile.Name())
 		masterSecretStorage.secretFile.Close()
 	}()
-	_, ok1 := masterSecretStorage.GetSecret()
-	if ok1 {
+	ctx := context.Background()
+	_, err1 := masterSecretStorage.GetSecret(ctx)
+	if Kind(err1) != ErrNotFound {
 		t.Log("secret  should not exisits")
 		t.FailNow()
 	}
 
 	t.Log("Secret  is not exisit. Creating new one")
-	masterSecretStorage.SetSecret(inSecret)
-	outSecret, ok2 := masterSecretStorage.GetSecret()
+	masterSecretStorage.SetSecret(ctx, 1, inSecret)
+	outSecret, err2 := masterSecretStorage.GetSecret(ctx)
 
-	if !ok2 {
+	if err2 != nil {
 		t.Log("secret  should  exisits")
 		t.FailNow()
 	}
 
-	if !bytes.Equal(inSecret[:], outSecret[:]) {
-		t.Log("The secret should match. Expected ", string(inSecret[:]), " received ", string(outSecret[:]))
+	if !bytes.Equal(inSecret[:], outSecret.Secret[:]) {
+		t.Log("The secret should match. Expected ", string(inSecret[:]), " received ", string(outSecret.Secret[:]))
 		t.FailNow()
 	}
 
 }
+
+func TestPlainTextFileMasterSecretStorage_Versions(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+
+	masterSecretStorage := PlainTextFileMasterSecretStorage{}
+	defer func() {
+		masterSecretStorage.Init()
+		os.Remove(masterSecretStorage.secretFile.Name())
+		masterSecretStorage.secretFile.Close()
+	}()
+	secret1 := []byte("dhkgfkdfhs49638543gfdkf38t1fgroe")
+	secret2 := []byte("kdfh3495hdg83h5gjd73h4jdk83hdy72")
+	ctx := context.Background()
+	masterSecretStorage.SetSecret(ctx, 1, secret1)
+	masterSecretStorage.SetSecret(ctx, 2, secret2)
+	if err := masterSecretStorage.SetSecret(ctx, 2, secret1); Kind(err) != ErrConflict {
+		t.Error("Storing an existing version should be a conflict but got ", err)
+	}
+
+	masterSecrets, err := masterSecretStorage.GetSecrets(ctx)
+	if err != nil || len(masterSecrets) != 2 {
+		t.Fatal("Expected 2 master secret versions but got ", len(masterSecrets))
+	}
+	if masterSecrets[0].Version != 1 || !bytes.Equal(masterSecrets[0].Secret[:], secret1) {
+		t.Error("First version should be the first secret")
+	}
+	if masterSecrets[1].Version != 2 || !bytes.Equal(masterSecrets[1].Secret[:], secret2) {
+		t.Error("Second version should be the second secret")
+	}
+	if masterSecrets[1].ActivatedAt.IsZero() {
+		t.Error("Activation time should be stored")
+	}
+	activeSecret, _ := masterSecretStorage.GetSecret(ctx)
+	if !bytes.Equal(activeSecret.Secret[:], secret2) {
+		t.Error("The latest version should be the active secret")
+	}
+}
+
+func TestDecodeMasterSecretRecords_Legacy(t *testing.T) {
+	legacySecret := []byte("dhkgfkdfhs49638543gfdkf38t1fgroe")
+	newSecret := []byte("kdfh3495hdg83h5gjd73h4jdk83hdy72")
+	content := append(append([]byte{}, legacySecret...), encodeMasterSecretRecord(newSecret, time.Now())...)
+
+	masterSecrets, err := decodeMasterSecretRecords(content)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(masterSecrets) != 2 {
+		t.Fatal("Expected 2 master secret versions but got ", len(masterSecrets))
+	}
+	if !bytes.Equal(masterSecrets[0].Secret[:], legacySecret) || !masterSecrets[0].ActivatedAt.IsZero() {
+		t.Error("Secret written before versioning should be read as version 1")
+	}
+	if !bytes.Equal(masterSecrets[1].Secret[:], newSecret) {
+		t.Error("Second version should follow the legacy secret")
+	}
+	if _, err := decodeMasterSecretRecords(content[:len(content)-1]); err == nil {
+		t.Error("Truncated file should be rejected")
+	}
+}
+
+func TestPlainTextFileMasterSecretStorage_CanceledContext(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	masterSecretStorage := PlainTextFileMasterSecretStorage{}
+	if _, err := masterSecretStorage.GetSecrets(ctx); Kind(err) != ErrUnavailable {
+		t.Error("Canceled read should return ErrUnavailable but got ", err)
+	}
+	if err := masterSecretStorage.SetSecret(ctx, 1, []byte("dhkgfkdfhs49638543gfdkf38t1fgroe")); Kind(err) != ErrUnavailable {
+		t.Error("Canceled write should return ErrUnavailable but got ", err)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const signingKeyFileName = "signing.key"
+
+//Signing key storage which keeps the Ed25519 seed in $DTA_HOME/signing.key
+type PlainTextFileSigningKeyStorage struct {
+	fileLocation string
+}
+
+func (signingKeyStorage *PlainTextFileSigningKeyStorage) Init() error {
+	signingKeyStorage.fileLocation = dtaHomeFile(signingKeyFileName)
+	return nil
+}
+
+//Returns ErrNotFound only if the file does not exist. A file which cannot be read or has the wrong size is an error,
+//so that the key is never replaced by accident
+func (signingKeyStorage *PlainTextFileSigningKeyStorage) GetSigningKey() (ed25519.PrivateKey, error) {
+	seed, err := ioutil.ReadFile(signingKeyStorage.fileLocation)
+	if os.IsNotExist(err) {
+		return nil, notFound("No signing key in " + signingKeyStorage.fileLocation)
+	}
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("Invalid signing key size %d in %s", len(seed), signingKeyStorage.fileLocation)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+//Writes the key to a temporary file and renames it so that a crash never leaves a partial key
+func (signingKeyStorage *PlainTextFileSigningKeyStorage) SetSigningKey(key ed25519.PrivateKey) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("Invalid signing key size %d", len(key))
+	}
+	tmpFile := signingKeyStorage.fileLocation + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, key.Seed(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, signingKeyStorage.fileLocation)
+}
@@ -0,0 +1,66 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPlainTextFileSigningKeyStorage_Persistence(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+	defer os.Remove("/tmp/" + signingKeyFileName)
+	os.Remove("/tmp/" + signingKeyFileName)
+
+	signingKeyStorage := &PlainTextFileSigningKeyStorage{}
+	signingKeyStorage.Init()
+	if _, err := signingKeyStorage.GetSigningKey(); Kind(err) != ErrNotFound {
+		t.Fatal("Signing key should not exist but got ", err)
+	}
+	_, signingKey, _ := ed25519.GenerateKey(rand.Reader)
+	if err := signingKeyStorage.SetSigningKey(signingKey); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reloaded := &PlainTextFileSigningKeyStorage{}
+	reloaded.Init()
+	loadedKey, err := reloaded.GetSigningKey()
+	if err != nil || !bytes.Equal(loadedKey, signingKey) {
+		t.Error("Signing key should survive reloading ", err)
+	}
+}
+
+func TestPlainTextFileSigningKeyStorage_InvalidFile(t *testing.T) {
+	os.Setenv("DTA_HOME", "/tmp")
+	defer os.Remove("/tmp/" + signingKeyFileName)
+	if err := ioutil.WriteFile("/tmp/"+signingKeyFileName, []byte("short"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	signingKeyStorage := &PlainTextFileSigningKeyStorage{}
+	signingKeyStorage.Init()
+	if _, err := signingKeyStorage.GetSigningKey(); err == nil || Kind(err) == ErrNotFound {
+		t.Error("Signing key of the wrong size should be an error other than not found but got ", err)
+	}
+}
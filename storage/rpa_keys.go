@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"crypto/ed25519"
+	"strconv"
+	"time"
+)
+
+//Types of relying party application keys
+const (
+	//Secret keys generated by the D-TA and shared with the application
+	KeyTypeShared = "shared"
+	//Ed25519 public keys of the application. The D-TA never holds a key which can sign requests of the application
+	KeyTypeEd25519 = "ed25519"
+)
+
+//Returns the key types which can be registered
+func KeyTypes() []string {
+	return []string{KeyTypeShared, KeyTypeEd25519}
+}
+
+//A key of a relying party application. The application signs its requests with one of its keys which are valid at
+//the time of the request
+type RPAKey struct {
+	//Sequence number of the key in the application, starting from "1"
+	ID        string
+	Key       []byte
+	NotBefore time.Time
+	//The key never expires if it is zero
+	ExpiresAt time.Time
+}
+
+//Returns true if the key verifies requests at the given time
+func (key RPAKey) ValidAt(at time.Time) bool {
+	return !at.Before(key.NotBefore) && (key.ExpiresAt.IsZero() || at.Before(key.ExpiresAt))
+}
+
+//Returns the keys which are valid at the given time. If keyID is not empty only that key is returned
+func (rpa RelyingPartyApplication) ValidKeys(at time.Time, keyID string) []RPAKey {
+	var keys []RPAKey
+	for _, key := range rpa.Keys {
+		if (keyID == "" || key.ID == keyID) && key.ValidAt(at) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+//Returns the application with Application_KEY set to the newest key which is valid at the given time. Storages set it
+//whenever an application is read, so that a key rolled for a later time does not replace the current key early
+func (rpa RelyingPartyApplication) withCurrentKey(at time.Time) RelyingPartyApplication {
+	if keys := rpa.ValidKeys(at, ""); len(keys) > 0 {
+		rpa.Application_KEY = keys[len(keys)-1].Key
+	}
+	return rpa
+}
+
+//Generates a shared key, or checks and returns the public key of applications with Ed25519 keys
+func newRPAKey(keyType string, publicKey []byte) ([]byte, error) {
+	switch keyType {
+	case KeyTypeShared:
+		return generateAppKey()
+	case KeyTypeEd25519:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, invalid("Invalid Ed25519 public key")
+		}
+		return publicKey, nil
+	}
+	return nil, invalid("Unknown RPA key type " + keyType)
+}
+
+//Creates the first key of a new application. Applications without a key type get shared keys
+func firstRPAKey(rpa *RelyingPartyApplication, notBefore time.Time) (RPAKey, error) {
+	if rpa.KeyType == "" {
+		rpa.KeyType = KeyTypeShared
+	}
+	appKey, err := newRPAKey(rpa.KeyType, rpa.Application_KEY)
+	if err != nil {
+		return RPAKey{}, err
+	}
+	return RPAKey{ID: "1", Key: appKey, NotBefore: notBefore}, nil
+}
+
+//Adds a new key valid from notBefore. The other keys expire after the grace period, counted from notBefore, unless
+//they expire earlier. Keys which already expired are removed. The new key is the last one
+func rotateRPAKeys(keys []RPAKey, keyType string, publicKey []byte, notBefore time.Time, gracePeriod time.Duration, now time.Time) ([]RPAKey, error) {
+	appKey, err := newRPAKey(keyType, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if notBefore.Before(now) {
+		notBefore = now
+	}
+	expiresAt := notBefore.Add(gracePeriod)
+	lastID := 0
+	var rotated []RPAKey
+	for _, key := range keys {
+		if id, err := strconv.Atoi(key.ID); err == nil && id > lastID {
+			lastID = id
+		}
+		if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+			continue
+		}
+		if key.ExpiresAt.IsZero() || key.ExpiresAt.After(expiresAt) {
+			key.ExpiresAt = expiresAt
+		}
+		rotated = append(rotated, key)
+	}
+	return append(rotated, RPAKey{ID: strconv.Itoa(lastID + 1), Key: appKey, NotBefore: notBefore}), nil
+}
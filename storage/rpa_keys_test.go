@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+)
+
+func TestRotateRPAKeys(t *testing.T) {
+	now := time.Now().UTC()
+	rpa := RelyingPartyApplication{}
+	first, err := firstRPAKey(&rpa, now.Add(-48*time.Hour))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rpa.KeyType != KeyTypeShared {
+		t.Error("Applications should get shared keys by default")
+	}
+	keys, err := rotateRPAKeys([]RPAKey{first}, rpa.KeyType, nil, time.Time{}, time.Hour, now)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(keys) != 2 || keys[1].ID != "2" || !keys[1].NotBefore.Equal(now) || !keys[1].ExpiresAt.IsZero() {
+		t.Fatal("New key should be added as key 2 valid from now ", keys)
+	}
+	if !keys[0].ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Error("Previous key should expire after the grace period ", keys[0].ExpiresAt)
+	}
+	rpa.Keys = keys
+	if len(rpa.ValidKeys(now, "")) != 2 || len(rpa.ValidKeys(now, "2")) != 1 {
+		t.Error("Both keys should verify during the grace period")
+	}
+	if validKeys := rpa.ValidKeys(now.Add(2*time.Hour), ""); len(validKeys) != 1 || validKeys[0].ID != "2" {
+		t.Error("Only the new key should verify after the grace period ", validKeys)
+	}
+
+	later := now.Add(2 * time.Hour)
+	keys, _ = rotateRPAKeys(keys, rpa.KeyType, nil, later.Add(time.Hour), time.Hour, later)
+	if len(keys) != 2 || keys[0].ID != "2" || keys[1].ID != "3" {
+		t.Fatal("Expired key should be removed ", keys)
+	}
+	if !keys[0].ExpiresAt.Equal(later.Add(2 * time.Hour)) {
+		t.Error("Grace period should start when the new key becomes valid ", keys[0].ExpiresAt)
+	}
+	if keys[1].ValidAt(later) {
+		t.Error("New key should not verify before its not before time")
+	}
+	rpa.Keys = keys
+	if current := rpa.withCurrentKey(later); !bytes.Equal(current.Application_KEY, keys[0].Key) {
+		t.Error("App key should stay the newest valid key until the new key becomes valid")
+	}
+	if current := rpa.withCurrentKey(later.Add(time.Hour)); !bytes.Equal(current.Application_KEY, keys[1].Key) {
+		t.Error("New key should become the App key at its not before time")
+	}
+}
+
+func TestRotateRPAKeys_Ed25519(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	rpa := RelyingPartyApplication{KeyType: KeyTypeEd25519, Application_KEY: publicKey}
+	first, err := firstRPAKey(&rpa, time.Now())
+	if err != nil || !bytes.Equal(first.Key, publicKey) {
+		t.Fatal("Public key should be the first key ", err)
+	}
+	if _, err := rotateRPAKeys([]RPAKey{first}, rpa.KeyType, nil, time.Time{}, time.Hour, time.Now()); Kind(err) != ErrInvalid {
+		t.Error("Ed25519 key should not be rolled without a public key")
+	}
+	if _, err := firstRPAKey(&RelyingPartyApplication{KeyType: KeyTypeEd25519, Application_KEY: publicKey[1:]}, time.Now()); Kind(err) != ErrInvalid {
+		t.Error("Invalid public key should be refused")
+	}
+	nextPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := rotateRPAKeys([]RPAKey{first}, rpa.KeyType, nextPublicKey, time.Time{}, time.Hour, time.Now())
+	if err != nil || !bytes.Equal(keys[1].Key, nextPublicKey) {
+		t.Error("Public key should become the new key ", err)
+	}
+}
@@ -0,0 +1,453 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	//SQLite is the default driver. Other database/sql drivers can be registered by importing them. Duplicate
+	//registrations are recognised by the SQLite error code, and for other drivers by looking the application up
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	defaultRPADriver   = "sqlite3"
+	defaultRPADatabase = "rpa.db"
+)
+
+//Schema migrations of the RPA database. Each entry is applied once, in order, and recorded in schema_migrations.
+//Never change an entry which is released, append a new one instead
+var rpaMigrations = []string{
+	`CREATE TABLE rpa (
+		app_id VARCHAR(255) NOT NULL PRIMARY KEY,
+		app_key BLOB NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE TABLE rpa_metadata (
+		app_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (app_id, name)
+	)`,
+	`ALTER TABLE rpa ADD COLUMN revision BIGINT NOT NULL DEFAULT 1`,
+	`ALTER TABLE rpa ADD COLUMN display_name VARCHAR(255) NOT NULL DEFAULT ''`,
+	`ALTER TABLE rpa ADD COLUMN owner_contact VARCHAR(255) NOT NULL DEFAULT ''`,
+	`ALTER TABLE rpa ADD COLUMN updated_at TIMESTAMP`,
+	`UPDATE rpa SET updated_at = created_at`,
+	`ALTER TABLE rpa ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT 0`,
+	`ALTER TABLE rpa ADD COLUMN allowed_operations TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rpa ADD COLUMN max_permit_range INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE rpa ADD COLUMN allowed_identity_domains TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE rpa_keys (
+		app_id VARCHAR(255) NOT NULL,
+		key_id VARCHAR(64) NOT NULL,
+		app_key BLOB NOT NULL,
+		not_before TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP,
+		PRIMARY KEY (app_id, key_id)
+	)`,
+	`INSERT INTO rpa_keys (app_id, key_id, app_key, not_before) SELECT app_id, '1', app_key, created_at FROM rpa`,
+	`ALTER TABLE rpa ADD COLUMN key_type VARCHAR(32) NOT NULL DEFAULT 'shared'`,
+	`ALTER TABLE rpa ADD COLUMN certificate_fingerprints TEXT NOT NULL DEFAULT ''`,
+}
+
+const rpaColumns = "app_id, app_key, display_name, owner_contact, created_at, updated_at, disabled, allowed_operations, max_permit_range, allowed_identity_domains, revision, key_type, certificate_fingerprints"
+
+//RPA storage in a database/sql database. By default it is SQLite in $DTA_HOME/rpa.db
+type SQLRPAStorage struct {
+	Driver     string
+	DataSource string
+	db         *sql.DB
+}
+
+//Opens the database and applies the pending schema migrations
+func (rpaStorage *SQLRPAStorage) Init(ctx context.Context) error {
+	if rpaStorage.Driver == "" {
+		rpaStorage.Driver = defaultRPADriver
+	}
+	if rpaStorage.DataSource == "" {
+		rpaStorage.DataSource = dtaHomeFile(defaultRPADatabase)
+	}
+	db, err := sql.Open(rpaStorage.Driver, rpaStorage.DataSource)
+	if err != nil {
+		return unavailable(errors.Wrap(err, "Error in opening the RPA database"))
+	}
+	if err := migrate(ctx, db, rpaMigrations); err != nil {
+		db.Close()
+		return unavailable(err)
+	}
+	rpaStorage.db = db
+	return nil
+}
+
+func (rpaStorage *SQLRPAStorage) Close() error {
+	return rpaStorage.db.Close()
+}
+
+//Applies the migrations which are not recorded in schema_migrations, each in its own transaction
+func migrate(ctx context.Context, db *sql.DB, migrations []string) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return errors.Wrap(err, "Error in creating schema_migrations")
+	}
+	var current int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return errors.Wrap(err, "Error in reading the schema version")
+	}
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[version-1]); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error in applying schema migration %d", version)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", version, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error in recording schema migration %d", version)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Println("Applied RPA schema migration ", version)
+	}
+	return nil
+}
+
+//Returns true if err is a violation of a primary key or unique constraint
+func isUniqueConstraintError(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique)
+}
+
+//Runs fn in a transaction which is committed if fn returns no error
+func (rpaStorage *SQLRPAStorage) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := rpaStorage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return unavailable(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return unavailable(err)
+	}
+	return unavailable(tx.Commit())
+}
+
+func (rpaStorage *SQLRPAStorage) RegisterRPA(ctx context.Context, relyingPartyApplication RelyingPartyApplication) (RelyingPartyApplication, error) {
+	createdAt := time.Now().UTC()
+	key, err := firstRPAKey(&relyingPartyApplication, createdAt)
+	if err != nil {
+		return RelyingPartyApplication{}, unavailable(err)
+	}
+	log.Println("Generating appkey for ", relyingPartyApplication.Application_ID)
+	relyingPartyApplication.Application_KEY = key.Key
+	relyingPartyApplication.Keys = []RPAKey{key}
+	relyingPartyApplication.CreatedAt = createdAt
+	relyingPartyApplication.UpdatedAt = relyingPartyApplication.CreatedAt
+	relyingPartyApplication.Revision = 1
+	//The primary key refuses a duplicate, also when two registrations of the same ID run at the same time
+	err = rpaStorage.transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO rpa ("+rpaColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			relyingPartyApplication.Application_ID, key.Key, relyingPartyApplication.DisplayName, relyingPartyApplication.OwnerContact,
+			relyingPartyApplication.CreatedAt, relyingPartyApplication.UpdatedAt, relyingPartyApplication.Disabled,
+			joinList(relyingPartyApplication.AllowedOperations), relyingPartyApplication.MaxPermitRange,
+			joinList(relyingPartyApplication.AllowedIdentityDomains), relyingPartyApplication.Revision, relyingPartyApplication.KeyType,
+			joinList(relyingPartyApplication.CertificateFingerprints)); err != nil {
+			if isUniqueConstraintError(err) {
+				return alreadyExists("RPA " + relyingPartyApplication.Application_ID)
+			}
+			return err
+		}
+		if err := insertKeys(ctx, tx, relyingPartyApplication.Application_ID, relyingPartyApplication.Keys); err != nil {
+			return err
+		}
+		return insertMetadata(ctx, tx, relyingPartyApplication.Application_ID, relyingPartyApplication.Metadata)
+	})
+	if err != nil {
+		//Drivers other than SQLite return their own errors for a violated primary key
+		if Kind(err) == ErrUnavailable && rpaStorage.exists(ctx, relyingPartyApplication.Application_ID) {
+			return RelyingPartyApplication{}, alreadyExists("RPA " + relyingPartyApplication.Application_ID)
+		}
+		return RelyingPartyApplication{}, err
+	}
+	return relyingPartyApplication, nil
+}
+
+//Returns true if the application is stored. Errors are reported as false
+func (rpaStorage *SQLRPAStorage) exists(ctx context.Context, appID string) bool {
+	var one int
+	return rpaStorage.db.QueryRowContext(ctx, "SELECT 1 FROM rpa WHERE app_id = ?", appID).Scan(&one) == nil
+}
+
+//Replaces the mutable attributes when the revision matches. The revision is checked and incremented by the same
+//statement, so concurrent updates of the same revision never both succeed
+func (rpaStorage *SQLRPAStorage) UpdateRPA(ctx context.Context, relyingPartyApplication RelyingPartyApplication) (RelyingPartyApplication, error) {
+	appID := relyingPartyApplication.Application_ID
+	err := rpaStorage.transaction(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE rpa SET display_name = ?, owner_contact = ?, updated_at = ?, disabled = ?,
+			allowed_operations = ?, max_permit_range = ?, allowed_identity_domains = ?, certificate_fingerprints = ?,
+			revision = revision + 1 WHERE app_id = ? AND revision = ?`,
+			relyingPartyApplication.DisplayName, relyingPartyApplication.OwnerContact, time.Now().UTC(), relyingPartyApplication.Disabled,
+			joinList(relyingPartyApplication.AllowedOperations), relyingPartyApplication.MaxPermitRange,
+			joinList(relyingPartyApplication.AllowedIdentityDomains), joinList(relyingPartyApplication.CertificateFingerprints),
+			appID, relyingPartyApplication.Revision)
+		if err != nil {
+			return err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			var revision int64
+			if err := tx.QueryRowContext(ctx, "SELECT revision FROM rpa WHERE app_id = ?", appID).Scan(&revision); err == sql.ErrNoRows {
+				return notFound("RPA " + appID)
+			} else if err != nil {
+				return err
+			}
+			return conflict(fmt.Sprintf("RPA %s is at revision %d", appID, revision))
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rpa_metadata WHERE app_id = ?", appID); err != nil {
+			return err
+		}
+		return insertMetadata(ctx, tx, appID, relyingPartyApplication.Metadata)
+	})
+	if err != nil {
+		return RelyingPartyApplication{}, err
+	}
+	return rpaStorage.GetRPA(ctx, appID)
+}
+
+func (rpaStorage *SQLRPAStorage) RotateRPAKey(ctx context.Context, appID string, publicKey []byte, notBefore time.Time, gracePeriod time.Duration) (RelyingPartyApplication, error) {
+	err := rpaStorage.transaction(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		var keyType string
+		if err := tx.QueryRowContext(ctx, "SELECT key_type FROM rpa WHERE app_id = ?", appID).Scan(&keyType); err == sql.ErrNoRows {
+			return notFound("RPA " + appID)
+		} else if err != nil {
+			return err
+		}
+		keys, err := queryKeys(ctx, tx, appID)
+		if err != nil {
+			return err
+		}
+		if keys, err = rotateRPAKeys(keys, keyType, publicKey, notBefore, gracePeriod, now); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE rpa SET updated_at = ?, revision = revision + 1 WHERE app_id = ?", now, appID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rpa_keys WHERE app_id = ?", appID); err != nil {
+			return err
+		}
+		return insertKeys(ctx, tx, appID, keys)
+	})
+	if err != nil {
+		return RelyingPartyApplication{}, err
+	}
+	return rpaStorage.GetRPA(ctx, appID)
+}
+
+func insertKeys(ctx context.Context, tx *sql.Tx, appID string, keys []RPAKey) error {
+	for _, key := range keys {
+		var expiresAt sql.NullTime
+		if !key.ExpiresAt.IsZero() {
+			expiresAt = sql.NullTime{Time: key.ExpiresAt, Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO rpa_keys (app_id, key_id, app_key, not_before, expires_at) VALUES (?, ?, ?, ?, ?)",
+			appID, key.ID, key.Key, key.NotBefore, expiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//*sql.DB or *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+//Returns the keys of the application, oldest first
+func queryKeys(ctx context.Context, db queryer, appID string) ([]RPAKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT key_id, app_key, not_before, expires_at FROM rpa_keys WHERE app_id = ? ORDER BY not_before, key_id", appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []RPAKey
+	for rows.Next() {
+		key := RPAKey{}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Key, &key.NotBefore, &expiresAt); err != nil {
+			return nil, err
+		}
+		key.ExpiresAt = expiresAt.Time
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func insertMetadata(ctx context.Context, tx *sql.Tx, appID string, metadata map[string]string) error {
+	for name, value := range metadata {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO rpa_metadata (app_id, name, value) VALUES (?, ?, ?)", appID, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rpaStorage *SQLRPAStorage) GetAllRPAs(ctx context.Context) ([]RelyingPartyApplication, error) {
+	now := time.Now().UTC()
+	rows, err := rpaStorage.db.QueryContext(ctx, "SELECT "+rpaColumns+" FROM rpa ORDER BY app_id")
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+	var apps []RelyingPartyApplication
+	for rows.Next() {
+		app, err := scanRPA(rows)
+		if err != nil {
+			return nil, unavailable(err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, unavailable(err)
+	}
+	for i := range apps {
+		if apps[i].Metadata, err = rpaStorage.metadata(ctx, apps[i].Application_ID); err != nil {
+			return nil, err
+		}
+		if apps[i].Keys, err = queryKeys(ctx, rpaStorage.db, apps[i].Application_ID); err != nil {
+			return nil, unavailable(err)
+		}
+		apps[i] = apps[i].withCurrentKey(now)
+	}
+	return apps, nil
+}
+
+func (rpaStorage *SQLRPAStorage) Ping(ctx context.Context) error {
+	return unavailable(rpaStorage.db.PingContext(ctx))
+}
+
+func (rpaStorage *SQLRPAStorage) CountRPAs(ctx context.Context) (int, error) {
+	var count int
+	if err := rpaStorage.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM rpa").Scan(&count); err != nil {
+		return 0, unavailable(err)
+	}
+	return count, nil
+}
+
+func (rpaStorage *SQLRPAStorage) GetRPA(ctx context.Context, rpaID string) (RelyingPartyApplication, error) {
+	app, err := scanRPA(rpaStorage.db.QueryRowContext(ctx, "SELECT "+rpaColumns+" FROM rpa WHERE app_id = ?", rpaID))
+	if err == sql.ErrNoRows {
+		return RelyingPartyApplication{}, notFound("RPA " + rpaID)
+	}
+	if err != nil {
+		return RelyingPartyApplication{}, unavailable(err)
+	}
+	if app.Metadata, err = rpaStorage.metadata(ctx, rpaID); err != nil {
+		return RelyingPartyApplication{}, err
+	}
+	if app.Keys, err = queryKeys(ctx, rpaStorage.db, rpaID); err != nil {
+		return RelyingPartyApplication{}, unavailable(err)
+	}
+	return app.withCurrentKey(time.Now().UTC()), nil
+}
+
+//*sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//Scans a row of rpaColumns
+func scanRPA(row rowScanner) (RelyingPartyApplication, error) {
+	app := RelyingPartyApplication{}
+	var allowedOperations, allowedIdentityDomains, certificateFingerprints string
+	err := row.Scan(&app.Application_ID, &app.Application_KEY, &app.DisplayName, &app.OwnerContact, &app.CreatedAt, &app.UpdatedAt,
+		&app.Disabled, &allowedOperations, &app.MaxPermitRange, &allowedIdentityDomains, &app.Revision, &app.KeyType,
+		&certificateFingerprints)
+	app.AllowedOperations = splitList(allowedOperations)
+	app.AllowedIdentityDomains = splitList(allowedIdentityDomains)
+	app.CertificateFingerprints = splitList(certificateFingerprints)
+	return app, err
+}
+
+//Operations, domains and fingerprints never contain commas, so lists are stored comma separated
+func joinList(values []string) string {
+	return strings.Join(values, ",")
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func (rpaStorage *SQLRPAStorage) metadata(ctx context.Context, appID string) (map[string]string, error) {
+	rows, err := rpaStorage.db.QueryContext(ctx, "SELECT name, value FROM rpa_metadata WHERE app_id = ?", appID)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer rows.Close()
+	var metadata map[string]string
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, unavailable(err)
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[name] = value
+	}
+	return metadata, unavailable(rows.Err())
+}
+
+func (rpaStorage *SQLRPAStorage) DeleteRPA(ctx context.Context, appID string) error {
+	return rpaStorage.transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rpa_metadata WHERE app_id = ?", appID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rpa_keys WHERE app_id = ?", appID); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM rpa WHERE app_id = ?", appID)
+		if err != nil {
+			return err
+		}
+		if deleted, err := result.RowsAffected(); err != nil {
+			return err
+		} else if deleted == 0 {
+			return notFound("RPA " + appID)
+		}
+		return nil
+	})
+}
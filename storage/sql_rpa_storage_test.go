@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements. See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership. The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License. You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLRPAStorage(t *testing.T) (*SQLRPAStorage, func()) {
+	dir, err := ioutil.TempDir("", "dta-rpa")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rpaStorage := &SQLRPAStorage{DataSource: filepath.Join(dir, "rpa.db")}
+	if err := rpaStorage.Init(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	return rpaStorage, func() { os.RemoveAll(dir) }
+}
+
+func TestSQLRPAStorage_Persistence(t *testing.T) {
+	rpaStorage, cleanup := newTestSQLRPAStorage(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	registered, err := rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{
+		Application_ID:         "app1",
+		DisplayName:            "App 1",
+		AllowedOperations:      []string{OperationClientSecret, OperationTimePermit},
+		MaxPermitRange:         7,
+		AllowedIdentityDomains: []string{"apache.org"},
+		Metadata:               map[string]string{"owner": "apache"},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{Application_ID: "app2"})
+	if err := rpaStorage.DeleteRPA(ctx, "app2"); err != nil {
+		t.Fatal(err.Error())
+	}
+	rpaStorage.Close()
+
+	reopened := &SQLRPAStorage{DataSource: rpaStorage.DataSource}
+	if err := reopened.Init(ctx); err != nil {
+		t.Fatal("Reopening should not apply the migrations again ", err.Error())
+	}
+	defer reopened.Close()
+	app, err := reopened.GetRPA(ctx, "app1")
+	if err != nil || len(app.Application_KEY) != 16 || !bytes.Equal(app.Application_KEY, registered.Application_KEY) {
+		t.Error("App key should survive reopening the database")
+	}
+	if app.CreatedAt.IsZero() || app.Metadata["owner"] != "apache" || app.Revision != 1 {
+		t.Error("Creation time, metadata and revision should be stored ", app)
+	}
+	if app.DisplayName != "App 1" || app.MaxPermitRange != 7 || len(app.AllowedOperations) != 2 || app.AllowedIdentityDomains[0] != "apache.org" {
+		t.Error("Capabilities should be stored ", app)
+	}
+	if _, err := reopened.GetRPA(ctx, "app2"); Kind(err) != ErrNotFound {
+		t.Error("Deleted app should not be returned but got ", err)
+	}
+	if err := reopened.DeleteRPA(ctx, "app2"); Kind(err) != ErrNotFound {
+		t.Error("Deleting an unknown app should return ErrNotFound but got ", err)
+	}
+	if apps, _ := reopened.GetAllRPAs(ctx); len(apps) != 1 || apps[0].Application_ID != "app1" {
+		t.Error("Expected only app1 but got ", apps)
+	}
+	if count, err := reopened.CountRPAs(ctx); err != nil || count != 1 {
+		t.Error("Expected a count of 1 but got ", count, err)
+	}
+	if err := reopened.Ping(ctx); err != nil {
+		t.Error("Open database should answer a ping but got ", err)
+	}
+	var version int
+	reopened.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if version != len(rpaMigrations) {
+		t.Error("Every migration should be recorded but got version ", version)
+	}
+}
+
+func TestSQLRPAStorage_Revisions(t *testing.T) {
+	rpaStorage, cleanup := newTestSQLRPAStorage(t)
+	defer cleanup()
+	defer rpaStorage.Close()
+	ctx := context.Background()
+
+	registered, _ := rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{Application_ID: "app1"})
+	if _, err := rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{Application_ID: "app1"}); Kind(err) != ErrAlreadyExists {
+		t.Error("Registering twice should return ErrAlreadyExists but got ", err)
+	}
+
+	registered.Metadata = map[string]string{"owner": "apache"}
+	registered.Disabled = true
+	updated, err := rpaStorage.UpdateRPA(ctx, registered)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if updated.Revision != 2 || updated.Metadata["owner"] != "apache" || !updated.Disabled || !bytes.Equal(updated.Application_KEY, registered.Application_KEY) {
+		t.Error("Update should replace the metadata and increment the revision ", updated)
+	}
+	if updated.UpdatedAt.Before(updated.CreatedAt) {
+		t.Error("Update time should be set ", updated.UpdatedAt)
+	}
+	registered.Metadata = map[string]string{"owner": "stale"}
+	if _, err := rpaStorage.UpdateRPA(ctx, registered); Kind(err) != ErrConflict {
+		t.Error("Updating a stale revision should return ErrConflict but got ", err)
+	}
+	if _, err := rpaStorage.UpdateRPA(ctx, RelyingPartyApplication{Application_ID: "app2", Revision: 1}); Kind(err) != ErrNotFound {
+		t.Error("Updating an unknown app should return ErrNotFound but got ", err)
+	}
+	if app, _ := rpaStorage.GetRPA(ctx, "app1"); app.Metadata["owner"] != "apache" {
+		t.Error("Conflicting update should not change the metadata ", app.Metadata)
+	}
+}
+
+func TestSQLRPAStorage_RotateRPAKey(t *testing.T) {
+	rpaStorage, cleanup := newTestSQLRPAStorage(t)
+	defer cleanup()
+	defer rpaStorage.Close()
+	ctx := context.Background()
+
+	registered, _ := rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{Application_ID: "app1"})
+	if len(registered.Keys) != 1 || !bytes.Equal(registered.Keys[0].Key, registered.Application_KEY) {
+		t.Fatal("Registered app should have its App key as key 1 ", registered.Keys)
+	}
+	rotated, err := rpaStorage.RotateRPAKey(ctx, "app1", nil, time.Time{}, time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(rotated.Keys) != 2 || rotated.Revision != 2 || !bytes.Equal(rotated.Application_KEY, rotated.Keys[1].Key) {
+		t.Fatal("Rotated key should become the App key ", rotated)
+	}
+	if rotated.Keys[0].ExpiresAt.IsZero() || !rotated.Keys[1].ExpiresAt.IsZero() {
+		t.Error("Only the previous key should expire ", rotated.Keys)
+	}
+	pending, err := rpaStorage.RotateRPAKey(ctx, "app1", nil, time.Now().Add(time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(pending.Keys) != 3 || !bytes.Equal(pending.Application_KEY, rotated.Application_KEY) {
+		t.Error("Key rolled for a later time should not become the App key yet ", pending)
+	}
+	if app, _ := rpaStorage.GetRPA(ctx, "app1"); !bytes.Equal(app.Application_KEY, rotated.Application_KEY) {
+		t.Error("Reading the app should return the newest valid key as the App key")
+	}
+	if _, err := rpaStorage.RotateRPAKey(ctx, "app2", nil, time.Time{}, time.Hour); Kind(err) != ErrNotFound {
+		t.Error("Rotating the key of an unknown app should return ErrNotFound but got ", err)
+	}
+}
+
+func TestSQLRPAStorage_Ed25519Keys(t *testing.T) {
+	rpaStorage, cleanup := newTestSQLRPAStorage(t)
+	defer cleanup()
+	defer rpaStorage.Close()
+	ctx := context.Background()
+
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{Application_ID: "app1", KeyType: KeyTypeEd25519, Application_KEY: publicKey}); err != nil {
+		t.Fatal(err.Error())
+	}
+	app, err := rpaStorage.GetRPA(ctx, "app1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if app.KeyType != KeyTypeEd25519 || !bytes.Equal(app.Application_KEY, publicKey) || !bytes.Equal(app.Keys[0].Key, publicKey) {
+		t.Fatal("Public key should be stored as the App key ", app)
+	}
+	nextPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	rotated, err := rpaStorage.RotateRPAKey(ctx, "app1", nextPublicKey, time.Time{}, time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(rotated.Application_KEY, nextPublicKey) || len(rotated.Keys) != 2 {
+		t.Error("Rolled public key should become the App key ", rotated)
+	}
+	//Invalid public keys are refused as invalid input rather than as an unavailable storage
+	if _, err := rpaStorage.RotateRPAKey(ctx, "app1", nextPublicKey[1:], time.Time{}, time.Hour); Kind(err) != ErrInvalid {
+		t.Error("Invalid public key should be refused as invalid but got ", err)
+	}
+	if _, err := rpaStorage.RegisterRPA(ctx, RelyingPartyApplication{Application_ID: "app2", KeyType: KeyTypeEd25519}); Kind(err) != ErrInvalid {
+		t.Error("Missing public key should be refused as invalid but got ", err)
+	}
+}
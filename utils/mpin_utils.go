@@ -25,8 +25,9 @@ import (
 	"github.com/miracl/amcl-go"
 )
 
-//Util method to validate the generated server secret,client secret ,time permit according to M-Pin protocol
-func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, clientID string, pin int) {
+//Util method to validate the generated server secret,client secret ,time permit according to M-Pin protocol.
+//Returns an error if the client does not authenticate with the given secrets
+func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, clientID string, pin int) error {
 
 	// Assign the End-User an ID
 	IDstr := clientID
@@ -48,7 +49,7 @@ func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, c
 	seed, err := hex.DecodeString(seedHex)
 	if err != nil {
 		fmt.Println("Error decoding seed value")
-		return
+		return err
 	}
 	rng := amcl.NewRAND()
 	rng.Seed(len(seed), seed)
@@ -104,7 +105,7 @@ func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, c
 	rtn := amcl.MPIN_EXTRACT_PIN(ID, PIN1, TOKEN[:])
 	if rtn != 0 {
 		fmt.Printf("FAILURE: EXTRACT_PIN rtn: %d\n", rtn)
-		return
+		return fmt.Errorf("EXTRACT_PIN failed %d", rtn)
 	}
 	fmt.Printf("Client Token TK: 0x")
 	amcl.MPIN_printBinary(TOKEN[:])
@@ -118,7 +119,7 @@ func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, c
 	rtn = amcl.MPIN_CLIENT_1(date, ID, rng, X[:], PIN2, TOKEN[:], SEC[:], U[:], UT[:], TP[:])
 	if rtn != 0 {
 		fmt.Printf("FAILURE: CLIENT rtn: %d\n", rtn)
-		return
+		return fmt.Errorf("CLIENT_1 failed %d", rtn)
 	}
 
 	/* Server first pass. Calculate H(ID) and H(T|H(ID)) (if time permits enabled), and maps them to points on the curve HID and HTID resp. */
@@ -131,6 +132,7 @@ func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, c
 	rtn = amcl.MPIN_CLIENT_2(X[:], Y[:], SEC[:])
 	if rtn != 0 {
 		fmt.Printf("FAILURE: CLIENT_2 rtn: %d\n", rtn)
+		return fmt.Errorf("CLIENT_2 failed %d", rtn)
 	}
 
 	/* Server Second pass. Inputs hashed client id, random Y, -(x+y)*SEC, xID and xCID and Server secret SST. E and F help kangaroos to find error. */
@@ -150,8 +152,10 @@ func ValidateMpin(serverSecret []byte, clientSecret []byte, timePermit []byte, c
 		if err != 0 {
 			fmt.Printf("PIN Error %d\n", err)
 		}
-		return
-	} else {
-		fmt.Printf("Authenticated ID: %s \n", IDstr)
+		return fmt.Errorf("Authentication failed %d", rtn)
+	} else if rtn != 0 {
+		return fmt.Errorf("SERVER_2 failed %d", rtn)
 	}
+	fmt.Printf("Authenticated ID: %s \n", IDstr)
+	return nil
 }